@@ -0,0 +1,122 @@
+// Package broadcast runs commands on multiple instances in parallel.
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const DefaultConcurrency = 10 // DefaultConcurrency is the default number of commands running at the same time
+
+const waitDelay = time.Second // waitDelay is how long the output of an interrupted command is read
+
+// Job is a command to run for an instance
+type Job struct {
+	ID   string   // instance ID
+	Args []string // program and arguments
+}
+
+// Result is the outcome of a job
+type Result struct {
+	Job      *Job
+	ExitCode int // -1 when the command could not be started or was interrupted
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+	Err      error
+}
+
+// OutputFunc receives the output of a job as it is written, the chunks are not split on lines
+type OutputFunc func(job *Job, data []byte, stderr bool)
+
+// Run runs the jobs with at most concurrency commands at the same time, output (when not nil) is
+// called with the output of the commands as it is written, and done as soon as each job completes,
+// both from the job goroutines, Run returns once all jobs are done
+func Run(ctx context.Context, jobs []*Job, concurrency int, output OutputFunc, done func(*Result)) {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for _, job := range jobs {
+		wg.Add(1)
+
+		go func(job *Job) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				done(&Result{Job: job, ExitCode: -1, Err: ctx.Err()})
+				return
+			}
+
+			done(runJob(ctx, job, output))
+		}(job)
+	}
+
+	wg.Wait()
+}
+
+func runJob(ctx context.Context, job *Job, output OutputFunc) *Result {
+	result := &Result{Job: job, ExitCode: -1}
+
+	if len(job.Args) == 0 {
+		result.Err = errors.New("empty command")
+		return result
+	}
+
+	stdout := &writer{job: job, output: output}
+	stderr := &writer{job: job, output: output, stderr: true}
+
+	cmd := exec.CommandContext(ctx, job.Args[0], job.Args[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay // the processes started by the command could keep its output open once it is killed
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Stdout = stdout.buffer.Bytes()
+	result.Stderr = stderr.buffer.Bytes()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case ctx.Err() != nil:
+		result.Err = ctx.Err()
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.Err = err
+	}
+
+	return result
+}
+
+// writer keeps the output of a command, and passes it to the output function as it is written
+type writer struct {
+	job    *Job
+	output OutputFunc
+	stderr bool
+	buffer bytes.Buffer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+
+	if w.output != nil {
+		// the caller can reuse p
+		w.output(w.job, append([]byte{}, p...), w.stderr)
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,219 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run runs the jobs and returns their results by job ID
+func run(ctx context.Context, jobs []*Job, concurrency int, output OutputFunc) map[string]*Result {
+	results := make(map[string]*Result)
+	mutex := sync.Mutex{}
+
+	Run(ctx, jobs, concurrency, output, func(result *Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		results[result.Job.ID] = result
+	})
+
+	return results
+}
+
+func shell(id string, script string) *Job {
+	return &Job{ID: id, Args: []string{"sh", "-c", script}}
+}
+
+func TestRunResults(t *testing.T) {
+	tests := []struct {
+		name       string
+		job        *Job
+		wantCode   int
+		wantStdout string
+		wantStderr string
+		wantErr    string
+	}{
+		{"success", shell("ok", "echo hello"), 0, "hello\n", "", ""},
+		{"exit code", shell("exit", "echo out; echo err >&2; exit 3"), 3, "out\n", "err\n", ""},
+		{"empty command", &Job{ID: "empty"}, -1, "", "", "empty command"},
+		{"not found", &Job{ID: "missing", Args: []string{"gosh-missing-command"}}, -1, "", "", "executable file not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := run(context.Background(), []*Job{test.job}, 1, nil)[test.job.ID]
+			if result == nil {
+				t.Fatal("no result")
+			}
+
+			if result.ExitCode != test.wantCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, test.wantCode)
+			}
+			if string(result.Stdout) != test.wantStdout || string(result.Stderr) != test.wantStderr {
+				t.Errorf("output = %q, %q, want %q, %q", result.Stdout, result.Stderr, test.wantStdout, test.wantStderr)
+			}
+			if (len(test.wantErr) == 0) != (result.Err == nil) || (result.Err != nil && !strings.Contains(result.Err.Error(), test.wantErr)) {
+				t.Errorf("Err = %v, want %q", result.Err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	dir := t.TempDir()
+
+	// each job records the number of jobs running when it starts
+	script := fmt.Sprintf(`mkdir -p %[1]s/running && touch %[1]s/running/$$ && ls %[1]s/running | wc -l > %[1]s/$$.count && sleep 0.2 && rm %[1]s/running/$$`, dir)
+
+	jobs := []*Job{}
+	for n := 0; n < 8; n++ {
+		jobs = append(jobs, shell(fmt.Sprint(n), script))
+	}
+
+	const concurrency = 3
+
+	start := time.Now()
+	results := run(context.Background(), jobs, concurrency, nil)
+	elapsed := time.Since(start)
+
+	for _, job := range jobs {
+		if result := results[job.ID]; result == nil || result.ExitCode != 0 {
+			t.Fatalf("job %s result = %+v, want a success", job.ID, result)
+		}
+	}
+
+	counts, err := filepath.Glob(filepath.Join(dir, "*.count"))
+	if err != nil || len(counts) != len(jobs) {
+		t.Fatalf("found %d counts (%v), want %d", len(counts), err, len(jobs))
+	}
+
+	for _, path := range counts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var running int
+		if _, err := fmt.Sscan(string(data), &running); err != nil || running > concurrency {
+			t.Errorf("%d jobs running at the same time, want at most %d", running, concurrency)
+		}
+	}
+
+	// 8 jobs of 200ms, 3 at a time
+	if elapsed < 600*time.Millisecond {
+		t.Errorf("jobs completed in %s, want at least 600ms with %d jobs at a time", elapsed, concurrency)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	jobs := []*Job{shell("1", "sleep 10"), shell("2", "sleep 10"), shell("3", "sleep 10")}
+
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	results := run(ctx, jobs, 2, nil) // the last job is waiting for a slot when cancelled
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run returned after %s, want it interrupted", elapsed)
+	}
+
+	for _, job := range jobs {
+		result := results[job.ID]
+		if result == nil {
+			t.Fatalf("no result for job %s", job.ID)
+		}
+
+		if result.ExitCode != -1 || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("job %s result = exit %d, %v, want exit -1 with %v", job.ID, result.ExitCode, result.Err, context.Canceled)
+		}
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	type chunk struct {
+		id     string
+		data   string
+		stderr bool
+	}
+
+	var mutex sync.Mutex
+	chunks := []chunk{}
+	streamed := make(chan struct{})
+	once := sync.Once{}
+
+	output := func(job *Job, data []byte, stderr bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		chunks = append(chunks, chunk{job.ID, string(data), stderr})
+		once.Do(func() { close(streamed) })
+	}
+
+	// the first line is streamed before the command completes
+	job := shell("1", "echo first; echo oops >&2; while [ ! -f $0 ]; do sleep 0.01; done; echo last")
+	dir := t.TempDir()
+	release := filepath.Join(dir, "release")
+	job.Args = append(job.Args, release)
+
+	timedOut := make(chan bool, 1)
+	go func() {
+		select {
+		case <-streamed:
+			timedOut <- false
+		case <-time.After(5 * time.Second):
+			timedOut <- true
+		}
+		os.WriteFile(release, nil, 0o600)
+	}()
+
+	result := run(context.Background(), []*Job{job}, 1, output)["1"]
+	if result == nil || result.ExitCode != 0 {
+		t.Fatalf("result = %+v, want a success", result)
+	}
+
+	if <-timedOut {
+		t.Error("no output streamed before the command completed")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// stdout and stderr are read separately, only their own order is kept
+	stdout, stderr := "", ""
+	firstStdout := ""
+	for _, c := range chunks {
+		if c.id != "1" {
+			t.Errorf("chunk of job %s, want 1", c.id)
+		}
+
+		if c.stderr {
+			stderr += c.data
+		} else {
+			if len(stdout) == 0 {
+				firstStdout = c.data
+			}
+			stdout += c.data
+		}
+	}
+
+	if stdout != "first\nlast\n" || stderr != "oops\n" {
+		t.Errorf("streamed output = %q, %q, want %q, %q", stdout, stderr, "first\nlast\n", "oops\n")
+	}
+	if firstStdout != "first\n" {
+		t.Errorf("first stdout chunk = %q, want the first line streamed before the last one", firstStdout)
+	}
+
+	// the result has the whole output
+	if string(result.Stdout) != stdout || string(result.Stderr) != stderr {
+		t.Errorf("result output = %q, %q, want %q, %q", result.Stdout, result.Stderr, stdout, stderr)
+	}
+}
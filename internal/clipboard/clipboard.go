@@ -0,0 +1,66 @@
+// Package clipboard copies text to the system clipboard.
+package clipboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// commands are the clipboard tools tried in order
+var commands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// Copy copies the text with an OSC 52 escape sequence written to the terminal, which applies it to
+// the system clipboard (including over ssh and in tmux), the first clipboard tool found is used
+// instead when useCommand is set and the sequence can't be written (eg. terminal is nil)
+func Copy(terminal io.Writer, text string, useCommand bool) error {
+	err := errors.New("no terminal to send the OSC 52 sequence to")
+	if terminal != nil {
+		err = OSC52(terminal, text)
+	}
+
+	if err != nil && useCommand {
+		return Command(text)
+	}
+
+	return err
+}
+
+// OSC52 sends the text to the terminal clipboard
+func OSC52(terminal io.Writer, text string) error {
+	sequence := fmt.Sprintf("\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+
+	// tmux forwards the sequence to the outer terminal with set-clipboard on, or when wrapped
+	// in a passthrough sequence with allow-passthrough on
+	if len(os.Getenv("TMUX")) > 0 {
+		sequence += "\x1bPtmux;" + strings.ReplaceAll(sequence, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+
+	_, err := io.WriteString(terminal, sequence)
+	return err
+}
+
+// Command copies the text with the first clipboard tool found
+func Command(text string) error {
+	for _, command := range commands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+
+		return cmd.Run()
+	}
+
+	return errors.New("no clipboard tool found (pbcopy, wl-copy, xclip or xsel)")
+}
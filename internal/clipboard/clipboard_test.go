@@ -0,0 +1,122 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSC52(t *testing.T) {
+	tests := []struct {
+		name string
+		tmux string
+		text string
+		want string
+	}{
+		{"plain", "", "i-0123456789", "\x1b]52;c;aS0wMTIzNDU2Nzg5\x07"},
+		{"utf-8", "", "café", "\x1b]52;c;Y2Fmw6k=\x07"},
+		{"empty", "", "", "\x1b]52;c;\x07"},
+		{
+			"tmux passthrough",
+			"/tmp/tmux-1000/default,1234,0",
+			"10.0.0.1",
+			"\x1b]52;c;MTAuMC4wLjE=\x07" + "\x1bPtmux;\x1b\x1b]52;c;MTAuMC4wLjE=\x07\x1b\\",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TMUX", test.tmux)
+
+			terminal := &bytes.Buffer{}
+			if err := OSC52(terminal, test.text); err != nil {
+				t.Fatalf("OSC52() error = %v", err)
+			}
+
+			if got := terminal.String(); got != test.want {
+				t.Errorf("OSC52() wrote %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// failingTerminal can't be written to
+type failingTerminal struct{}
+
+func (failingTerminal) Write(p []byte) (int, error) {
+	return 0, errors.New("terminal closed")
+}
+
+// fakeCommand replaces the clipboard tools with a command writing the text to a file
+func fakeCommand(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "clipboard")
+	previous := commands
+	commands = [][]string{{"gosh-missing-clipboard-tool"}, {"sh", "-c", "cat > " + path}}
+	t.Cleanup(func() { commands = previous })
+
+	return path
+}
+
+func TestCopy(t *testing.T) {
+	t.Setenv("TMUX", "")
+
+	tests := []struct {
+		name        string
+		terminal    bool // a terminal receives the sequence
+		failing     bool // the terminal can't be written to
+		useCommand  bool
+		wantCommand bool
+		wantErr     bool
+	}{
+		{"terminal", true, false, false, false, false},
+		{"terminal with command", true, false, true, false, false},
+		{"no terminal", false, false, false, false, true},
+		{"no terminal with command", false, false, true, true, false},
+		{"failing terminal", true, true, false, false, true},
+		{"failing terminal with command", true, true, true, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := fakeCommand(t)
+
+			buffer := &bytes.Buffer{}
+			var terminal io.Writer
+			if test.terminal {
+				terminal = buffer
+				if test.failing {
+					terminal = failingTerminal{}
+				}
+			}
+
+			err := Copy(terminal, "i-1", test.useCommand)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Copy() error = %v, want error %t", err, test.wantErr)
+			}
+
+			copied, _ := os.ReadFile(path)
+			if got := string(copied) == "i-1"; got != test.wantCommand {
+				t.Errorf("command copied %q, want command used %t", copied, test.wantCommand)
+			}
+
+			if wantSequence := test.terminal && !test.failing; (buffer.Len() > 0) != wantSequence {
+				t.Errorf("terminal received %q, want sequence %t", buffer.String(), wantSequence)
+			}
+		})
+	}
+}
+
+func TestCommandNotFound(t *testing.T) {
+	previous := commands
+	commands = [][]string{{"gosh-missing-clipboard-tool"}}
+	t.Cleanup(func() { commands = previous })
+
+	if err := Command("i-1"); err == nil {
+		t.Error("Command() succeeded without clipboard tool, want an error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/yogin/gosh/internal/utils"
 	"gopkg.in/yaml.v3"
@@ -16,6 +17,7 @@ const (
 	DefaultConfigFile    = "gosh.yaml"           // DefaultConfigFile is the default configuration file name
 	CurrentConfigVersion = 1                     // CurrentConfigVersion is the current configuration version
 	DefaultTimeFormat    = "2006-01-02 15:04:05" // DefaultTimeFormat is the default time format
+	DefaultTimeout       = 30                    // DefaultTimeout is the default provider API timeout in seconds
 )
 
 var (
@@ -23,23 +25,89 @@ var (
 )
 
 type Config struct {
-	Version       int        `json:"version" yaml:"version"`
-	Profiles      []*Profile `json:"profiles" yaml:"profiles"`
-	ShowUTCTime   bool       `json:"show_utc_time" yaml:"show_utc_time"`     // show UTC time (default: false)
-	ShowLocalTime bool       `json:"show_local_time" yaml:"show_local_time"` // show local time (default: false)
-	TimeFormat    string     `json:"time_format" yaml:"time_format"`         // time format (default: "2006-01-02 15:04:05")
-	Developer     bool       `json:"developer" yaml:"developer"`             // developer mode (default: false)
+	Version          int        `json:"version" yaml:"version"`
+	Profiles         []*Profile `json:"profiles" yaml:"profiles"`
+	ShowUTCTime      bool       `json:"show_utc_time" yaml:"show_utc_time"`         // show UTC time (default: false)
+	ShowLocalTime    bool       `json:"show_local_time" yaml:"show_local_time"`     // show local time (default: false)
+	TimeFormat       string     `json:"time_format" yaml:"time_format"`             // time format (default: "2006-01-02 15:04:05")
+	Developer        bool       `json:"developer" yaml:"developer"`                 // developer mode (default: false)
+	ClipboardCommand bool       `json:"clipboard_command" yaml:"clipboard_command"` // copy with pbcopy, wl-copy, xclip or xsel when found and the OSC 52 sequence can't be sent to the terminal (default: false)
 
 	configPath string
 }
 
 type Profile struct {
-	ID             string  `json:"id" yaml:"id"`                             // profile id (unique, used for navigation)
-	Provider       string  `json:"provider" yaml:"provider"`                 // aws, gcp, azure (only aws is supported for now)
-	Name           string  `json:"name" yaml:"name"`                         // provider profile name (eg. aws profile name)
-	Region         string  `json:"region" yaml:"region"`                     // region (us-west-1, us-east-1, etc)
-	PreferPublicIP bool    `json:"prefer_public_ip" yaml:"prefer_public_ip"` // prefer public IP over private IP (default: false)
-	Refresh        Refresh `json:"refresh" yaml:"refresh"`                   // auto refresh settings
+	ID             string              `json:"id" yaml:"id"`                                               // profile id (unique, used for navigation)
+	Provider       string              `json:"provider" yaml:"provider"`                                   // aws, gcp, azure, digitalocean, hetzner, linode, file, ansible
+	Name           string              `json:"name" yaml:"name"`                                           // provider profile name (eg. aws profile name)
+	Region         string              `json:"region" yaml:"region"`                                       // region (us-west-1, us-east-1, etc)
+	Project        string              `json:"project,omitempty" yaml:"project,omitempty"`                 // gcp project or azure subscription id (default: from the credentials or environment variables)
+	ResourceGroups []string            `json:"resource_groups,omitempty" yaml:"resource_groups,omitempty"` // azure resource groups listed (default: all the subscription)
+	Credentials    string              `json:"credentials,omitempty" yaml:"credentials,omitempty"`         // credentials file (eg. gcp service account or azure service principal JSON, api token file, default: provider CLI or environment credentials)
+	Endpoint       string              `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`               // provider API endpoint (default: the provider public endpoint)
+	Inventory      string              `json:"inventory,omitempty" yaml:"inventory,omitempty"`             // inventory of the file (YAML, JSON or CSV file) and ansible (INI or YAML file, directory or script) providers
+	PreferPublicIP bool                `json:"prefer_public_ip" yaml:"prefer_public_ip"`                   // prefer public IP over private IP (default: false)
+	Refresh        Refresh             `json:"refresh" yaml:"refresh"`                                     // auto refresh settings
+	Filter         string              `json:"filter,omitempty" yaml:"filter,omitempty"`                   // filter expression always applied to the instances (eg. state=running tag:env=prod)
+	Columns        []*Column           `json:"columns,omitempty" yaml:"columns,omitempty"`                 // columns displayed in order (default: provider tags and fields)
+	Sort           *Sort               `json:"sort,omitempty" yaml:"sort,omitempty"`                       // instances sort order (default: tags then id)
+	APIFilters     map[string][]string `json:"api_filters,omitempty" yaml:"api_filters,omitempty"`         // server-side filters sent to the provider API (eg. instance-state-name: [running])
+	Timeout        int                 `json:"timeout,omitempty" yaml:"timeout,omitempty"`                 // provider API timeout in seconds (default: 30)
+	ReadOnly       bool                `json:"read_only,omitempty" yaml:"read_only,omitempty"`             // disable the lifecycle actions changing the state of instances (default: false)
+	Connect        Connect             `json:"connect,omitempty" yaml:"connect,omitempty"`                 // connection settings
+	Tunnels        []*Tunnel           `json:"tunnels,omitempty" yaml:"tunnels,omitempty"`                 // port forwarding presets, opened on the selected instance
+}
+
+type Tunnel struct {
+	Name    string `json:"name" yaml:"name"`                   // preset name, displayed in the tunnels page
+	Forward string `json:"forward" yaml:"forward"`             // local->host:port, the host is resolved from the instance (eg. 5432->db.internal:5432)
+	Key     string `json:"key,omitempty" yaml:"key,omitempty"` // key opening the tunnel from the instances list (eg. P)
+}
+
+type Connect struct {
+	Method       string   `json:"method,omitempty" yaml:"method,omitempty"`               // ssh (default), ssm (aws ssm start-session) or ssh-ssm (ssh through an ssm session)
+	Command      string   `json:"command,omitempty" yaml:"command,omitempty"`             // command template (Go text/template), eg. ssh -l {{or .Tags.user "ec2-user"}} {{.IP}}
+	User         string   `json:"user,omitempty" yaml:"user,omitempty"`                   // ssh user (default: ssh client configuration)
+	Port         int      `json:"port,omitempty" yaml:"port,omitempty"`                   // ssh port (default: ssh client configuration)
+	IdentityFile string   `json:"identity_file,omitempty" yaml:"identity_file,omitempty"` // ssh private key (default: ssh client configuration)
+	Args         []string `json:"args,omitempty" yaml:"args,omitempty"`                   // extra ssh arguments
+	Bastions     []*Hop   `json:"bastions,omitempty" yaml:"bastions,omitempty"`           // jump hosts (ssh -J) in order, overridden by the gosh:bastion instance tag
+	Parallel     int      `json:"parallel,omitempty" yaml:"parallel,omitempty"`           // number of commands running at the same time on marked instances (default: 10)
+
+	SynchronizePanes bool `json:"synchronize_panes,omitempty" yaml:"synchronize_panes,omitempty"` // send the input to all the tmux panes of marked instances (default: false)
+
+	InstanceConnect    bool   `json:"instance_connect,omitempty" yaml:"instance_connect,omitempty"`         // push a temporary key with EC2 Instance Connect before connecting (default: false)
+	InstanceConnectKey string `json:"instance_connect_key,omitempty" yaml:"instance_connect_key,omitempty"` // private key used with EC2 Instance Connect, generated if missing (default: ~/.gosh/instance_connect_rsa)
+}
+
+const (
+	ConnectMethodSSH    = "ssh"     // ConnectMethodSSH connects with ssh (default)
+	ConnectMethodSSM    = "ssm"     // ConnectMethodSSM connects with an AWS SSM session
+	ConnectMethodSSHSSM = "ssh-ssm" // ConnectMethodSSHSSM connects with ssh through an AWS SSM session
+)
+
+// UsesSSM indicates if the connection method relies on AWS SSM
+func (c *Connect) UsesSSM() bool {
+	return c.Method == ConnectMethodSSM || c.Method == ConnectMethodSSHSSM
+}
+
+type Hop struct {
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`         // static host ([user@]host[:port])
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"` // filter expression to find the host in the profile instances (eg. tag:role=bastion)
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`         // ssh user (used with selector)
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`         // ssh port (used with selector)
+}
+
+type Sort struct {
+	Field      string `json:"field" yaml:"field"`                               // instance field or tag (tag:<name>) to sort on
+	Descending bool   `json:"descending,omitempty" yaml:"descending,omitempty"` // sort in descending order (default: false)
+}
+
+type Column struct {
+	Field string `json:"field" yaml:"field"`                     // instance field (id, private_ip, public_ip, state, az, type, ami, running, launched) or tag (tag:<name>)
+	Label string `json:"label,omitempty" yaml:"label,omitempty"` // header label (default: based on the field name)
+	Width int    `json:"width,omitempty" yaml:"width,omitempty"` // maximum width (default: 0, no limit)
+	Align string `json:"align,omitempty" yaml:"align,omitempty"` // left, center, right (default: left)
 }
 
 type Refresh struct {
@@ -47,6 +115,15 @@ type Refresh struct {
 	Interval int  `json:"interval" yaml:"interval"` // refresh interval in seconds (default: 60)
 }
 
+// GetTimeout returns the provider API timeout
+func (p *Profile) GetTimeout() time.Duration {
+	if p.Timeout <= 0 {
+		return DefaultTimeout * time.Second
+	}
+
+	return time.Duration(p.Timeout) * time.Second
+}
+
 func NewConfig(path *string) *Config {
 	if config != nil {
 		return config
@@ -137,17 +214,45 @@ func (c *Config) loadJSONConfigFile() error {
 }
 
 func (c *Config) loadConfigFile() error {
+	var err error
+
+	switch {
 	// try to load yaml configuration file
-	if strings.HasSuffix(c.configPath, ".yaml") || strings.HasSuffix(c.configPath, ".yml") {
-		return c.loadYAMLConfigFile()
-	}
+	case strings.HasSuffix(c.configPath, ".yaml") || strings.HasSuffix(c.configPath, ".yml"):
+		err = c.loadYAMLConfigFile()
 
 	// try to load json configuration file
-	if strings.HasSuffix(c.configPath, ".json") {
-		return c.loadJSONConfigFile()
+	case strings.HasSuffix(c.configPath, ".json"):
+		err = c.loadJSONConfigFile()
+
+	default:
+		return errors.New("unsupported configuration file format")
 	}
 
-	return errors.New("unsupported configuration file format")
+	if err != nil {
+		return err
+	}
+
+	return c.validate()
+}
+
+// validate checks the settings that can't be ignored at run time (eg. a tunnel preset key of several
+// characters would never open), the keys already bound in the instances list are checked when gosh
+// starts
+func (c *Config) validate() error {
+	for _, profile := range c.Profiles {
+		for _, preset := range profile.Tunnels {
+			if len(preset.Key) == 0 {
+				continue
+			}
+
+			if len([]rune(preset.Key)) != 1 {
+				return fmt.Errorf("profile '%s': tunnel '%s' key '%s' must be a single character", profile.ID, preset.Name, preset.Key)
+			}
+		}
+	}
+
+	return nil
 }
 
 func (c *Config) findConfigFile() bool {
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFileTunnelKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{"free key", "P", ""},
+		{"no key", "", ""},
+		{"bound key", "c", ""}, // checked when gosh starts
+		{"several characters", "Pg", "key 'Pg' must be a single character"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gosh.yaml")
+			data := "profiles:\n  - id: usw1\n    tunnels:\n      - name: postgres\n        forward: 5432->db.internal:5432\n        key: \"" + test.key + "\"\n"
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			c := &Config{configPath: path}
+			err := c.loadConfigFile()
+
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Errorf("loadConfigFile() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) || !strings.HasPrefix(err.Error(), "profile 'usw1': tunnel 'postgres'") {
+				t.Errorf("loadConfigFile() error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package connect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/filter"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+const (
+	BastionTag  = "gosh:bastion" // BastionTag overrides the profile bastions for an instance (comma separated hops, or "none")
+	BastionNone = "none"         // BastionNone disables bastions for an instance
+)
+
+// Jump returns the jump hosts to reach the instance (ssh -J value), or an empty string to connect directly
+func (t *Target) Jump() (string, error) {
+	hops := t.Profile.Connect.Bastions
+
+	if value, ok := t.Instance.Tags[BastionTag]; ok {
+		hops = ParseHops(value)
+	}
+
+	hosts := []string{}
+	for _, hop := range hops {
+		host, err := t.resolveHop(hop, len(hosts) == 0)
+		if err != nil {
+			return "", err
+		}
+
+		// the instance can be its own bastion (eg. bastion selector matching itself)
+		if len(host) == 0 {
+			continue
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return strings.Join(hosts, ","), nil
+}
+
+// ParseHops parses the value of the bastion tag: comma separated static hosts ([user@]host[:port])
+// or selectors (filter expressions, eg. tag:role=bastion), or "none" to connect directly
+func ParseHops(value string) []*config.Hop {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 || strings.EqualFold(value, BastionNone) {
+		return nil
+	}
+
+	hops := []*config.Hop{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		if strings.ContainsAny(part, "=~<>") {
+			hops = append(hops, &config.Hop{Selector: part})
+		} else {
+			hops = append(hops, &config.Hop{Host: part})
+		}
+	}
+
+	return hops
+}
+
+// resolveHop returns the [user@]host[:port] of a hop, the first hop is reached from the local
+// machine and the next ones from the previous hop
+func (t *Target) resolveHop(hop *config.Hop, first bool) (string, error) {
+	if len(hop.Host) > 0 {
+		return hop.Host, nil
+	}
+
+	if len(hop.Selector) == 0 {
+		return "", fmt.Errorf("bastion requires a host or a selector")
+	}
+
+	bastion, err := t.findBastion(hop.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	if bastion.ID == t.Instance.ID {
+		return "", nil
+	}
+
+	// the first bastion is usually reached through its public IP, the next ones from inside the
+	// network through their private IP
+	host := bastion.PrivateIP
+	if (first && len(bastion.PublicIP) > 0) || len(host) == 0 {
+		host = bastion.PublicIP
+	}
+
+	if len(hop.User) > 0 {
+		host = hop.User + "@" + host
+	}
+
+	if hop.Port > 0 {
+		host += ":" + strconv.Itoa(hop.Port)
+	}
+
+	return host, nil
+}
+
+// findBastion returns the running instance matching the selector, preferring the
+// instances in the same VPC then the same AZ as the target
+func (t *Target) findBastion(selector string) (*providers.Instance, error) {
+	f, err := filter.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bastion selector: %w", err)
+	}
+
+	candidates := []*providers.Instance{}
+	for _, instance := range t.Inventory {
+		if instance.State != "running" || !f.Match(instance) {
+			continue
+		}
+
+		if len(instance.PublicIP) == 0 && len(instance.PrivateIP) == 0 {
+			continue
+		}
+
+		candidates = append(candidates, instance)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no running bastion found for '%s'", selector)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := t.affinity(candidates[i]), t.affinity(candidates[j])
+		if si != sj {
+			return si > sj
+		}
+
+		return candidates[i].ID < candidates[j].ID
+	})
+
+	return candidates[0], nil
+}
+
+// affinity scores how close a bastion is to the target
+func (t *Target) affinity(bastion *providers.Instance) int {
+	score := 0
+
+	if len(t.Instance.VPC) > 0 && bastion.VPC == t.Instance.VPC {
+		score += 2
+	}
+
+	if bastion.AZ == t.Instance.AZ {
+		score++
+	}
+
+	return score
+}
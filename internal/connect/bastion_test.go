@@ -0,0 +1,203 @@
+package connect
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// bastionInventory returns the instances of a profile with bastions in two VPCs and AZs
+func bastionInventory() []*providers.Instance {
+	bastion := func(id string, role string, vpc string, az string, publicIP string, privateIP string) *providers.Instance {
+		return &providers.Instance{
+			ID:        id,
+			State:     "running",
+			VPC:       vpc,
+			AZ:        az,
+			PublicIP:  publicIP,
+			PrivateIP: privateIP,
+			Tags:      map[string]string{"role": role},
+		}
+	}
+
+	stopped := bastion("i-stopped", "bastion", "vpc-1", "us-west-2a", "54.0.0.9", "10.1.0.9")
+	stopped.State = "stopped"
+
+	return []*providers.Instance{
+		bastion("i-b1", "bastion", "vpc-2", "us-west-2a", "54.0.0.1", "10.2.0.1"),
+		bastion("i-b2", "bastion", "vpc-1", "us-west-2b", "54.0.0.2", "10.1.0.2"),
+		bastion("i-b3", "bastion", "vpc-1", "us-west-2a", "54.0.0.3", "10.1.0.3"),
+		bastion("i-db", "bastion-db", "vpc-1", "us-west-2a", "54.0.0.4", "10.1.0.4"),
+		bastion("i-private", "bastion-db", "vpc-1", "us-west-2b", "", "10.1.0.5"),
+		stopped,
+	}
+}
+
+func TestJump(t *testing.T) {
+	tests := []struct {
+		name     string
+		bastions []*config.Hop
+		tag      string // gosh:bastion tag of the instance, not set when empty
+		vpc      string
+		az       string
+		want     string
+		err      string
+	}{
+		{
+			name: "no bastions",
+			vpc:  "vpc-1",
+			az:   "us-west-2a",
+			want: "",
+		},
+		{
+			name:     "static host",
+			bastions: []*config.Hop{{Host: "ops@bastion.example.com:2222"}},
+			want:     "ops@bastion.example.com:2222",
+		},
+		{
+			name:     "same vpc and az",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}},
+			vpc:      "vpc-1",
+			az:       "us-west-2a",
+			want:     "54.0.0.3",
+		},
+		{
+			name:     "same vpc before same az",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}},
+			vpc:      "vpc-1",
+			az:       "us-west-2c",
+			want:     "54.0.0.2",
+		},
+		{
+			name:     "same az",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}},
+			vpc:      "vpc-3",
+			az:       "us-west-2a",
+			want:     "54.0.0.1",
+		},
+		{
+			name:     "user and port",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion", User: "ops", Port: 2222}},
+			vpc:      "vpc-1",
+			az:       "us-west-2a",
+			want:     "ops@54.0.0.3:2222",
+		},
+		{
+			name:     "multiple hops",
+			bastions: []*config.Hop{{Host: "gateway.example.com"}, {Selector: "tag:role=bastion"}, {Selector: "id=i-private", User: "ops"}},
+			vpc:      "vpc-1",
+			az:       "us-west-2a",
+			want:     "gateway.example.com,10.1.0.3,ops@10.1.0.5",
+		},
+		{
+			name:     "first selector hop",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}, {Selector: "tag:role=bastion-db"}},
+			vpc:      "vpc-1",
+			az:       "us-west-2a",
+			want:     "54.0.0.3,10.1.0.4",
+		},
+		{
+			name:     "first hop without public ip",
+			bastions: []*config.Hop{{Selector: "id=i-private"}},
+			want:     "10.1.0.5",
+		},
+		{
+			name:     "tag override",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}},
+			tag:      "tag:role=bastion-db, jump.example.com",
+			vpc:      "vpc-1",
+			az:       "us-west-2a",
+			want:     "54.0.0.4,jump.example.com",
+		},
+		{
+			name:     "tag none",
+			bastions: []*config.Hop{{Selector: "tag:role=bastion"}},
+			tag:      "none",
+			want:     "",
+		},
+		{
+			name:     "no running bastion",
+			bastions: []*config.Hop{{Selector: "tag:role=missing"}},
+			err:      "no running bastion found for 'tag:role=missing'",
+		},
+		{
+			name:     "invalid selector",
+			bastions: []*config.Hop{{Selector: "colour=red"}},
+			err:      "invalid bastion selector",
+		},
+		{
+			name:     "empty hop",
+			bastions: []*config.Hop{{User: "ops"}},
+			err:      "bastion requires a host or a selector",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := &providers.Instance{ID: "i-target", State: "running", VPC: test.vpc, AZ: test.az, PrivateIP: "10.1.1.1", Tags: map[string]string{}}
+			if len(test.tag) > 0 {
+				instance.Tags[BastionTag] = test.tag
+			}
+
+			profile := &config.Profile{Connect: config.Connect{Bastions: test.bastions}}
+			target := NewTarget(profile, instance, instance.PrivateIP, append(bastionInventory(), instance))
+
+			got, err := target.Jump()
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Jump() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Jump() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Jump() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJumpSkipsTarget(t *testing.T) {
+	inventory := bastionInventory()
+
+	// a bastion connecting through the bastions (eg. tag:role=bastion in the profile)
+	profile := &config.Profile{Connect: config.Connect{Bastions: []*config.Hop{{Selector: "tag:role=bastion"}, {Selector: "tag:role=bastion-db"}}}}
+	target := NewTarget(profile, inventory[2], inventory[2].PrivateIP, inventory)
+
+	got, err := target.Jump()
+	if err != nil {
+		t.Fatalf("Jump() error = %v", err)
+	}
+
+	// the next hop is reached directly, through its public IP
+	if want := "54.0.0.4"; got != want {
+		t.Errorf("Jump() = %q, want %q", got, want)
+	}
+}
+
+func TestParseHops(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []*config.Hop
+	}{
+		{"", nil},
+		{" none ", nil},
+		{"NONE", nil},
+		{"bastion.example.com", []*config.Hop{{Host: "bastion.example.com"}}},
+		{"ops@bastion:2222, tag:role=bastion", []*config.Hop{{Host: "ops@bastion:2222"}, {Selector: "tag:role=bastion"}}},
+		{"az~^us-west,, name!=old", []*config.Hop{{Selector: "az~^us-west"}, {Selector: "name!=old"}}},
+		{"launched>7d", []*config.Hop{{Selector: "launched>7d"}}},
+	}
+
+	for _, test := range tests {
+		if got := ParseHops(test.value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseHops(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package connect resolves the commands used to connect to instances.
+package connect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+	"github.com/yogin/gosh/internal/utils"
+)
+
+// TemplateData is available to the connect command templates, along with all the instance fields
+type TemplateData struct {
+	*providers.Instance
+
+	IP           string // IP to connect to (private or public depending on the profile)
+	User         string // user from the instance (eg. inventory files) or the profile connect settings
+	Port         int    // port from the instance (eg. inventory files) or the profile connect settings
+	IdentityFile string // identity file from the profile connect settings
+	Jump         string // jump hosts (ssh -J value), empty when connecting directly
+}
+
+// Target is an instance to connect to
+type Target struct {
+	Profile   *config.Profile
+	Instance  *providers.Instance
+	IP        string                // IP to connect to (private or public depending on the profile)
+	Inventory []*providers.Instance // instances of the same profile, used to resolve bastions
+
+	User         string // overrides the profile connect user
+	IdentityFile string // overrides the profile connect identity file
+}
+
+func NewTarget(profile *config.Profile, instance *providers.Instance, ip string, inventory []*providers.Instance) *Target {
+	return &Target{
+		Profile:   profile,
+		Instance:  instance,
+		IP:        ip,
+		Inventory: inventory,
+	}
+}
+
+// Command returns the command (program and arguments) to connect to the instance
+func (t *Target) Command() ([]string, error) {
+	settings := t.Profile.Connect
+
+	if len(settings.Command) == 0 {
+		if args, ok, err := t.methodCommand(); ok || err != nil {
+			return args, err
+		}
+	}
+
+	jump, err := t.Jump()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(settings.Command) == 0 {
+		return append([]string{"ssh"}, t.SSHArgs(jump)...), nil
+	}
+
+	data := TemplateData{
+		Instance:     t.Instance,
+		IP:           t.IP,
+		User:         t.user(),
+		Port:         t.port(),
+		IdentityFile: t.identityFile(),
+		Jump:         jump,
+	}
+
+	line, err := render(settings.Command, data)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := SplitArgs(line)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connect command '%s': %w", line, err)
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("connect command is empty")
+	}
+
+	// add the jump hosts to ssh commands that don't already use them
+	if len(jump) > 0 && filepath.Base(args[0]) == "ssh" && !contains(args, "-J") && !strings.Contains(settings.Command, ".Jump") {
+		args = append([]string{args[0], "-J", jump}, args[1:]...)
+	}
+
+	return expandHome(args), nil
+}
+
+// SSHOptions returns the ssh options from the profile settings (user, port, identity file,
+// jump hosts and extra arguments), without the destination
+func (t *Target) SSHOptions(jump string) []string {
+	settings := t.Profile.Connect
+	args := []string{}
+
+	if user := t.user(); len(user) > 0 {
+		args = append(args, "-l", user)
+	}
+
+	if port := t.port(); port > 0 {
+		args = append(args, "-p", strconv.Itoa(port))
+	}
+
+	if identity := t.identityFile(); len(identity) > 0 {
+		args = append(args, "-i", identity)
+	}
+
+	if len(jump) > 0 {
+		args = append(args, "-J", jump)
+	}
+
+	args = append(args, settings.Args...)
+
+	return expandHome(args)
+}
+
+// SSHArgs returns the ssh arguments from the profile settings followed by the destination
+func (t *Target) SSHArgs(jump string) []string {
+	return append(t.SSHOptions(jump), t.IP)
+}
+
+func (t *Target) user() string {
+	if len(t.User) > 0 {
+		return t.User
+	}
+
+	if len(t.Instance.User) > 0 {
+		return t.Instance.User
+	}
+
+	return t.Profile.Connect.User
+}
+
+func (t *Target) port() int {
+	if t.Instance.Port > 0 {
+		return t.Instance.Port
+	}
+
+	return t.Profile.Connect.Port
+}
+
+func (t *Target) identityFile() string {
+	if len(t.IdentityFile) > 0 {
+		return t.IdentityFile
+	}
+
+	return t.Profile.Connect.IdentityFile
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func render(command string, data TemplateData) (string, error) {
+	tmpl, err := template.New("connect").Option("missingkey=zero").Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("invalid connect command template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("unable to render connect command: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// SplitArgs splits a command line into arguments, supporting single and double quotes and
+// backslash escapes (the command is not run through a shell)
+func SplitArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quoted string")
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// expandHome replaces ~ or a leading ~/ of the arguments with the user's home directory, as a shell would
+func expandHome(args []string) []string {
+	for i, arg := range args {
+		args[i] = utils.ExpandHome(arg)
+	}
+
+	return args
+}
+
+// UseInstanceConnect generates (or reuses) the EC2 Instance Connect key pair, and sets the target
+// user and identity file to use it, returns the user and public key to push
+func (t *Target) UseInstanceConnect() (user string, publicKey string, err error) {
+	key := t.Profile.Connect.InstanceConnectKey
+	if len(key) == 0 {
+		key = DefaultInstanceConnectKey
+	}
+
+	publicKey, err = EnsureKeyPair(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	user = t.user()
+	if len(user) == 0 {
+		user = DefaultInstanceConnectUser
+	}
+
+	t.User = user
+	t.IdentityFile = key
+
+	return user, publicKey, nil
+}
+
+// ExecCommand returns the non-interactive command (program and arguments) running the shell
+// command on the instance
+func (t *Target) ExecCommand(command string) ([]string, error) {
+	if t.Profile.Connect.Method == config.ConnectMethodSSM {
+		return nil, fmt.Errorf("running commands is not supported with the '%s' connect method (use '%s')", config.ConnectMethodSSM, config.ConnectMethodSSHSSM)
+	}
+
+	args, err := t.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	// never prompt for passwords or host keys, there is no terminal
+	if filepath.Base(args[0]) == "ssh" {
+		args = append([]string{args[0], "-T", "-o", "BatchMode=yes"}, args[1:]...)
+	}
+
+	return append(args, command), nil
+}
@@ -0,0 +1,207 @@
+package connect
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const (
+	Upload   = "upload"   // Upload copies local files to the instance
+	Download = "download" // Download copies files from the instance
+)
+
+// CopyCommand returns the scp command copying files to (upload) or from (download) the instance,
+// using the same user, identity file, bastions and connect method (or command template) as ssh
+func (t *Target) CopyCommand(direction string, local string, remote string) ([]string, error) {
+	var options []string
+	var host string
+	var err error
+
+	if len(t.Profile.Connect.Command) > 0 {
+		options, host, err = t.templateCopyOptions()
+	} else {
+		options, host, err = t.copyOptions()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"scp", "-r", "-q", "-o", "BatchMode=yes"}, options...)
+
+	// IPv6 addresses must be enclosed in brackets
+	user, address := "", host
+	if idx := strings.LastIndex(host, "@"); idx >= 0 {
+		user, address = host[:idx+1], host[idx+1:]
+	}
+	if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+
+	remote = user + address + ":" + remote
+
+	switch direction {
+	case Upload:
+		args = append(args, local, remote)
+	case Download:
+		args = append(args, remote, local)
+	default:
+		return nil, fmt.Errorf("unsupported transfer direction '%s'", direction)
+	}
+
+	return expandHome(args), nil
+}
+
+// copyOptions returns the scp options and destination ([user@]host) from the profile connect
+// settings
+func (t *Target) copyOptions() ([]string, string, error) {
+	host := t.IP
+	args := []string{}
+
+	switch t.Profile.Connect.Method {
+	case "", config.ConnectMethodSSH:
+		jump, err := t.Jump()
+		if err != nil {
+			return nil, "", err
+		}
+
+		if len(jump) > 0 {
+			args = append(args, "-o", "ProxyJump="+jump)
+		}
+
+	case config.ConnectMethodSSHSSM:
+		args = append(args, "-o", t.ssmProxyCommand())
+		host = t.Instance.ID
+
+	default:
+		return nil, "", fmt.Errorf("file transfers are not supported with the '%s' connect method", t.Profile.Connect.Method)
+	}
+
+	args = append(args, t.SCPOptions()...)
+
+	if user := t.user(); len(user) > 0 {
+		host = user + "@" + host
+	}
+
+	return args, host, nil
+}
+
+// SCPOptions returns the scp options from the profile settings (port, identity file and the -o
+// options of the extra arguments), scp uses -P for the port and doesn't support -l for the user,
+// the other ssh arguments are ignored as they don't have the same meaning for scp (eg. -t)
+func (t *Target) SCPOptions() []string {
+	settings := t.Profile.Connect
+	args := []string{}
+
+	if port := t.port(); port > 0 {
+		args = append(args, "-P", strconv.Itoa(port))
+	}
+
+	if identity := t.identityFile(); len(identity) > 0 {
+		args = append(args, "-i", identity)
+	}
+
+	for idx := 0; idx < len(settings.Args); idx++ {
+		arg := settings.Args[idx]
+
+		switch {
+		case arg == "-o" && idx+1 < len(settings.Args):
+			args = append(args, "-o", settings.Args[idx+1])
+			idx++
+		case strings.HasPrefix(arg, "-o") && len(arg) > 2:
+			args = append(args, "-o", arg[2:])
+		}
+	}
+
+	return args
+}
+
+const (
+	sshValueFlags   = "lpiJoFc"         // ssh options with a value, converted to scp ones
+	sshCopyFlags    = "46C"             // ssh flags with the same meaning for scp
+	sshSessionFlags = "tTnNxXYaAkKgqvf" // ssh flags only used by interactive sessions, ignored by transfers
+)
+
+// templateCopyOptions returns the scp options and destination ([user@]host) of the command rendered
+// from the connect command template, which must be an ssh command without a remote command
+func (t *Target) templateCopyOptions() ([]string, string, error) {
+	command, err := t.Command()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if filepath.Base(command[0]) != "ssh" {
+		return nil, "", fmt.Errorf("file transfers need an ssh connect command, the connect command runs '%s'", command[0])
+	}
+
+	args := []string{}
+	user, host := "", ""
+
+	for idx := 1; idx < len(command); idx++ {
+		arg := command[idx]
+
+		if len(host) > 0 {
+			return nil, "", fmt.Errorf("file transfers can't use a connect command running a remote command ('%s')", strings.Join(command[idx:], " "))
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			host = arg
+			continue
+		}
+
+		// flags can be combined (eg. -tt or -4p 2222)
+		for pos := 1; pos < len(arg); pos++ {
+			flag := arg[pos]
+
+			switch {
+			case strings.IndexByte(sshValueFlags, flag) >= 0:
+				value := arg[pos+1:]
+				if len(value) == 0 {
+					if idx+1 >= len(command) {
+						return nil, "", fmt.Errorf("missing value of the ssh option -%c of the connect command", flag)
+					}
+					idx++
+					value = command[idx]
+				}
+				pos = len(arg) // the rest of the argument is the value
+
+				switch flag {
+				case 'l':
+					user = value
+				case 'p':
+					args = append(args, "-P", value)
+				case 'J':
+					args = append(args, "-o", "ProxyJump="+value)
+				default:
+					args = append(args, "-"+string(flag), value)
+				}
+
+			case strings.IndexByte(sshCopyFlags, flag) >= 0:
+				args = append(args, "-"+string(flag))
+
+			case strings.IndexByte(sshSessionFlags, flag) >= 0:
+				// terminal, forwarding and verbosity flags of interactive sessions
+
+			default:
+				return nil, "", fmt.Errorf("the ssh option -%c of the connect command can't be used with scp", flag)
+			}
+		}
+	}
+
+	if len(host) == 0 {
+		return nil, "", fmt.Errorf("no destination found in the connect command '%s'", strings.Join(command, " "))
+	}
+
+	if strings.Contains(host, "://") {
+		return nil, "", fmt.Errorf("file transfers don't support the destination URI of the connect command ('%s')", host)
+	}
+
+	if len(user) > 0 && !strings.Contains(host, "@") {
+		host = user + "@" + host
+	}
+
+	return args, host, nil
+}
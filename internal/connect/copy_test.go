@@ -0,0 +1,154 @@
+package connect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+func TestCopyCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		connect   config.Connect
+		instance  providers.Instance
+		direction string
+		want      string
+		err       string
+	}{
+		{
+			name:      "upload",
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes ./app 10.0.0.1:/tmp",
+		},
+		{
+			name:      "download",
+			direction: Download,
+			want:      "scp -r -q -o BatchMode=yes 10.0.0.1:/tmp ./app",
+		},
+		{
+			name:      "profile settings",
+			connect:   config.Connect{User: "ubuntu", Port: 2222, IdentityFile: "/keys/id", Bastions: []*config.Hop{{Host: "bastion.example.com"}}},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -o ProxyJump=bastion.example.com -P 2222 -i /keys/id ./app ubuntu@10.0.0.1:/tmp",
+		},
+		{
+			name:      "instance user and port",
+			connect:   config.Connect{User: "ubuntu", Port: 2222},
+			instance:  providers.Instance{User: "admin", Port: 22},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -P 22 ./app admin@10.0.0.1:/tmp",
+		},
+		{
+			name:      "only -o extra arguments",
+			connect:   config.Connect{Args: []string{"-t", "-o", "StrictHostKeyChecking=accept-new", "-A", "-oConnectTimeout=5", "-L", "8080:localhost:80"}},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -o StrictHostKeyChecking=accept-new -o ConnectTimeout=5 ./app 10.0.0.1:/tmp",
+		},
+		{
+			name:      "ssh over ssm",
+			connect:   config.Connect{Method: config.ConnectMethodSSHSSM, User: "ec2-user"},
+			instance:  providers.Instance{ID: "i-123"},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -o ProxyCommand=aws ssm start-session --target %h --document-name AWS-StartSSHSession --parameters portNumber=%p ./app ec2-user@i-123:/tmp",
+		},
+		{
+			name:      "ssm",
+			connect:   config.Connect{Method: config.ConnectMethodSSM},
+			direction: Upload,
+			err:       "not supported with the 'ssm' connect method",
+		},
+		{
+			name:      "template",
+			connect:   config.Connect{Command: `ssh -tt -A -l {{or .Tags.user "ec2-user"}} -p 2200 -o ServerAliveInterval=30 {{.IP}}`},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -P 2200 -o ServerAliveInterval=30 ./app ec2-user@10.0.0.1:/tmp",
+		},
+		{
+			name:      "template destination user",
+			connect:   config.Connect{Command: `ssh -4 -i /keys/{{.Tags.env}} -J jump.example.com admin@{{.IP}}`},
+			instance:  providers.Instance{Tags: map[string]string{"env": "prod"}},
+			direction: Download,
+			want:      "scp -r -q -o BatchMode=yes -4 -i /keys/prod -o ProxyJump=jump.example.com admin@10.0.0.1:/tmp ./app",
+		},
+		{
+			name:      "template combined flags",
+			connect:   config.Connect{Command: `ssh -4p2200 {{.IP}}`},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -4 -P 2200 ./app 10.0.0.1:/tmp",
+		},
+		{
+			name:      "template with bastions",
+			connect:   config.Connect{Command: `ssh {{.IP}}`, Bastions: []*config.Hop{{Host: "bastion"}}},
+			direction: Upload,
+			want:      "scp -r -q -o BatchMode=yes -o ProxyJump=bastion ./app 10.0.0.1:/tmp",
+		},
+		{
+			name:      "template not ssh",
+			connect:   config.Connect{Command: `mosh {{.IP}}`},
+			direction: Upload,
+			err:       "file transfers need an ssh connect command, the connect command runs 'mosh'",
+		},
+		{
+			name:      "template remote command",
+			connect:   config.Connect{Command: `ssh -t {{.IP}} sudo -i`},
+			direction: Upload,
+			err:       "running a remote command ('sudo -i')",
+		},
+		{
+			name:      "template unsupported option",
+			connect:   config.Connect{Command: `ssh -L 8080:localhost:80 {{.IP}}`},
+			direction: Upload,
+			err:       "the ssh option -L of the connect command can't be used with scp",
+		},
+		{
+			name:      "template missing destination",
+			connect:   config.Connect{Command: `ssh -l admin`},
+			direction: Upload,
+			err:       "no destination found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := test.instance
+			if len(instance.ID) == 0 {
+				instance.ID = "i-1"
+			}
+
+			profile := &config.Profile{ID: "test", Connect: test.connect}
+			target := NewTarget(profile, &instance, "10.0.0.1", nil)
+
+			args, err := target.CopyCommand(test.direction, "./app", "/tmp")
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("CopyCommand() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("CopyCommand() error = %v", err)
+			}
+
+			if got := strings.Join(args, " "); got != test.want {
+				t.Errorf("CopyCommand() = %s\n want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCopyCommandIPv6(t *testing.T) {
+	profile := &config.Profile{ID: "test", Connect: config.Connect{User: "admin"}}
+	target := NewTarget(profile, &providers.Instance{ID: "i-1"}, "fd00::1", nil)
+
+	args, err := target.CopyCommand(Upload, "./app", "/tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := args[len(args)-1]; got != "admin@[fd00::1]:/tmp" {
+		t.Errorf("destination = %s, want admin@[fd00::1]:/tmp", got)
+	}
+}
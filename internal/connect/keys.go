@@ -0,0 +1,42 @@
+package connect
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/yogin/gosh/internal/utils"
+)
+
+const (
+	DefaultInstanceConnectKey  = "~/.gosh/instance_connect_rsa" // DefaultInstanceConnectKey is the default private key pushed with EC2 Instance Connect
+	DefaultInstanceConnectUser = "ec2-user"                     // DefaultInstanceConnectUser is the default OS user used with EC2 Instance Connect
+)
+
+// EnsureKeyPair returns the public key of the key pair, generating the pair with ssh-keygen
+// if the private key doesn't exist yet
+func EnsureKeyPair(path string) (string, error) {
+	path = utils.ExpandHome(path)
+	publicPath := path + ".pub"
+
+	if !utils.IsFile(path) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", fmt.Errorf("unable to create key directory: %w", err)
+		}
+
+		// RSA keys are supported by all the EC2 Instance Connect AMIs
+		cmd := exec.Command("ssh-keygen", "-q", "-t", "rsa", "-b", "4096", "-N", "", "-C", "gosh-instance-connect", "-f", path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("unable to generate key pair %s: %w (%s)", path, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	data, err := os.ReadFile(publicPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read public key: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,85 @@
+package connect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// ssmCommand returns the aws cli command starting an SSM session on the instance
+func (t *Target) ssmCommand() []string {
+	args := []string{"aws", "ssm", "start-session", "--target", t.Instance.ID}
+	return append(args, t.awsOptions()...)
+}
+
+// sshOverSSMCommand returns the ssh command connecting to the instance through an SSM session
+func (t *Target) sshOverSSMCommand() []string {
+	args := []string{"ssh", "-o", t.ssmProxyCommand()}
+	args = append(args, t.SSHOptions("")...)
+	args = append(args, t.Instance.ID)
+
+	return args
+}
+
+// ssmProxyCommand returns the ssh ProxyCommand option tunneling the connection through an SSM session
+func (t *Target) ssmProxyCommand() string {
+	proxy := []string{
+		"aws", "ssm", "start-session",
+		"--target", "%h",
+		"--document-name", "AWS-StartSSHSession",
+		"--parameters", "portNumber=%p",
+	}
+	proxy = append(proxy, t.awsOptions()...)
+
+	return "ProxyCommand=" + strings.Join(proxy, " ")
+}
+
+// awsOptions returns the aws cli profile and region options of the profile
+func (t *Target) awsOptions() []string {
+	args := []string{}
+
+	if len(t.Profile.Name) > 0 {
+		args = append(args, "--profile", t.Profile.Name)
+	}
+
+	region := t.Profile.Region
+	if len(region) == 0 && len(t.Instance.AZ) > 1 {
+		// eg. us-west-1a -> us-west-1
+		region = t.Instance.AZ[:len(t.Instance.AZ)-1]
+	}
+
+	if len(region) > 0 {
+		args = append(args, "--region", region)
+	}
+
+	return args
+}
+
+// methodCommand returns the command of the profile connect method, when not using ssh
+func (t *Target) methodCommand() ([]string, bool, error) {
+	switch t.Profile.Connect.Method {
+	case "", config.ConnectMethodSSH:
+		return nil, false, nil
+	case config.ConnectMethodSSM:
+		return t.ssmCommand(), true, nil
+	case config.ConnectMethodSSHSSM:
+		return t.sshOverSSMCommand(), true, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported connect method '%s'", t.Profile.Connect.Method)
+	}
+}
+
+// ssmTunnelCommand returns the aws cli command forwarding a local port through an SSM session
+func (t *Target) ssmTunnelCommand(localPort int, host string, port int) []string {
+	parameters := fmt.Sprintf("host=%s,portNumber=%d,localPortNumber=%d", host, port, localPort)
+
+	args := []string{
+		"aws", "ssm", "start-session",
+		"--target", t.Instance.ID,
+		"--document-name", "AWS-StartPortForwardingSessionToRemoteHost",
+		"--parameters", parameters,
+	}
+
+	return append(args, t.awsOptions()...)
+}
@@ -0,0 +1,93 @@
+package connect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// TmuxAvailable indicates if the tmux binary can be found
+func TmuxAvailable() bool {
+	_, err := exec.LookPath("tmux")
+	return err == nil
+}
+
+// InTmux indicates if gosh is running inside a tmux session
+func InTmux() bool {
+	return len(os.Getenv("TMUX")) > 0
+}
+
+// OpenTmux opens a tmux window with one pane per command, in the current tmux session or in a
+// new detached session when not running inside tmux, in which case the command attaching to the
+// new session is returned
+func OpenTmux(name string, commands [][]string, synchronize bool) ([]string, error) {
+	if len(commands) == 0 {
+		return nil, errors.New("no commands to run")
+	}
+
+	var create []string
+	session := ""
+
+	if InTmux() {
+		create = []string{"new-window", "-P", "-F", "#{window_id}", "-n", name, ShellQuote(commands[0])}
+	} else {
+		session = name
+		create = []string{"new-session", "-d", "-P", "-F", "#{window_id}", "-s", session, "-n", name, ShellQuote(commands[0])}
+	}
+
+	window, err := tmux(create...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, command := range commands[1:] {
+		if _, err := tmux("split-window", "-t", window, ShellQuote(command)); err != nil {
+			return nil, err
+		}
+
+		// rebalance after each split, or tmux runs out of space for the next panes
+		if _, err := tmux("select-layout", "-t", window, "tiled"); err != nil {
+			return nil, err
+		}
+	}
+
+	if synchronize {
+		if _, err := tmux("set-window-option", "-t", window, "synchronize-panes", "on"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(session) == 0 {
+		return nil, nil
+	}
+
+	return []string{"tmux", "attach-session", "-t", session}, nil
+}
+
+// tmux runs a tmux command and returns its trimmed output
+func tmux(args ...string) (string, error) {
+	out, err := exec.Command("tmux", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("tmux %s: %w (%s)", args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ShellQuote joins the arguments in a command line safe to pass to sh -c
+func ShellQuote(args []string) string {
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		if len(arg) > 0 && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@=,+%") == "" {
+			quoted[i] = arg
+			continue
+		}
+
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+
+	return strings.Join(quoted, " ")
+}
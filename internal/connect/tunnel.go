@@ -0,0 +1,36 @@
+package connect
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// TunnelCommand returns the command forwarding a local port to a host and port reachable from the
+// instance (ssh -L), running in the background without a shell
+func (t *Target) TunnelCommand(localPort int, host string, port int) ([]string, error) {
+	if t.Profile.Connect.Method == config.ConnectMethodSSM {
+		return t.ssmTunnelCommand(localPort, host, port), nil
+	}
+
+	args, err := t.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.Base(args[0]) != "ssh" {
+		return nil, fmt.Errorf("tunnels need an ssh connect command, not '%s'", args[0])
+	}
+
+	// IPv6 addresses must be enclosed in brackets
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	forward := fmt.Sprintf("%d:%s:%d", localPort, host, port)
+	options := []string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "BatchMode=yes", "-L", forward}
+
+	return append(append([]string{args[0]}, options...), args[1:]...), nil
+}
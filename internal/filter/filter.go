@@ -0,0 +1,189 @@
+// Package filter implements a small expression language to select instances.
+//
+// An expression is a list of predicates combined with `and` (or `&&`, the default
+// when predicates are simply separated by spaces), `or` (or `||`) and `not` (or `!`),
+// and grouped with parentheses, eg.
+//
+//	state=running tag:role=web az~us-west-1 launched<7d type!=t3.micro
+//	(tag:env=prod or tag:env=staging) and not type=t3.*
+//
+// A predicate is made of a field, an operator and a value:
+//
+//	=   equals (case insensitive), or glob match when the value contains * ? or [
+//	!=  does not equal (or does not match the glob)
+//	~   matches the regular expression
+//	!~  does not match the regular expression
+//	<, <=, >, >=  compares numbers, durations or dates
+//
+// Parentheses in a value are part of it when they are balanced (eg. name~^(web|db)-[0-9]+$), other
+// values containing parentheses or spaces must be quoted (eg. tag:team="ops (eu)").
+//
+// Words without an operator match the instance ID, IPs, state, type, AZ and tag values
+// (case insensitive), the same way the interactive search does.
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// Filter is a compiled filter expression
+type Filter struct {
+	expr string
+	root node
+}
+
+// Parse compiles the expression into a filter, an empty expression matches all instances
+func Parse(expr string) (*Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().typ == tokenEOF {
+		return &Filter{expr: expr}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unexpected '%s'", t.value)}
+	}
+
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match indicates if the instance matches the filter, a nil or empty filter matches all instances
+func (f *Filter) Match(i *providers.Instance) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+
+	return f.root.match(i)
+}
+
+// Empty indicates if the filter has no predicates
+func (f *Filter) Empty() bool {
+	return f == nil || f.root == nil
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(f.expr)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr parses: and-expression { or and-expression }
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{left}
+	for p.peek().typ == tokenOr {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+
+	if len(nodes) == 1 {
+		return left, nil
+	}
+
+	return orNode(nodes), nil
+}
+
+// parseAnd parses: unary { [and] unary }
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{left}
+	for {
+		t := p.peek()
+
+		if t.typ == tokenAnd {
+			p.next()
+		} else if t.typ != tokenTerm && t.typ != tokenNot && t.typ != tokenLParen {
+			break
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+
+	if len(nodes) == 1 {
+		return left, nil
+	}
+
+	return andNode(nodes), nil
+}
+
+// parseUnary parses: not unary | ( or-expression ) | term
+func (p *parser) parseUnary() (node, error) {
+	t := p.next()
+
+	switch t.typ {
+	case tokenNot:
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.typ != tokenRParen {
+			return nil, &ParseError{Pos: closing.pos, Msg: fmt.Sprintf("missing ')' for '(' at position %d", t.pos+1)}
+		}
+		return n, nil
+
+	case tokenTerm:
+		return parseTerm(t)
+
+	case tokenEOF:
+		return nil, &ParseError{Pos: t.pos, Msg: "unexpected end of filter"}
+
+	default:
+		return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unexpected '%s'", t.value)}
+	}
+}
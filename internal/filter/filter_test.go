@@ -0,0 +1,251 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// testInstances are matched by the filters, by name
+var testInstances = map[string]*providers.Instance{
+	"web-1": {
+		ID: "i-web1", PrivateIP: "10.0.1.10", PublicIP: "54.1.2.3", State: "running", AZ: "us-west-1a", Type: "t3.large",
+		Launched: time.Now().Add(-2 * 24 * time.Hour),
+		Tags:     map[string]string{"name": "web-1", "role": "web", "env": "prod", "team": "ops (eu)"},
+	},
+	"web-2": {
+		ID: "i-web2", PrivateIP: "10.0.1.11", State: "stopped", AZ: "us-west-1b", Type: "t3.micro",
+		Launched: time.Now().Add(-30 * 24 * time.Hour),
+		Tags:     map[string]string{"name": "web-2", "role": "web", "env": "staging"},
+	},
+	"db-1": {
+		ID: "i-db1", PrivateIP: "10.0.2.10", State: "running", AZ: "us-east-1a", Type: "r5.xlarge",
+		Launched: time.Now().Add(-3 * time.Hour),
+		Tags:     map[string]string{"name": "db-1", "role": "db", "env": "prod"},
+	},
+	"cache-1": {
+		ID: "i-cache1", PrivateIP: "10.0.3.10", State: "pending", AZ: "us-east-1b", Type: "t3.micro",
+		Launched: time.Now().Add(-10 * time.Minute),
+		Tags:     map[string]string{"name": "cache-1", "role": "cache", "env": "staging"},
+	},
+}
+
+// matching returns the names of the instances matching the filter, in name order
+func matching(f *Filter) string {
+	names := []string{}
+	for _, name := range []string{"cache-1", "db-1", "web-1", "web-2"} {
+		if f.Match(testInstances[name]) {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, " ")
+}
+
+func TestParseMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		// predicates
+		{"empty", "", "cache-1 db-1 web-1 web-2"},
+		{"spaces", "   ", "cache-1 db-1 web-1 web-2"},
+		{"equals", "state=running", "db-1 web-1"},
+		{"equals case insensitive", "STATE=Running", "db-1 web-1"},
+		{"not equals", "type!=t3.micro", "db-1 web-1"},
+		{"tag", "tag:role=web", "web-1 web-2"},
+		{"missing tag", "tag:missing=x", ""},
+		{"missing tag not equals", "tag:missing!=x", "cache-1 db-1 web-1 web-2"},
+		{"glob", "type=t3.*", "cache-1 web-1 web-2"},
+		{"glob class", "name=web-[12]", "web-1 web-2"},
+		{"glob negated class", "name=web-[!1]", "web-2"},
+		{"not glob", "name!=*-1", "web-2"},
+		{"field alias", "private=10.0.2.10", "db-1"},
+		{"ip field", "ip=54.1.2.3", "web-1"},
+		{"quoted value", `tag:team="ops (eu)"`, "web-1"},
+		{"single quoted value", `tag:team='ops (eu)'`, "web-1"},
+		{"free text", "web", "web-1 web-2"},
+		{"free text ip", "10.0.2", "db-1"},
+		{"quoted free text", `"ops (eu)"`, "web-1"},
+
+		// regular expressions
+		{"regex", "az~^us-west", "web-1 web-2"},
+		{"not regex", "az!~^us-west", "cache-1 db-1"},
+		{"regex alternatives", "name~^(web|db)-1$", "db-1 web-1"},
+		{"regex nested groups", "name~^((web)|(cache))-1$", "cache-1 web-1"},
+		{"regex in a group", "(name~^(web|db)-1$) and state=running", "db-1 web-1"},
+		{"regex in a group before or", "(name~^(db|cache) or tag:env=staging)", "cache-1 db-1 web-2"},
+		{"negated regex with group", "!name~^(web|db)", "cache-1"},
+		{"regex case insensitive", "az~^US-WEST", "web-1 web-2"},
+		{"regex case sensitive", "az~(?-i)^US-WEST", ""},
+
+		// numeric and duration comparisons
+		{"launched less than", "launched<7d", "cache-1 db-1 web-1"},
+		{"launched more than", "launched>7d", "web-2"},
+		{"launched hours", "launched<=4h", "cache-1 db-1"},
+		{"launched minutes", "age<1h", "cache-1"},
+		{"launched combined units", "launched<1d12h", "cache-1 db-1"},
+		{"launched weeks", "launched>=2w", "web-2"},
+		{"launched date", "launched<" + time.Now().Add(-7*24*time.Hour).Format("2006-01-02"), "web-2"},
+		{"numeric", "tag:env>a", "cache-1 db-1 web-1 web-2"},
+
+		// grouping and precedence
+		{"implicit and", "state=running tag:env=prod", "db-1 web-1"},
+		{"explicit and", "state=running and tag:role=web", "web-1"},
+		{"and symbol", "state=running && tag:role=web", "web-1"},
+		{"or", "tag:role=db or tag:role=cache", "cache-1 db-1"},
+		{"or symbol", "tag:role=db || tag:role=cache", "cache-1 db-1"},
+		{"and before or", "tag:role=web state=running or tag:role=cache", "cache-1 web-1"},
+		{"and before or reversed", "tag:role=cache or tag:role=web and state=stopped", "cache-1 web-2"},
+		{"parentheses", "(tag:role=web or tag:role=db) state=running", "db-1 web-1"},
+		{"nested parentheses", "((tag:role=web) or (tag:role=db and type=r5.*))", "db-1 web-1 web-2"},
+		{"parentheses without spaces", "(tag:env=prod)(state=running)", "db-1 web-1"},
+
+		// negation
+		{"not", "not state=running", "cache-1 web-2"},
+		{"not symbol", "!state=running", "cache-1 web-2"},
+		{"not separated", "! state=running", "cache-1 web-2"},
+		{"not group", "not (tag:role=web or tag:role=db)", "cache-1"},
+		{"not before and", "not tag:role=web state=running", "db-1"},
+		{"double not", "not not tag:role=db", "db-1"},
+		{"not free text", "!web", "cache-1 db-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := Parse(test.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", test.expr, err)
+			}
+
+			if got := matching(f); got != test.want {
+				t.Errorf("Parse(%q) matches %q, want %q", test.expr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"unknown field", "colour=red", 0, "unknown field 'colour'"},
+		{"missing field", "=running", 0, "missing field before '='"},
+		{"missing tag name", "tag:=web", 0, "missing tag name"},
+		{"missing value", "launched<", 0, "missing value after '<'"},
+		{"invalid regex", "name~^(web", 5, "invalid regular expression"},
+		{"invalid quoted regex", `name~"^(web"`, 6, "invalid regular expression"},
+		{"invalid regex after multibyte runes", "tag:équipe~^(web", 11, "invalid regular expression"},
+		{"invalid duration", "launched<7x", 9, "invalid duration or date '7x'"},
+		{"unterminated quote", `tag:team="ops`, 0, "unterminated quoted string"},
+		{"missing closing parenthesis", "(state=running", 14, "missing ')' for '(' at position 1"},
+		{"unbalanced closing parenthesis", "state=running)", 13, "unexpected ')'"},
+		{"unbalanced value parenthesis", "(name~^web)-1)", 13, "unexpected ')'"},
+		{"empty group", "()", 1, "unexpected ')'"},
+		{"dangling and", "state=running and", 17, "unexpected end of filter"},
+		{"dangling or", "or state=running", 0, "unexpected 'or'"},
+		{"dangling not", "state=running not", 17, "unexpected end of filter"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.expr)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse(%q) error = %v, want a parse error", test.expr, err)
+			}
+
+			if parseErr.Pos != test.pos || !strings.Contains(parseErr.Msg, test.msg) {
+				t.Errorf("Parse(%q) error = %q at %d, want %q at %d", test.expr, parseErr.Msg, parseErr.Pos, test.msg, test.pos)
+			}
+		})
+	}
+}
+
+func TestLexParentheses(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"name~^(a|b)$", []string{"name~^(a|b)$"}},
+		{"(name~^(a|b)$)", []string{"(", "name~^(a|b)$", ")"}},
+		{"(name=a)", []string{"(", "name=a", ")"}},
+		{"((a b))", []string{"(", "(", "a", "b", ")", ")"}},
+		{"f(x)", []string{"f", "(", "x", ")"}},
+		{"tag:x=\"(a b)\"", []string{"tag:x=(a b)"}},
+		{"!(a or b)", []string{"!", "(", "a", "or", "b", ")"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			tokens, err := lex(test.expr)
+			if err != nil {
+				t.Fatalf("lex(%q) error = %v", test.expr, err)
+			}
+
+			got := []string{}
+			for _, token := range tokens[:len(tokens)-1] { // without EOF
+				got = append(got, token.value)
+			}
+
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("lex(%q) = %q, want %q", test.expr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"30m", 30 * time.Minute, false},
+		{"12h", 12 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"1w2d", 9 * 24 * time.Hour, false},
+		{"d", 0, true},
+		{"7x", 0, true},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseDuration(test.value)
+			if (err != nil) != test.err {
+				t.Fatalf("ParseDuration(%q) error = %v, want error %v", test.value, err, test.err)
+			}
+
+			if got != test.want {
+				t.Errorf("ParseDuration(%q) = %s, want %s", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	var nilFilter *Filter
+	if !nilFilter.Empty() || !nilFilter.Match(testInstances["web-1"]) || nilFilter.String() != "" {
+		t.Error("a nil filter must be empty and match all instances")
+	}
+
+	f, err := Parse("  state=running  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Empty() || f.String() != "state=running" {
+		t.Errorf("String() = %q, want %q", f.String(), "state=running")
+	}
+}
@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokenTerm   tokenType = iota // predicate (key/operator/value) or free text
+	tokenAnd                     // and, &&
+	tokenOr                      // or, ||
+	tokenNot                     // not, !
+	tokenLParen                  // (
+	tokenRParen                  // )
+	tokenEOF
+)
+
+type token struct {
+	typ     tokenType
+	value   string
+	pos     int   // position of the token in the expression (0 based, in runes)
+	offsets []int // position in the expression of each rune of a term value (quotes are not part of the value)
+}
+
+// position returns the position in the expression of the byte offset of the value
+func (t token) position(offset int) int {
+	idx := utf8.RuneCountInString(t.value[:offset])
+	if idx < len(t.offsets) {
+		return t.offsets[idx]
+	}
+
+	return t.pos + idx
+}
+
+// ParseError describes an invalid filter expression
+type ParseError struct {
+	Pos int    // position of the error in the expression (0 based, in runes)
+	Msg string // error message
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// lex splits the expression into tokens, quoted strings (single or double quotes) are kept together,
+// and the balanced parentheses of a predicate value are part of the value (eg. name~^(web|db)-)
+func lex(expr string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{typ: tokenLParen, value: "(", pos: i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{typ: tokenRParen, value: ")", pos: i})
+			i++
+
+		case r == '!' && i+1 < len(runes) && runes[i+1] != '=' && runes[i+1] != '~' && !unicode.IsSpace(runes[i+1]):
+			// negation prefix (eg. !state=running)
+			tokens = append(tokens, token{typ: tokenNot, value: "!", pos: i})
+			i++
+
+		default:
+			start := i
+			var sb strings.Builder
+			offsets := []int{}
+			var quote rune
+			quoted := false
+			depth := 0 // parentheses opened in the value
+
+			for ; i < len(runes); i++ {
+				c := runes[i]
+
+				if quote != 0 {
+					if c == quote {
+						quote = 0
+						continue
+					}
+					sb.WriteRune(c)
+					offsets = append(offsets, i)
+					continue
+				}
+
+				if c == '"' || c == '\'' {
+					quote = c
+					quoted = true
+					continue
+				}
+
+				if unicode.IsSpace(c) {
+					break
+				}
+
+				if c == '(' || c == ')' {
+					// parentheses only group expressions until the operator of a predicate
+					if idx, _ := findOperator(sb.String()); idx < 0 {
+						break
+					}
+
+					if c == '(' {
+						depth++
+					} else if depth == 0 {
+						break // closes a group, eg. (name~^web)
+					} else {
+						depth--
+					}
+				}
+
+				sb.WriteRune(c)
+				offsets = append(offsets, i)
+			}
+
+			if quote != 0 {
+				return nil, &ParseError{Pos: start, Msg: "unterminated quoted string"}
+			}
+
+			t := keyword(sb.String(), start)
+			if quoted {
+				t = token{typ: tokenTerm, value: sb.String(), pos: start}
+			}
+			t.offsets = offsets
+			tokens = append(tokens, t)
+		}
+	}
+
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// keyword converts a raw word into an operator token when it is one
+func keyword(word string, pos int) token {
+	switch strings.ToLower(word) {
+	case "and", "&&":
+		return token{typ: tokenAnd, value: word, pos: pos}
+	case "or", "||":
+		return token{typ: tokenOr, value: word, pos: pos}
+	case "not", "!":
+		return token{typ: tokenNot, value: word, pos: pos}
+	}
+
+	return token{typ: tokenTerm, value: word, pos: pos}
+}
@@ -0,0 +1,339 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/providers"
+)
+
+const tagPrefix = "tag:"
+
+type node interface {
+	match(i *providers.Instance) bool
+}
+
+type andNode []node
+
+func (n andNode) match(i *providers.Instance) bool {
+	for _, c := range n {
+		if !c.match(i) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) match(i *providers.Instance) bool {
+	for _, c := range n {
+		if c.match(i) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node node
+}
+
+func (n notNode) match(i *providers.Instance) bool {
+	return !n.node.match(i)
+}
+
+// textNode matches free text in the instance fields and tag values
+type textNode string
+
+func (n textNode) match(i *providers.Instance) bool {
+	return i.Matches(string(n))
+}
+
+// fields maps the field names to the instance values, tags are accessed with the `tag:` prefix
+var fields = map[string]func(i *providers.Instance) []string{
+	"id":         func(i *providers.Instance) []string { return []string{i.ID} },
+	"private_ip": func(i *providers.Instance) []string { return []string{i.PrivateIP} },
+	"public_ip":  func(i *providers.Instance) []string { return []string{i.PublicIP} },
+	"ip":         func(i *providers.Instance) []string { return []string{i.PrivateIP, i.PublicIP} },
+	"state":      func(i *providers.Instance) []string { return []string{i.State} },
+	"az":         func(i *providers.Instance) []string { return []string{i.AZ} },
+	"type":       func(i *providers.Instance) []string { return []string{i.Type} },
+	"ami":        func(i *providers.Instance) []string { return []string{i.AMI} },
+	"vpc":        func(i *providers.Instance) []string { return []string{i.VPC} },
+	"ssm":        func(i *providers.Instance) []string { return []string{strconv.FormatBool(i.SSM)} },
+	"name":       func(i *providers.Instance) []string { return []string{i.Tags["name"]} },
+	"launched":   func(i *providers.Instance) []string { return []string{i.Launched.UTC().Format(time.RFC3339)} },
+}
+
+// fieldAliases are alternative names for fields
+var fieldAliases = map[string]string{
+	"private": "private_ip",
+	"public":  "public_ip",
+	"age":     "launched",
+	"running": "launched",
+}
+
+// operators ordered so that two-character operators are found first
+var operators = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+type predicate struct {
+	field  string
+	op     string
+	values func(i *providers.Instance) []string
+	cmp    func(value string) bool          // used by string operators
+	since  func(i *providers.Instance) bool // used by launched comparisons
+}
+
+func (p *predicate) match(i *providers.Instance) bool {
+	if p.since != nil {
+		return p.since(i)
+	}
+
+	values := p.values(i)
+	negated := p.op == "!=" || p.op == "!~"
+
+	for _, v := range values {
+		if p.cmp(v) {
+			return !negated
+		}
+	}
+
+	return negated
+}
+
+// parseTerm compiles a predicate (eg. state=running) or free text when there is no operator
+func parseTerm(t token) (node, error) {
+	idx, op := findOperator(t.value)
+	if idx < 0 {
+		return textNode(t.value), nil
+	}
+
+	field := strings.ToLower(t.value[:idx])
+	value := t.value[idx+len(op):]
+
+	if len(field) == 0 {
+		return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("missing field before '%s'", op)}
+	}
+
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+
+	p := &predicate{field: field, op: op}
+
+	if strings.HasPrefix(field, tagPrefix) {
+		key := strings.TrimPrefix(field, tagPrefix)
+		if len(key) == 0 {
+			return nil, &ParseError{Pos: t.pos, Msg: "missing tag name after 'tag:'"}
+		}
+		p.values = func(i *providers.Instance) []string { return []string{i.Tags[key]} }
+	} else if f, ok := fields[field]; ok {
+		p.values = f
+	} else {
+		return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unknown field '%s' (valid fields: %s, tag:<name>)", field, strings.Join(fieldNames(), ", "))}
+	}
+
+	var err error
+	switch op {
+	case "=", "!=":
+		p.cmp, err = equals(value)
+	case "~", "!~":
+		p.cmp, err = matches(value)
+	default:
+		if len(value) == 0 {
+			return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("missing value after '%s'", op)}
+		}
+
+		if field == "launched" {
+			p.since, err = compareLaunched(op, value)
+		} else {
+			p.cmp = compare(op, value)
+		}
+	}
+
+	if err != nil {
+		return nil, &ParseError{Pos: t.position(idx + len(op)), Msg: err.Error()}
+	}
+
+	return p, nil
+}
+
+func findOperator(term string) (int, string) {
+	for idx := range term {
+		for _, op := range operators {
+			if strings.HasPrefix(term[idx:], op) {
+				return idx, op
+			}
+		}
+	}
+
+	return -1, ""
+}
+
+func fieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// equals compares values case insensitively, or as a glob pattern when the value contains wildcards
+func equals(value string) (func(string) bool, error) {
+	if !strings.ContainsAny(value, "*?[") {
+		return func(v string) bool { return strings.EqualFold(v, value) }, nil
+	}
+
+	re, err := regexp.Compile("(?i)^" + globToRegexp(value) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %w", value, err)
+	}
+
+	return re.MatchString, nil
+}
+
+// matches matches values with a regular expression, case insensitively like equals (unless the
+// expression disables it with (?-i))
+func matches(value string) (func(string) bool, error) {
+	if _, err := regexp.Compile(value); err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %w", value, err)
+	}
+
+	return regexp.MustCompile("(?i)" + value).MatchString, nil
+}
+
+// compare compares numerically when both values are numbers, otherwise lexicographically
+func compare(op string, value string) func(string) bool {
+	num, numErr := strconv.ParseFloat(value, 64)
+
+	return func(v string) bool {
+		var c int
+
+		if n, err := strconv.ParseFloat(v, 64); err == nil && numErr == nil {
+			switch {
+			case n < num:
+				c = -1
+			case n > num:
+				c = 1
+			}
+		} else {
+			c = strings.Compare(v, value)
+		}
+
+		return compareResult(op, c)
+	}
+}
+
+// compareLaunched compares the age of the instance when the value is a duration (eg. launched<7d
+// means launched less than 7 days ago), or the launch time when the value is a date
+func compareLaunched(op string, value string) (func(*providers.Instance) bool, error) {
+	if d, err := ParseDuration(value); err == nil {
+		return func(i *providers.Instance) bool {
+			age := time.Since(i.Launched)
+
+			c := 0
+			switch {
+			case age < d:
+				c = -1
+			case age > d:
+				c = 1
+			}
+
+			return compareResult(op, c)
+		}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return func(i *providers.Instance) bool {
+				return compareResult(op, i.Launched.Compare(t))
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid duration or date '%s' (eg. 12h, 7d, 2w, 2006-01-02)", value)
+}
+
+func compareResult(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// ParseDuration parses a duration like time.ParseDuration, with the additional
+// `d` (days) and `w` (weeks) units, eg. 7d, 2w, 1d12h
+func ParseDuration(value string) (time.Duration, error) {
+	var total time.Duration
+	rest := value
+
+	for len(rest) > 0 {
+		idx := strings.IndexAny(rest, "dw")
+		if idx < 0 {
+			d, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, err
+			}
+			return total + d, nil
+		}
+
+		n, err := strconv.Atoi(rest[:idx])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+
+		unit := 24 * time.Hour
+		if rest[idx] == 'w' {
+			unit *= 7
+		}
+
+		total += time.Duration(n) * unit
+		rest = rest[idx+1:]
+	}
+
+	return total, nil
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta(glob[i:]))
+				return sb.String()
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,501 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ansibleInventory is a parsed Ansible inventory, before the variables are resolved
+type ansibleInventory struct {
+	groups map[string]*ansibleGroup
+	hosts  map[string]*ansibleHost
+	order  []string // host names, in order of appearance
+}
+
+type ansibleGroup struct {
+	name     string
+	hosts    []string
+	children []string
+	vars     map[string]string
+}
+
+type ansibleHost struct {
+	name string
+	path string
+	line int
+	vars map[string]string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	inv := &ansibleInventory{
+		groups: make(map[string]*ansibleGroup),
+		hosts:  make(map[string]*ansibleHost),
+	}
+
+	inv.group("all")
+	inv.group("ungrouped")
+
+	return inv
+}
+
+// group returns the group, created when it doesn't exist yet
+func (inv *ansibleInventory) group(name string) *ansibleGroup {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &ansibleGroup{name: name, vars: make(map[string]string)}
+		inv.groups[name] = g
+	}
+
+	return g
+}
+
+// addChild adds a child group to a group
+func (inv *ansibleInventory) addChild(group string, child string) {
+	g := inv.group(group)
+	inv.group(child)
+
+	if child == group || contains(g.children, child) {
+		return
+	}
+
+	g.children = append(g.children, child)
+}
+
+// addHost adds a host to a group, the variables of a host defined several times are merged
+func (inv *ansibleInventory) addHost(group string, name string, vars map[string]string, path string, line int) {
+	host, ok := inv.hosts[name]
+	if !ok {
+		host = &ansibleHost{name: name, path: path, line: line, vars: make(map[string]string)}
+		inv.hosts[name] = host
+		inv.order = append(inv.order, name)
+	}
+
+	for key, value := range vars {
+		host.vars[key] = value
+	}
+
+	g := inv.group(group)
+	if !contains(g.hosts, name) {
+		g.hosts = append(g.hosts, name)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAnsibleINI reads an INI inventory, with [group], [group:vars] and [group:children] sections
+func parseAnsibleINI(inv *ansibleInventory, path string, data []byte, errs *InventoryError) {
+	group, kind := "ungrouped", ""
+
+	for idx, raw := range strings.Split(string(data), "\n") {
+		line := idx + 1
+		text := strings.TrimSpace(raw)
+
+		if len(text) == 0 || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			if !strings.HasSuffix(text, "]") {
+				errs.add(path, line, "invalid section '%s'", text)
+				continue
+			}
+
+			name, suffix, _ := strings.Cut(text[1:len(text)-1], ":")
+			if len(name) == 0 || strings.ContainsAny(name, " \t") {
+				errs.add(path, line, "invalid group name '%s'", name)
+				continue
+			}
+
+			switch suffix {
+			case "", "vars", "children":
+				group, kind = name, suffix
+				inv.group(group)
+			default:
+				errs.add(path, line, "invalid section type '%s' (expected vars or children)", suffix)
+			}
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(text, "=")
+			if !ok || len(strings.TrimSpace(key)) == 0 {
+				errs.add(path, line, "invalid variable '%s' (expected key=value)", text)
+				continue
+			}
+			inv.group(group).vars[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+
+		case "children":
+			inv.addChild(group, strings.Fields(text)[0])
+
+		default:
+			fields := splitAnsibleLine(text)
+			if len(fields) == 0 {
+				continue // comment
+			}
+
+			vars := make(map[string]string)
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok || len(key) == 0 {
+					errs.add(path, line, "invalid host variable '%s' (expected key=value)", field)
+					continue
+				}
+				vars[key] = unquote(value)
+			}
+
+			// host:port shorthand (but not IPv6 addresses)
+			name := fields[0]
+			if idx := strings.LastIndex(name, ":"); idx > 0 && strings.Count(name, ":") == 1 {
+				if _, err := strconv.Atoi(name[idx+1:]); err == nil {
+					vars["ansible_port"] = name[idx+1:]
+					name = name[:idx]
+				}
+			}
+
+			names, err := expandHostPattern(name)
+			if err != nil {
+				errs.add(path, line, "%s", err)
+				continue
+			}
+
+			for _, name := range names {
+				inv.addHost(group, name, vars, path, line)
+			}
+		}
+	}
+}
+
+// splitAnsibleLine splits a host line on spaces, keeping quoted values and ignoring comments
+func splitAnsibleLine(text string) []string {
+	fields := []string{}
+	current := strings.Builder{}
+	quote := rune(0)
+
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			current.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		case r == '#' && current.Len() == 0:
+			return fields
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+
+	return fields
+}
+
+// unquote removes the quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// hostRangeRegexp matches a host range, eg. web[01:20].example.com or db-[a:c]
+var hostRangeRegexp = regexp.MustCompile(`^([^\[]*)\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\](.*)$`)
+
+// expandHostPattern returns the host names of a host range (eg. web[01:03] is web01, web02 and web03)
+func expandHostPattern(pattern string) ([]string, error) {
+	match := hostRangeRegexp.FindStringSubmatch(pattern)
+	if match == nil {
+		if strings.ContainsAny(pattern, "[]") {
+			return nil, fmt.Errorf("invalid host range '%s'", pattern)
+		}
+
+		return []string{pattern}, nil
+	}
+
+	prefix, start, end, suffix := match[1], match[2], match[3], match[5]
+
+	step := 1
+	if len(match[4]) > 0 {
+		step, _ = strconv.Atoi(match[4])
+		if step <= 0 {
+			return nil, fmt.Errorf("invalid host range step in '%s'", pattern)
+		}
+	}
+
+	// the suffix can contain other ranges
+	suffixes, err := expandHostPattern(suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []string{}
+
+	first, errStart := strconv.Atoi(start)
+	last, errEnd := strconv.Atoi(end)
+	switch {
+	case errStart == nil && errEnd == nil:
+		format := "%d"
+		if len(start) > 1 && start[0] == '0' {
+			format = fmt.Sprintf("%%0%dd", len(start))
+		}
+
+		for n := first; n <= last; n += step {
+			values = append(values, fmt.Sprintf(format, n))
+		}
+
+	case len(start) == 1 && len(end) == 1 && errStart != nil && errEnd != nil:
+		for c := int(start[0]); c <= int(end[0]); c += step {
+			values = append(values, string(rune(c)))
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid host range '%s'", pattern)
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty host range '%s'", pattern)
+	}
+
+	names := []string{}
+	for _, value := range values {
+		for _, s := range suffixes {
+			names = append(names, prefix+value+s)
+		}
+	}
+
+	return names, nil
+}
+
+// parseAnsibleYAML reads a YAML inventory, groups (usually all) with hosts, vars and children
+func parseAnsibleYAML(inv *ansibleInventory, path string, data []byte, errs *InventoryError) {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		line, message := yamlError(err)
+		errs.add(path, line, "%s", message)
+		return
+	}
+
+	if len(root.Content) == 0 {
+		return // empty file
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		errs.add(path, doc.Line, "expected a mapping of groups")
+		return
+	}
+
+	for idx := 0; idx < len(doc.Content)-1; idx += 2 {
+		parseAnsibleYAMLGroup(inv, path, doc.Content[idx].Value, doc.Content[idx+1], errs)
+	}
+}
+
+// parseAnsibleYAMLGroup reads the hosts, vars and children of a group
+func parseAnsibleYAMLGroup(inv *ansibleInventory, path string, name string, node *yaml.Node, errs *InventoryError) {
+	g := inv.group(name)
+
+	if isYAMLNull(node) {
+		return
+	}
+
+	if node.Kind != yaml.MappingNode {
+		errs.add(path, node.Line, "group '%s' should be a mapping of hosts, vars and children", name)
+		return
+	}
+
+	for idx := 0; idx < len(node.Content)-1; idx += 2 {
+		key, value := node.Content[idx], node.Content[idx+1]
+
+		switch key.Value {
+		case "hosts":
+			if isYAMLNull(value) {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				errs.add(path, value.Line, "hosts of group '%s' should be a mapping", name)
+				continue
+			}
+
+			for h := 0; h < len(value.Content)-1; h += 2 {
+				hostKey, hostVars := value.Content[h], value.Content[h+1]
+
+				vars, ok := yamlVars(path, hostVars, errs)
+				if !ok {
+					continue
+				}
+
+				names, err := expandHostPattern(hostKey.Value)
+				if err != nil {
+					errs.add(path, hostKey.Line, "%s", err)
+					continue
+				}
+
+				for _, host := range names {
+					inv.addHost(name, host, vars, path, hostKey.Line)
+				}
+			}
+
+		case "vars":
+			if vars, ok := yamlVars(path, value, errs); ok {
+				for k, v := range vars {
+					g.vars[k] = v
+				}
+			}
+
+		case "children":
+			if isYAMLNull(value) {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				errs.add(path, value.Line, "children of group '%s' should be a mapping", name)
+				continue
+			}
+
+			for c := 0; c < len(value.Content)-1; c += 2 {
+				child := value.Content[c].Value
+				inv.addChild(name, child)
+				parseAnsibleYAMLGroup(inv, path, child, value.Content[c+1], errs)
+			}
+
+		default:
+			errs.add(path, key.Line, "unknown key '%s' in group '%s' (expected hosts, vars or children)", key.Value, name)
+		}
+	}
+}
+
+func isYAMLNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// yamlVars decodes a mapping of variables
+func yamlVars(path string, node *yaml.Node, errs *InventoryError) (map[string]string, bool) {
+	if isYAMLNull(node) {
+		return map[string]string{}, true
+	}
+
+	values := make(map[string]interface{})
+	if err := node.Decode(&values); err != nil {
+		errs.add(path, node.Line, "variables should be a mapping")
+		return nil, false
+	}
+
+	return ansibleVars(values), true
+}
+
+// ansibleVars converts the variables to strings, non scalar values are JSON encoded
+func ansibleVars(values map[string]interface{}) map[string]string {
+	vars := make(map[string]string)
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+			vars[key] = ""
+		case string:
+			vars[key] = v
+		case int, int64, uint64, float64, bool:
+			vars[key] = fmt.Sprint(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				data = []byte(fmt.Sprint(v))
+			}
+			vars[key] = string(data)
+		}
+	}
+
+	return vars
+}
+
+// yamlErrorRegexp matches the line of the YAML syntax errors
+var yamlErrorRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlError returns the line and message of a YAML syntax error
+func yamlError(err error) (int, string) {
+	if match := yamlErrorRegexp.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line, match[2]
+	}
+
+	return 0, strings.TrimPrefix(err.Error(), "yaml: ")
+}
+
+// parseAnsibleScript reads the JSON output of a dynamic inventory script (--list), with the host
+// variables in _meta.hostvars
+func parseAnsibleScript(inv *ansibleInventory, path string, data []byte, errs *InventoryError) {
+	list := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &list); err != nil {
+		errs.add(path, 0, "invalid dynamic inventory output: %s", err)
+		return
+	}
+
+	var meta struct {
+		HostVars map[string]map[string]interface{} `json:"hostvars"`
+	}
+	if raw, ok := list["_meta"]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			errs.add(path, 0, "invalid dynamic inventory _meta: %s", err)
+		}
+	}
+
+	names := make([]string, 0, len(list))
+	for name := range list {
+		if name != "_meta" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var group struct {
+			Hosts    []string               `json:"hosts"`
+			Vars     map[string]interface{} `json:"vars"`
+			Children []string               `json:"children"`
+		}
+
+		// a group is either a list of hosts, or an object
+		if err := json.Unmarshal(list[name], &group.Hosts); err != nil {
+			if err := json.Unmarshal(list[name], &group); err != nil {
+				errs.add(path, 0, "invalid dynamic inventory group '%s': %s", name, err)
+				continue
+			}
+		}
+
+		g := inv.group(name)
+		for key, value := range ansibleVars(group.Vars) {
+			g.vars[key] = value
+		}
+
+		for _, child := range group.Children {
+			inv.addChild(name, child)
+		}
+
+		for _, host := range group.Hosts {
+			inv.addHost(name, host, ansibleVars(meta.HostVars[host]), path, 0)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yogin/gosh/internal/utils"
+)
+
+const (
+	AzureDefaultEndpoint = "https://management.azure.com" // AzureDefaultEndpoint is the public Azure Resource Manager endpoint
+	azureAuthority       = "https://login.microsoftonline.com"
+)
+
+// azureCredentials is a service principal, read from a file created with
+// az ad sp create-for-rbac --sdk-auth, or from the AZURE_* environment variables
+type azureCredentials struct {
+	ClientID       string `json:"clientId"`
+	ClientSecret   string `json:"clientSecret"`
+	TenantID       string `json:"tenantId"`
+	SubscriptionID string `json:"subscriptionId"`
+	Authority      string `json:"activeDirectoryEndpointUrl"`
+	ResourceURL    string `json:"resourceManagerEndpointUrl"`
+}
+
+// loadAzureCredentials reads the service principal file, or the environment variables, returns nil
+// credentials (and no error) when none are found, to use the Azure CLI instead
+func loadAzureCredentials(path string) (*azureCredentials, error) {
+	creds := &azureCredentials{}
+
+	if len(path) > 0 {
+		path = utils.ExpandHome(path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read azure credentials: %w", err)
+		}
+
+		if err := json.Unmarshal(data, creds); err != nil {
+			return nil, fmt.Errorf("invalid azure credentials %s: %w", path, err)
+		}
+	} else {
+		creds.ClientID = os.Getenv("AZURE_CLIENT_ID")
+		creds.ClientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+		creds.TenantID = os.Getenv("AZURE_TENANT_ID")
+		creds.SubscriptionID = os.Getenv("AZURE_SUBSCRIPTION_ID")
+
+		if len(creds.ClientID) == 0 || len(creds.ClientSecret) == 0 || len(creds.TenantID) == 0 {
+			return nil, nil
+		}
+	}
+
+	if len(creds.ClientID) == 0 || len(creds.ClientSecret) == 0 || len(creds.TenantID) == 0 {
+		return nil, fmt.Errorf("invalid azure credentials %s: clientId, clientSecret and tenantId are required", path)
+	}
+
+	return creds, nil
+}
+
+// azureTokenSource returns Azure Resource Manager access tokens, refreshed shortly before they expire
+type azureTokenSource struct {
+	client       *http.Client
+	creds        *azureCredentials
+	resource     string
+	subscription string // subscription of the Azure CLI account, when not configured
+	token        string
+	expiry       time.Time
+	mutex        sync.Mutex
+}
+
+// Token returns a valid access token
+func (t *azureTokenSource) Token(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.token) > 0 && time.Until(t.expiry) > time.Minute {
+		return t.token, nil
+	}
+
+	var err error
+	if t.creds == nil {
+		err = t.cliToken(ctx)
+	} else {
+		err = t.clientSecretToken(ctx)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get azure access token: %w", err)
+	}
+
+	return t.token, nil
+}
+
+// Subscription returns the subscription of the Azure CLI account, once a token was requested
+func (t *azureTokenSource) Subscription() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.subscription
+}
+
+// clientSecretToken requests a token for the service principal
+func (t *azureTokenSource) clientSecretToken(ctx context.Context) error {
+	authority := t.creds.Authority
+	if len(authority) == 0 {
+		authority = azureAuthority
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.creds.ClientID},
+		"client_secret": {t.creds.ClientSecret},
+		"scope":         {strings.TrimSuffix(t.resource, "/") + "/.default"},
+	}
+
+	u := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authority, "/"), url.PathEscape(t.creds.TenantID))
+	req, err := formRequest(ctx, u, form)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: res.StatusCode, Message: azureAuthError(body)}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || len(token.AccessToken) == 0 {
+		return errors.New("invalid token response")
+	}
+
+	t.token = token.AccessToken
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return nil
+}
+
+// azureAuthError returns the description of an Azure AD error response
+func azureAuthError(body []byte) string {
+	var res struct {
+		Description string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &res); err == nil && len(res.Description) > 0 {
+		// the description is followed by the trace and correlation ids
+		return strings.SplitN(res.Description, "\r\n", 2)[0]
+	}
+
+	return strings.TrimSpace(string(body))
+}
+
+// cliToken requests a token from the Azure CLI (az login)
+func (t *azureTokenSource) cliToken(ctx context.Context) error {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", t.resource, "--output", "json")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+			lines := strings.Split(message, "\n")
+			return errors.New(strings.TrimSpace(lines[len(lines)-1]))
+		}
+
+		return err
+	}
+
+	var token struct {
+		AccessToken  string `json:"accessToken"`
+		ExpiresOn    string `json:"expiresOn"`  // local time
+		ExpiresAt    int64  `json:"expires_on"` // unix time, azure cli 2.54+
+		Subscription string `json:"subscription"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &token); err != nil || len(token.AccessToken) == 0 {
+		return errors.New("invalid azure cli token")
+	}
+
+	t.token = token.AccessToken
+	t.subscription = token.Subscription
+
+	if token.ExpiresAt > 0 {
+		t.expiry = time.Unix(token.ExpiresAt, 0)
+	} else if expiry, err := time.ParseInLocation("2006-01-02 15:04:05.999999", token.ExpiresOn, time.Local); err == nil {
+		t.expiry = expiry
+	} else {
+		t.expiry = time.Now().Add(5 * time.Minute)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Detail is a value displayed in the instance details, grouped by section
+type Detail struct {
+	Section string
+	Key     string
+	Value   string
+}
+
+// Details returns everything known about the instance, from the raw provider data when available
+// (the launch time is left to the caller, which formats it)
+func (i *Instance) Details() []*Detail {
+	d := &details{}
+
+	d.add("Instance", "ID", i.ID)
+	d.add("Instance", "State", i.State)
+	d.add("Instance", "Type", i.Type)
+	d.add("Instance", "AMI", i.AMI)
+	d.add("Instance", "AZ", i.AZ)
+
+	if i.data != nil {
+		i.awsDetails(d)
+	} else {
+		d.add("Network", "VPC", i.VPC)
+		d.add("Network", "Private IP", i.PrivateIP)
+		d.add("Network", "Public IP", i.PublicIP)
+	}
+
+	for _, detail := range i.extra {
+		d.add(detail.Section, detail.Key, detail.Value)
+	}
+
+	keys := make([]string, 0, len(i.Tags))
+	for key := range i.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// tag keys are lowercased, display the original keys when known
+	names := make(map[string]string)
+	if i.data != nil {
+		for _, tag := range i.data.Tags {
+			names[strings.ToLower(aws.StringValue(tag.Key))] = aws.StringValue(tag.Key)
+		}
+	}
+
+	for _, key := range keys {
+		name := key
+		if original, ok := names[key]; ok {
+			name = original
+		}
+
+		d.add("Tags", name, i.Tags[key])
+	}
+
+	return d.list
+}
+
+// addExtra adds a provider specific detail, for providers without raw EC2 data
+func (i *Instance) addExtra(section string, key string, value string) {
+	i.extra = append(i.extra, &Detail{Section: section, Key: key, Value: value})
+}
+
+// awsDetails adds the EC2 specific details
+func (i *Instance) awsDetails(d *details) {
+	data := i.data
+
+	// only windows instances have a platform
+	platform := aws.StringValue(data.Platform)
+	if len(platform) == 0 {
+		platform = "Linux/UNIX"
+	}
+	d.add("Instance", "Platform", platform)
+	d.add("Instance", "Architecture", aws.StringValue(data.Architecture))
+
+	lifecycle := aws.StringValue(data.InstanceLifecycle)
+	if len(lifecycle) == 0 {
+		lifecycle = "on-demand"
+	}
+	d.add("Instance", "Lifecycle", lifecycle)
+
+	if data.Monitoring != nil {
+		d.add("Instance", "Monitoring", aws.StringValue(data.Monitoring.State))
+	}
+	if data.StateReason != nil {
+		d.add("Instance", "State reason", aws.StringValue(data.StateReason.Message))
+	}
+	d.add("Instance", "Key name", aws.StringValue(data.KeyName))
+	if data.IamInstanceProfile != nil {
+		d.add("Instance", "IAM instance profile", aws.StringValue(data.IamInstanceProfile.Arn))
+	}
+
+	d.add("Network", "VPC", aws.StringValue(data.VpcId))
+	d.add("Network", "Subnet", aws.StringValue(data.SubnetId))
+	d.add("Network", "Private IP", aws.StringValue(data.PrivateIpAddress))
+	d.add("Network", "Private DNS", aws.StringValue(data.PrivateDnsName))
+	d.add("Network", "Public IP", aws.StringValue(data.PublicIpAddress))
+	d.add("Network", "Public DNS", aws.StringValue(data.PublicDnsName))
+
+	for _, group := range data.SecurityGroups {
+		d.add("Security groups", aws.StringValue(group.GroupId), aws.StringValue(group.GroupName))
+	}
+
+	for _, eni := range data.NetworkInterfaces {
+		section := fmt.Sprintf("Network interface %s", aws.StringValue(eni.NetworkInterfaceId))
+		if eni.Attachment != nil {
+			section = fmt.Sprintf("%s (device %d)", section, aws.Int64Value(eni.Attachment.DeviceIndex))
+		}
+
+		d.add(section, "Subnet", aws.StringValue(eni.SubnetId))
+		d.add(section, "MAC", aws.StringValue(eni.MacAddress))
+
+		for _, ip := range eni.PrivateIpAddresses {
+			key := "Private IP"
+			if aws.BoolValue(ip.Primary) {
+				key = "Private IP (primary)"
+			}
+			d.add(section, key, aws.StringValue(ip.PrivateIpAddress))
+
+			if ip.Association != nil {
+				d.add(section, "Public IP", aws.StringValue(ip.Association.PublicIp))
+			}
+		}
+
+		for _, ip := range eni.Ipv6Addresses {
+			d.add(section, "IPv6", aws.StringValue(ip.Ipv6Address))
+		}
+	}
+
+	for _, device := range data.BlockDeviceMappings {
+		if device.Ebs == nil {
+			continue
+		}
+
+		value := aws.StringValue(device.Ebs.VolumeId)
+		if aws.BoolValue(device.Ebs.DeleteOnTermination) {
+			value += " (deleted on termination)"
+		}
+
+		d.add("Block devices", aws.StringValue(device.DeviceName), value)
+	}
+
+	if data.RootDeviceName != nil {
+		d.add("Block devices", "Root device", fmt.Sprintf("%s (%s)", aws.StringValue(data.RootDeviceName), aws.StringValue(data.RootDeviceType)))
+	}
+
+	if data.CpuOptions != nil {
+		d.add("Instance", "CPU", fmt.Sprintf("%d cores x %d threads", aws.Int64Value(data.CpuOptions.CoreCount), aws.Int64Value(data.CpuOptions.ThreadsPerCore)))
+	}
+
+	if data.HibernationOptions != nil && aws.BoolValue(data.HibernationOptions.Configured) {
+		d.add("Instance", "Hibernation", "enabled")
+	}
+
+	if data.MetadataOptions != nil {
+		d.add("Instance", "Metadata tokens (IMDSv2)", aws.StringValue(data.MetadataOptions.HttpTokens))
+	}
+
+	d.add("Instance", "State transition", aws.StringValue(data.StateTransitionReason))
+}
+
+// details collects the non empty details, keeping the sections together in order of appearance
+type details struct {
+	list []*Detail
+}
+
+func (d *details) add(section string, key string, value string) {
+	if len(value) == 0 {
+		return
+	}
+
+	detail := &Detail{Section: section, Key: key, Value: value}
+
+	// insert after the last detail of the same section
+	for idx := len(d.list) - 1; idx >= 0; idx-- {
+		if d.list[idx].Section == section {
+			d.list = append(d.list[:idx+1], append([]*Detail{detail}, d.list[idx+1:]...)...)
+			return
+		}
+	}
+
+	d.list = append(d.list, detail)
+}
@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// IsThrottlingError indicates if the provider API rejected the request because of rate limiting
+func IsThrottlingError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return request.IsErrorThrottle(err)
+}
+
+// IsTransientError indicates if the request failed because of a temporary issue (throttling, timeout,
+// network or server error) and can be retried
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if IsThrottlingError(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// the aws sdk considers unknown errors as retryable
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return request.IsErrorRetryable(awsErr)
+	}
+
+	return false
+}
+
+// HTTPError is an error response of a provider REST API
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
@@ -0,0 +1,264 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yogin/gosh/internal/utils"
+)
+
+const (
+	gceTokenURL      = "https://oauth2.googleapis.com/token"
+	gceMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gceScope         = "https://www.googleapis.com/auth/compute.readonly"
+)
+
+// gceCredentials is a service account or authorized user (gcloud auth application-default login)
+// credentials file
+type gceCredentials struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	QuotaProject string `json:"quota_project_id"`
+
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loadGCECredentials reads the credentials file, or the application default credentials, returns
+// nil credentials (and no error) when none are found, to use the metadata server instead
+func loadGCECredentials(path string) (*gceCredentials, error) {
+	path = utils.ExpandHome(path)
+	if len(path) == 0 {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	if len(path) == 0 {
+		path = gceDefaultCredentialsPath()
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gcp credentials: %w", err)
+	}
+
+	creds := &gceCredentials{}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, fmt.Errorf("invalid gcp credentials %s: %w", path, err)
+	}
+
+	switch creds.Type {
+	case "service_account", "authorized_user":
+		return creds, nil
+	default:
+		return nil, fmt.Errorf("unsupported gcp credentials type '%s' in %s", creds.Type, path)
+	}
+}
+
+// gceDefaultCredentialsPath returns the path of the credentials written by gcloud auth application-default login
+func gceDefaultCredentialsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// gceTokenSource returns OAuth2 access tokens, refreshed shortly before they expire
+type gceTokenSource struct {
+	client *http.Client
+	creds  *gceCredentials
+	token  string
+	expiry time.Time
+	mutex  sync.Mutex
+}
+
+// Token returns a valid access token
+func (t *gceTokenSource) Token(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.token) > 0 && time.Until(t.expiry) > time.Minute {
+		return t.token, nil
+	}
+
+	var req *http.Request
+	var err error
+
+	switch {
+	case t.creds == nil:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, gceMetadataToken, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case t.creds.Type == "service_account":
+		req, err = t.serviceAccountRequest(ctx)
+	default:
+		req, err = t.refreshTokenRequest(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to get gcp access token: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to get gcp access token: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get gcp access token: %w", &HTTPError{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))})
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || len(token.AccessToken) == 0 {
+		return "", errors.New("unable to get gcp access token: invalid token response")
+	}
+
+	t.token = token.AccessToken
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return t.token, nil
+}
+
+// serviceAccountRequest exchanges a JWT signed with the service account key for an access token
+func (t *gceTokenSource) serviceAccountRequest(ctx context.Context) (*http.Request, error) {
+	key, err := parseRSAPrivateKey(t.creds.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gcp service account key: %w", err)
+	}
+
+	tokenURL := t.creds.TokenURI
+	if len(tokenURL) == 0 {
+		tokenURL = gceTokenURL
+	}
+
+	now := time.Now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT", "kid": t.creds.PrivateKeyID}
+	claims := map[string]interface{}{
+		"iss":   t.creds.ClientEmail,
+		"scope": gceScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+
+	jwt, err := signJWT(header, claims, key)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {jwt},
+	}
+
+	return formRequest(ctx, tokenURL, form)
+}
+
+// refreshTokenRequest exchanges the gcloud refresh token for an access token
+func (t *gceTokenSource) refreshTokenRequest(ctx context.Context) (*http.Request, error) {
+	tokenURL := t.creds.TokenURI
+	if len(tokenURL) == 0 {
+		tokenURL = gceTokenURL
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {t.creds.ClientID},
+		"client_secret": {t.creds.ClientSecret},
+		"refresh_token": {t.creds.RefreshToken},
+	}
+
+	return formRequest(ctx, tokenURL, form)
+}
+
+func formRequest(ctx context.Context, url string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return req, nil
+}
+
+// signJWT returns a JWT signed with RS256
+func signJWT(header map[string]string, claims map[string]interface{}, key *rsa.PrivateKey) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign jwt: %w", err)
+	}
+
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#8 or PKCS#1 RSA private key
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an RSA private key")
+		}
+
+		return rsaKey, nil
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
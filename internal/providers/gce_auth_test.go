@@ -0,0 +1,292 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTokenServer is a local fake of the OAuth2 token endpoint, handle checks the token request
+// and returns the access token (or an error)
+type fakeTokenServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests int
+}
+
+func newFakeTokenServer(t *testing.T, expiresIn int, handle func(r *http.Request) (string, error)) *fakeTokenServer {
+	f := &fakeTokenServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mutex.Lock()
+		f.requests++
+		f.mutex.Unlock()
+
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token, err := handle(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_grant", "error_description": %q}`, err.Error())
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": token, "expires_in": expiresIn, "token_type": "Bearer"})
+	}))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeTokenServer) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.requests
+}
+
+// verifyJWT checks the RS256 signature of a JWT, and returns its header and claims
+func verifyJWT(jwt string, key *rsa.PublicKey) (map[string]interface{}, map[string]interface{}, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("not a jwt")
+	}
+
+	encoding := base64.RawURLEncoding
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, nil, err
+	}
+
+	decoded := []map[string]interface{}{}
+	for _, part := range parts[:2] {
+		data, err := encoding.DecodeString(part)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value := map[string]interface{}{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, nil, err
+		}
+		decoded = append(decoded, value)
+	}
+
+	return decoded[0], decoded[1], nil
+}
+
+func TestGCEServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *fakeTokenServer
+	server = newFakeTokenServer(t, 3600, func(r *http.Request) (string, error) {
+		if grant := r.PostForm.Get("grant_type"); grant != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			return "", fmt.Errorf("grant type %s", grant)
+		}
+
+		header, claims, err := verifyJWT(r.PostForm.Get("assertion"), &key.PublicKey)
+		if err != nil {
+			return "", fmt.Errorf("invalid assertion: %w", err)
+		}
+
+		want := map[string]interface{}{
+			"alg":   "RS256",
+			"kid":   "key-1",
+			"iss":   "gosh@test-project.iam.gserviceaccount.com",
+			"aud":   server.URL,
+			"scope": gceScope,
+		}
+		for name, value := range want {
+			got, ok := header[name]
+			if !ok {
+				got = claims[name]
+			}
+			if got != value {
+				return "", fmt.Errorf("%s = %v, want %v", name, got, value)
+			}
+		}
+
+		iat, _ := claims["iat"].(float64)
+		exp, _ := claims["exp"].(float64)
+		if exp-iat != 3600 {
+			return "", fmt.Errorf("token lifetime %v", exp-iat)
+		}
+
+		return "sa-token", nil
+	})
+
+	tokens := &gceTokenSource{
+		client: server.Client(),
+		creds: &gceCredentials{
+			Type:         "service_account",
+			ClientEmail:  "gosh@test-project.iam.gserviceaccount.com",
+			PrivateKeyID: "key-1",
+			PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			TokenURI:     server.URL,
+		},
+	}
+
+	for n := 0; n < 2; n++ {
+		token, err := tokens.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+
+		if token != "sa-token" {
+			t.Errorf("Token() = %s, want sa-token", token)
+		}
+	}
+
+	// the token is reused until it expires
+	if requests := server.count(); requests != 1 {
+		t.Errorf("%d token requests, want 1", requests)
+	}
+
+	// PKCS#1 keys are supported too
+	tokens.creds.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	tokens.token = ""
+	if _, err := tokens.Token(context.Background()); err != nil {
+		t.Errorf("Token() with a PKCS#1 key error = %v", err)
+	}
+
+	tokens.creds.PrivateKey = "not a key"
+	tokens.token = ""
+	if _, err := tokens.Token(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid gcp service account key") {
+		t.Errorf("Token() error = %v, want an invalid key error", err)
+	}
+}
+
+func TestGCERefreshToken(t *testing.T) {
+	issued := 0
+	server := newFakeTokenServer(t, 30, func(r *http.Request) (string, error) {
+		want := map[string]string{
+			"grant_type":    "refresh_token",
+			"client_id":     "client-id",
+			"client_secret": "client-secret",
+			"refresh_token": "refresh-token",
+		}
+		for name, value := range want {
+			if got := r.PostForm.Get(name); got != value {
+				return "", fmt.Errorf("%s = %s, want %s", name, got, value)
+			}
+		}
+
+		issued++
+		return fmt.Sprintf("user-token-%d", issued), nil
+	})
+
+	tokens := &gceTokenSource{
+		client: server.Client(),
+		creds: &gceCredentials{
+			Type:         "authorized_user",
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			RefreshToken: "refresh-token",
+			TokenURI:     server.URL,
+		},
+	}
+
+	// tokens expiring within a minute are refreshed
+	for n := 1; n <= 2; n++ {
+		token, err := tokens.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+
+		if want := fmt.Sprintf("user-token-%d", n); token != want {
+			t.Errorf("Token() = %s, want %s", token, want)
+		}
+	}
+
+	// token errors
+	tokens.creds.RefreshToken = "revoked"
+	tokens.token = ""
+
+	_, err := tokens.Token(context.Background())
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest || !strings.Contains(httpErr.Message, "invalid_grant") {
+		t.Errorf("Token() error = %v, want a 400 invalid_grant error", err)
+	}
+}
+
+func TestLoadGCECredentials(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		data string
+		want string
+		err  string
+	}{
+		{"service account", `{"type": "service_account", "project_id": "sa-project", "client_email": "gosh@sa-project.iam.gserviceaccount.com"}`, "sa-project", ""},
+		{"authorized user", `{"type": "authorized_user", "quota_project_id": "user-project", "refresh_token": "token"}`, "user-project", ""},
+		{"external account", `{"type": "external_account"}`, "", "unsupported gcp credentials type 'external_account'"},
+		{"invalid", `{"type": `, "", "invalid gcp credentials"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "-")+".json")
+			if err := os.WriteFile(path, []byte(test.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			creds, err := loadGCECredentials(path)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("loadGCECredentials() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loadGCECredentials() error = %v", err)
+			}
+
+			if project := creds.ProjectID + creds.QuotaProject; project != test.want {
+				t.Errorf("project = %s, want %s", project, test.want)
+			}
+		})
+	}
+
+	if _, err := loadGCECredentials(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("loadGCECredentials() of a missing file must fail")
+	}
+}
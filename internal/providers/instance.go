@@ -1,20 +1,42 @@
 package providers
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/yogin/gosh/internal/config"
 	"github.com/yogin/gosh/internal/utils"
 )
 
+const TagFieldPrefix = "tag:" // TagFieldPrefix is the prefix of fields referring to a tag (eg. tag:name)
+
+// FieldLabels are the default header labels of the instance fields
+var FieldLabels = map[string]string{
+	"id":         "ID",
+	"private_ip": "Private IP",
+	"public_ip":  "Public IP",
+	"state":      "State",
+	"az":         "AZ",
+	"type":       "Type",
+	"ami":        "AMI",
+	"vpc":        "VPC",
+	"ssm":        "SSM",
+	"running":    "Running",
+	"launched":   "Launched",
+}
+
 // type Instance interface {
 // 	GetID() string
 // }
 
 type Instance struct {
-	data *ec2.Instance
+	data  *ec2.Instance
+	extra []*Detail // provider specific details, for providers without raw EC2 data
 
 	ID        string
 	PrivateIP string
@@ -24,7 +46,12 @@ type Instance struct {
 	Launched  time.Time
 	Type      string
 	AMI       string
+	VPC       string
+	SSM       bool // managed by AWS SSM (only set when the profile uses SSM)
 	Tags      map[string]string
+
+	User string // ssh user from the provider (eg. inventory files), overrides the profile connect user
+	Port int    // ssh port from the provider (eg. inventory files), overrides the profile connect port
 }
 
 func NewInstance(ins *ec2.Instance) *Instance {
@@ -37,6 +64,7 @@ func NewInstance(ins *ec2.Instance) *Instance {
 		AZ:        *ins.Placement.AvailabilityZone,
 		Type:      *ins.InstanceType,
 		AMI:       *ins.ImageId,
+		VPC:       utils.SafeString(ins.VpcId),
 		Launched:  *ins.LaunchTime,
 		Tags:      make(map[string]string),
 	}
@@ -63,7 +91,7 @@ func (i *Instance) GetID() string {
 // RunningDescription returns how old the instance is as a string
 // eg. 1 day ago, 10 minutes ago, ...
 func (i *Instance) RunningDescription() string {
-	if i.State == "terminated" {
+	if i.State == "terminated" || i.Launched.IsZero() {
 		return ""
 	}
 
@@ -113,7 +141,7 @@ func (i *Instance) TagValues(names []string) []string {
 	for _, key := range names {
 		value := ""
 
-		if v, ok := i.Tags[key]; ok {
+		if v, ok := i.Tags[strings.ToLower(key)]; ok {
 			value = v
 		}
 
@@ -123,6 +151,91 @@ func (i *Instance) TagValues(names []string) []string {
 	return values
 }
 
+// Matches indicates if the term is found (case insensitive) in the instance ID, IPs,
+// state, type, AZ or any of the tag values
+func (i *Instance) Matches(term string) bool {
+	term = strings.ToLower(term)
+	if len(term) == 0 {
+		return true
+	}
+
+	fields := []string{i.ID, i.PrivateIP, i.PublicIP, i.State, i.Type, i.AZ}
+	for _, value := range i.Tags {
+		fields = append(fields, value)
+	}
+
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldValue returns the value of an instance field (see FieldLabels) or tag (eg. tag:name)
+func (i *Instance) FieldValue(field string) string {
+	if strings.HasPrefix(field, TagFieldPrefix) {
+		return i.TagValues([]string{strings.TrimPrefix(field, TagFieldPrefix)})[0]
+	}
+
+	switch field {
+	case "id":
+		return i.ID
+	case "private_ip":
+		return i.PrivateIP
+	case "public_ip":
+		return i.PublicIP
+	case "state":
+		return i.State
+	case "az":
+		return i.AZ
+	case "type":
+		return i.Type
+	case "ami":
+		return i.AMI
+	case "vpc":
+		return i.VPC
+	case "ssm":
+		if i.SSM {
+			return "yes"
+		}
+		return ""
+	case "running":
+		return i.RunningDescription()
+	case "launched":
+		if i.Launched.IsZero() {
+			return ""
+		}
+		return i.Launched.Format(config.DefaultTimeFormat)
+	}
+
+	return ""
+}
+
+// FieldLabel returns the default header label of a field
+func FieldLabel(field string) string {
+	if strings.HasPrefix(field, TagFieldPrefix) {
+		return "Tag:" + strings.TrimPrefix(field, TagFieldPrefix)
+	}
+
+	if label, ok := FieldLabels[field]; ok {
+		return label
+	}
+
+	return field
+}
+
+// IsValidField indicates if the field is a known instance field or a tag
+func IsValidField(field string) bool {
+	if strings.HasPrefix(field, TagFieldPrefix) {
+		return len(field) > len(TagFieldPrefix)
+	}
+
+	_, ok := FieldLabels[field]
+	return ok
+}
+
 // IsRunningLessThan indicates if the instance was started less than X minutes ago
 func (i *Instance) IsRunningLessThan(mins int) bool {
 	elapsed := time.Since(i.Launched)
@@ -159,3 +272,48 @@ func (a AWSInstanceSorter) Less(i, j int) bool {
 func (a AWSInstanceSorter) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
+
+// SortInstances sorts the instances by a field (see FieldValue), IPs are compared numerically and
+// the running time by launch time, instances with equal values keep their current order
+func SortInstances(instances []*Instance, field string, descending bool) {
+	sort.SliceStable(instances, func(i, j int) bool {
+		if descending {
+			return CompareField(instances[j], instances[i], field) < 0
+		}
+
+		return CompareField(instances[i], instances[j], field) < 0
+	})
+}
+
+// CompareField compares a field of two instances, returns -1, 0 or 1
+func CompareField(a, b *Instance, field string) int {
+	switch field {
+	case "private_ip", "public_ip":
+		return compareIP(a.FieldValue(field), b.FieldValue(field))
+
+	case "launched":
+		return a.Launched.Compare(b.Launched)
+
+	case "running":
+		// shortest running time first (ie. launched last)
+		return b.Launched.Compare(a.Launched)
+	}
+
+	return strings.Compare(strings.ToLower(a.FieldValue(field)), strings.ToLower(b.FieldValue(field)))
+}
+
+// compareIP compares IPs numerically, empty or invalid IPs are sorted last
+func compareIP(a, b string) int {
+	ipA, ipB := net.ParseIP(a).To16(), net.ParseIP(b).To16()
+
+	switch {
+	case ipA == nil && ipB == nil:
+		return 0
+	case ipA == nil:
+		return 1
+	case ipB == nil:
+		return -1
+	}
+
+	return bytes.Compare(ipA, ipB)
+}
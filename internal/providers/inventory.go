@@ -0,0 +1,233 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher is implemented by the providers reading local files, Watch calls changed when the
+// instances should be reloaded, until the context is done
+type Watcher interface {
+	Watch(ctx context.Context, changed func())
+}
+
+// watchInterval is how often the inventory files are checked for changes
+const watchInterval = 2 * time.Second
+
+// watchFiles calls changed when the modification time or size of one of the files changes
+func watchFiles(ctx context.Context, paths func() []string, changed func()) {
+	state := func() string {
+		parts := []string{}
+		for _, path := range paths() {
+			if info, err := os.Stat(path); err == nil {
+				parts = append(parts, fmt.Sprintf("%s:%d:%d", path, info.ModTime().UnixNano(), info.Size()))
+			} else {
+				parts = append(parts, path+":missing")
+			}
+		}
+
+		return strings.Join(parts, ",")
+	}
+
+	last := state()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := state(); current != last {
+				last = current
+				changed()
+			}
+		}
+	}
+}
+
+// LineError is an invalid entry of an inventory file
+type LineError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e *LineError) Error() string {
+	if e.Line <= 0 {
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+}
+
+// InventoryError lists the invalid entries of an inventory file
+type InventoryError struct {
+	Errors []*LineError
+}
+
+// Error returns the first error, the status bar only displays one line
+func (e *InventoryError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", e.Errors[0], len(e.Errors)-1)
+}
+
+// add adds an error at the line of the file
+func (e *InventoryError) add(path string, line int, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &LineError{Path: path, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the error, or nil when there are no errors
+func (e *InventoryError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(e.Errors, func(i, j int) bool {
+		if e.Errors[i].Path != e.Errors[j].Path {
+			return e.Errors[i].Path < e.Errors[j].Path
+		}
+
+		return e.Errors[i].Line < e.Errors[j].Line
+	})
+
+	return e
+}
+
+// inventoryHost is a host of an inventory file
+type inventoryHost struct {
+	ID        string            `json:"id" yaml:"id"`                 // unique id (default: name)
+	Name      string            `json:"name" yaml:"name"`             // host name, displayed as the name tag (default: id)
+	PrivateIP string            `json:"private_ip" yaml:"private_ip"` // private IP or host name
+	PublicIP  string            `json:"public_ip" yaml:"public_ip"`   // public IP or host name
+	User      string            `json:"user" yaml:"user"`             // ssh user
+	Port      int               `json:"port" yaml:"port"`             // ssh port
+	State     string            `json:"state" yaml:"state"`           // state (default: running)
+	Zone      string            `json:"zone" yaml:"zone"`             // zone or location (eg. rack-1)
+	Type      string            `json:"type" yaml:"type"`             // type (eg. hardware model)
+	Tags      map[string]string `json:"tags" yaml:"tags"`             // tags
+
+	path string            // file defining the host, when the inventory has several files
+	line int               // line defining the host, 0 when unknown
+	vars map[string]string // variables, displayed in the details
+}
+
+// hostnameRegexp matches DNS names
+var hostnameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_])?$`)
+
+// validAddress indicates if the address is an IP or a host name
+func validAddress(address string) bool {
+	return net.ParseIP(address) != nil || hostnameRegexp.MatchString(address)
+}
+
+// inventoryInstances validates the hosts, and returns the instances by ID, hosts without a state
+// are running (eg. they can be used as bastions)
+func inventoryInstances(path string, hosts []*inventoryHost, errs *InventoryError) map[string]*Instance {
+	insts := make(map[string]*Instance)
+	defined := make(map[string]*inventoryHost) // first host of each ID
+
+	for _, host := range hosts {
+		if len(host.path) == 0 {
+			host.path = path
+		}
+		if len(host.ID) == 0 {
+			host.ID = host.Name
+		}
+		if len(host.Name) == 0 {
+			host.Name = host.ID
+		}
+		if len(host.State) == 0 {
+			host.State = "running"
+		}
+
+		valid := true
+		invalid := func(format string, args ...interface{}) {
+			errs.add(host.path, host.line, format, args...)
+			valid = false
+		}
+
+		first, duplicate := defined[host.ID]
+		switch {
+		case len(host.ID) == 0:
+			invalid("host without id or name")
+		case duplicate && first.line > 0 && first.path == host.path:
+			invalid("duplicate host '%s' (first defined on line %d)", host.ID, first.line)
+		case duplicate && first.line > 0:
+			invalid("duplicate host '%s' (first defined in %s:%d)", host.ID, first.path, first.line)
+		case duplicate:
+			invalid("duplicate host '%s' (first defined in %s)", host.ID, first.path)
+		default:
+			defined[host.ID] = host
+		}
+
+		if len(host.PrivateIP) == 0 && len(host.PublicIP) == 0 {
+			invalid("host '%s' has no private_ip or public_ip", host.ID)
+		}
+		if len(host.PrivateIP) > 0 && !validAddress(host.PrivateIP) {
+			invalid("host '%s' has an invalid private_ip '%s'", host.ID, host.PrivateIP)
+		}
+		if len(host.PublicIP) > 0 && !validAddress(host.PublicIP) {
+			invalid("host '%s' has an invalid public_ip '%s'", host.ID, host.PublicIP)
+		}
+		if host.Port < 0 || host.Port > 65535 {
+			invalid("host '%s' has an invalid port %d", host.ID, host.Port)
+		}
+
+		if !valid {
+			continue
+		}
+
+		i := &Instance{
+			ID:        host.ID,
+			PrivateIP: host.PrivateIP,
+			PublicIP:  host.PublicIP,
+			State:     host.State,
+			AZ:        host.Zone,
+			Type:      host.Type,
+			User:      host.User,
+			Port:      host.Port,
+			Tags:      make(map[string]string),
+		}
+
+		for key, value := range host.Tags {
+			i.Tags[strings.ToLower(key)] = value
+		}
+		if _, ok := i.Tags["name"]; !ok {
+			i.Tags["name"] = host.Name
+		}
+
+		i.addExtra("Instance", "Name", host.Name)
+		i.addExtra("Connect", "User", host.User)
+		if host.Port > 0 {
+			i.addExtra("Connect", "Port", fmt.Sprint(host.Port))
+		}
+		if host.line > 0 {
+			i.addExtra("Connect", "Inventory", fmt.Sprintf("%s:%d", host.path, host.line))
+		} else {
+			i.addExtra("Connect", "Inventory", host.path)
+		}
+
+		keys := make([]string, 0, len(host.vars))
+		for key := range host.vars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			i.addExtra("Variables", key, host.vars[key])
+		}
+
+		insts[i.ID] = i
+	}
+
+	return insts
+}
@@ -0,0 +1,31 @@
+package providers
+
+import "context"
+
+// Action is a lifecycle action changing the state of an instance
+type Action string
+
+const (
+	ActionStart     Action = "start"
+	ActionStop      Action = "stop"
+	ActionHibernate Action = "hibernate"
+	ActionReboot    Action = "reboot"
+	ActionTerminate Action = "terminate"
+)
+
+// Destructive indicates if the action interrupts (stop, hibernate, reboot) or destroys (terminate)
+// the instance, such actions require typing the instance name to be confirmed
+func (a Action) Destructive() bool {
+	switch a {
+	case ActionStop, ActionHibernate, ActionReboot, ActionTerminate:
+		return true
+	default:
+		return false
+	}
+}
+
+// LifecycleManager is implemented by providers able to change the state of instances
+type LifecycleManager interface {
+	Actions(instance *Instance) []Action                                    // Actions returns the actions available in the current state of the instance
+	RunAction(ctx context.Context, instance *Instance, action Action) error // RunAction requests the action, the new state is visible on the next refresh
+}
@@ -0,0 +1,19 @@
+package providers
+
+import "testing"
+
+func TestActionDestructive(t *testing.T) {
+	tests := map[Action]bool{
+		ActionStart:     false,
+		ActionStop:      true,
+		ActionHibernate: true,
+		ActionReboot:    true,
+		ActionTerminate: true,
+	}
+
+	for action, want := range tests {
+		if got := action.Destructive(); got != want {
+			t.Errorf("%s.Destructive() = %v, want %v", action, got, want)
+		}
+	}
+}
@@ -1,28 +1,61 @@
 package providers
 
-import "github.com/yogin/gosh/internal/config"
+import (
+	"context"
+
+	"github.com/yogin/gosh/internal/config"
+)
 
 type ProviderType string
 
 const (
-	ProviderTypeAWS ProviderType = "aws"
+	ProviderTypeAWS   ProviderType = "aws"
+	ProviderTypeGCP   ProviderType = "gcp"
+	ProviderTypeAzure ProviderType = "azure"
+
+	ProviderTypeDigitalOcean ProviderType = "digitalocean"
+	ProviderTypeHetzner      ProviderType = "hetzner"
+	ProviderTypeLinode       ProviderType = "linode"
+
+	ProviderTypeFile    ProviderType = "file"
+	ProviderTypeAnsible ProviderType = "ansible"
 )
 
 type Provider interface {
-	Type() ProviderType                 // Type returns the provider type
-	Headers() []string                  // Headers returns the table headers
-	LoadInstances() error               // LoadInstances queries the provider for all instances
-	InstancesCount() int                // InstancesCount returns the number of instances
-	GetTags() []string                  // GetTags returns the list of tags across all instances
-	GetInstances() []*Instance          // GetInstances returns the list of instances
-	GetInstanceByID(string) *Instance   // GetInstanceByID returns an instance by ID
-	GetInstanceIPByID(id string) string // GetInstanceIPByID returns an instance IP by ID (public or private)
+	Type() ProviderType                  // Type returns the provider type
+	Headers() []string                   // Headers returns the table headers
+	Fields() []string                    // Fields returns the instance fields matching the table headers
+	LoadInstances(context.Context) error // LoadInstances queries the provider for all instances
+	InstancesCount() int                 // InstancesCount returns the number of instances
+	GetTags() []string                   // GetTags returns the list of tags across all instances
+	GetInstances() []*Instance           // GetInstances returns the list of instances
+	GetInstanceByID(string) *Instance    // GetInstanceByID returns an instance by ID
+	GetInstanceIPByID(id string) string  // GetInstanceIPByID returns an instance IP by ID (public or private)
+}
+
+// SSHKeyPusher is implemented by providers able to push a temporary ssh public key to an instance
+type SSHKeyPusher interface {
+	PushSSHPublicKey(ctx context.Context, instance *Instance, user string, publicKey string) error
 }
 
 func NewProvider(provider string, profile *config.Profile) Provider {
 	switch provider {
 	case string(ProviderTypeAWS):
 		return NewAWSProvider(profile)
+	case string(ProviderTypeGCP), "gce":
+		return NewGCEProvider(profile)
+	case string(ProviderTypeAzure):
+		return NewAzureProvider(profile)
+	case string(ProviderTypeDigitalOcean), "do":
+		return NewDigitalOceanProvider(profile)
+	case string(ProviderTypeHetzner), "hcloud":
+		return NewHetznerProvider(profile)
+	case string(ProviderTypeLinode):
+		return NewLinodeProvider(profile)
+	case string(ProviderTypeFile):
+		return NewFileProvider(profile)
+	case string(ProviderTypeAnsible):
+		return NewAnsibleProvider(profile)
 	default:
 		return nil
 	}
@@ -0,0 +1,432 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// AnsibleProvider reads the instances from an Ansible inventory: an INI or YAML file, a directory of
+// inventory files, or a dynamic inventory script, with the group_vars and host_vars next to it
+type AnsibleProvider struct {
+	instanceStore
+
+	path       string
+	files      []string // files read by the last load, watched for changes
+	filesMutex sync.Mutex
+}
+
+func NewAnsibleProvider(profile *config.Profile) *AnsibleProvider {
+	return &AnsibleProvider{
+		instanceStore: newInstanceStore(profile, AnsibleDefaultTags),
+		path:          utils.ExpandHome(profile.Inventory),
+	}
+}
+
+func (p *AnsibleProvider) Type() ProviderType {
+	return ProviderTypeAnsible
+}
+
+func (p *AnsibleProvider) Headers() []string {
+	return []string{"Host", "Private IP", "Public IP", "Groups"}
+}
+
+func (p *AnsibleProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", TagFieldPrefix + "groups"}
+}
+
+// LoadInstances reads the inventory, the previous instances are kept when it is invalid
+func (p *AnsibleProvider) LoadInstances(ctx context.Context) error {
+	if len(p.path) == 0 {
+		return errors.New("no inventory configured (set the profile inventory)")
+	}
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("unable to read inventory: %w", err)
+	}
+
+	inv := newAnsibleInventory()
+	errs := &InventoryError{}
+	files := []string{}
+
+	paths := []string{p.path}
+	base := filepath.Dir(p.path)
+
+	if info.IsDir() {
+		base = p.path
+		if paths, err = ansibleInventoryFiles(p.path); err != nil {
+			return fmt.Errorf("unable to read inventory: %w", err)
+		}
+	}
+
+	for _, path := range paths {
+		if err := p.parse(ctx, inv, path, errs); err != nil {
+			return err
+		}
+		files = append(files, path)
+	}
+
+	groupVars, groupFiles := ansibleVarFiles(filepath.Join(base, "group_vars"), errs)
+	hostVars, hostFiles := ansibleVarFiles(filepath.Join(base, "host_vars"), errs)
+
+	hosts := inv.resolve(groupVars, hostVars, errs)
+	insts := inventoryInstances(p.path, hosts, errs)
+
+	p.filesMutex.Lock()
+	p.files = append(append(files, groupFiles...), hostFiles...)
+	p.filesMutex.Unlock()
+
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	p.setInstances(insts)
+
+	return nil
+}
+
+// parse reads an inventory file, or runs a dynamic inventory script
+func (p *AnsibleProvider) parse(ctx context.Context, inv *ansibleInventory, path string, errs *InventoryError) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to read inventory: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	// executable files are dynamic inventory scripts, as for ansible
+	if info.Mode()&0111 != 0 && ext != ".yml" && ext != ".yaml" && ext != ".ini" && ext != ".json" {
+		data, err := runInventoryScript(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		parseAnsibleScript(inv, path, data, errs)
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read inventory: %w", err)
+	}
+
+	switch ext {
+	case ".yml", ".yaml", ".json":
+		parseAnsibleYAML(inv, path, data, errs)
+	default:
+		parseAnsibleINI(inv, path, data, errs)
+	}
+
+	return nil
+}
+
+// Watch reloads the instances when the inventory or variable files change
+func (p *AnsibleProvider) Watch(ctx context.Context, changed func()) {
+	watchFiles(ctx, func() []string {
+		p.filesMutex.Lock()
+		defer p.filesMutex.Unlock()
+
+		return append([]string{p.path}, p.files...)
+	}, changed)
+}
+
+// runInventoryScript returns the output of a dynamic inventory script called with --list
+func runInventoryScript(ctx context.Context, path string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	// the script runs in its directory, a relative path would be resolved from there
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	cmd := exec.CommandContext(ctx, path, "--list")
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+			lines := strings.Split(message, "\n")
+			return nil, fmt.Errorf("inventory script %s failed: %s", path, strings.TrimSpace(lines[len(lines)-1]))
+		}
+
+		return nil, fmt.Errorf("inventory script %s failed: %w", path, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ansibleIgnoredFile indicates if a file of an inventory directory is ignored, as for ansible
+func ansibleIgnoredFile(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+
+	switch filepath.Ext(name) {
+	case ".orig", ".cfg", ".retry", ".pyc", ".pyo", ".bak", ".md", ".txt":
+		return true
+	}
+
+	return false
+}
+
+// ansibleInventoryFiles returns the inventory files of a directory, in name order
+func ansibleInventoryFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || ansibleIgnoredFile(entry.Name()) {
+			continue
+		}
+
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+// ansibleVarFiles reads the variables of a group_vars or host_vars directory, by group or host name,
+// from <name>, <name>.yml, <name>.yaml or <name>.json files, or the files of a <name> directory
+func ansibleVarFiles(dir string, errs *InventoryError) (map[string]map[string]string, []string) {
+	vars := make(map[string]map[string]string)
+	files := []string{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return vars, files // no variables
+	}
+
+	for _, entry := range entries {
+		if ansibleIgnoredFile(entry.Name()) {
+			continue
+		}
+
+		name := entry.Name()
+		paths := []string{filepath.Join(dir, name)}
+
+		if entry.IsDir() {
+			children, err := os.ReadDir(paths[0])
+			if err != nil {
+				continue
+			}
+
+			paths = []string{}
+			for _, child := range children {
+				if !child.IsDir() && !ansibleIgnoredFile(child.Name()) {
+					paths = append(paths, filepath.Join(dir, name, child.Name()))
+				}
+			}
+		} else {
+			switch ext := filepath.Ext(name); ext {
+			case ".yml", ".yaml", ".json":
+				name = strings.TrimSuffix(name, ext)
+			}
+		}
+
+		if _, ok := vars[name]; !ok {
+			vars[name] = make(map[string]string)
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs.add(path, 0, "%s", err)
+				continue
+			}
+
+			// encrypted variables can't be read without the vault password
+			if bytes.HasPrefix(data, []byte("$ANSIBLE_VAULT")) {
+				continue
+			}
+
+			values := make(map[string]interface{})
+			if err := yaml.Unmarshal(data, &values); err != nil {
+				line, message := yamlError(err)
+				errs.add(path, line, "%s", message)
+				continue
+			}
+
+			for key, value := range ansibleVars(values) {
+				vars[name][key] = value
+			}
+			files = append(files, path)
+		}
+	}
+
+	return vars, files
+}
+
+// resolve returns the hosts with their variables, by increasing precedence: the inventory group
+// variables (parent groups first), the group_vars files, the inventory host variables and the
+// host_vars files
+func (inv *ansibleInventory) resolve(groupVars map[string]map[string]string, hostVars map[string]map[string]string, errs *InventoryError) []*inventoryHost {
+	parents := make(map[string][]string)
+	for name, g := range inv.groups {
+		for _, child := range g.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+
+	memberships := make(map[string][]string)
+	for name, g := range inv.groups {
+		for _, host := range g.hosts {
+			memberships[host] = append(memberships[host], name)
+		}
+	}
+
+	depths := make(map[string]int)
+	var depth func(group string, visiting map[string]bool) int
+	depth = func(group string, visiting map[string]bool) int {
+		if group == "all" {
+			return 0
+		}
+		if d, ok := depths[group]; ok {
+			return d
+		}
+		if visiting[group] {
+			return 1 // cycle
+		}
+		visiting[group] = true
+
+		d := 1
+		for _, parent := range parents[group] {
+			if pd := depth(parent, visiting) + 1; pd > d {
+				d = pd
+			}
+		}
+
+		depths[group] = d
+		return d
+	}
+
+	hosts := []*inventoryHost{}
+	for _, name := range inv.order {
+		host := inv.hosts[name]
+
+		// the groups of the host and their ancestors
+		groups := map[string]struct{}{"all": {}}
+		queue := append([]string{}, memberships[name]...)
+		for len(queue) > 0 {
+			group := queue[0]
+			queue = queue[1:]
+
+			if _, ok := groups[group]; ok {
+				continue
+			}
+			groups[group] = struct{}{}
+			queue = append(queue, parents[group]...)
+		}
+
+		ordered := make([]string, 0, len(groups))
+		for group := range groups {
+			ordered = append(ordered, group)
+		}
+		sort.Slice(ordered, func(i, j int) bool {
+			di, dj := depth(ordered[i], map[string]bool{}), depth(ordered[j], map[string]bool{})
+			if di != dj {
+				return di < dj
+			}
+			return ordered[i] < ordered[j]
+		})
+
+		vars := make(map[string]string)
+		for _, group := range ordered {
+			for key, value := range inv.groups[group].vars {
+				vars[key] = value
+			}
+		}
+		for _, group := range ordered {
+			for key, value := range groupVars[group] {
+				vars[key] = value
+			}
+		}
+		for key, value := range host.vars {
+			vars[key] = value
+		}
+		for key, value := range hostVars[name] {
+			vars[key] = value
+		}
+
+		h := &inventoryHost{
+			ID:   name,
+			Name: name,
+			User: ansibleVar(vars, "ansible_user", "ansible_ssh_user"),
+			Tags: make(map[string]string),
+			path: host.path,
+			line: host.line,
+			vars: vars,
+		}
+
+		// connect to the inventory hostname when there is no ansible_host
+		address := ansibleVar(vars, "ansible_host", "ansible_ssh_host")
+		if len(address) == 0 {
+			address = name
+		}
+		if ip := net.ParseIP(address); ip != nil && !ip.IsPrivate() && !ip.IsLoopback() {
+			h.PublicIP = address
+		} else {
+			h.PrivateIP = address
+		}
+
+		if port := ansibleVar(vars, "ansible_port", "ansible_ssh_port"); len(port) > 0 {
+			n, err := strconv.Atoi(port)
+			if err != nil {
+				errs.add(host.path, host.line, "host '%s' has an invalid ansible_port '%s'", name, port)
+			}
+			h.Port = n
+		}
+
+		// group tags are prefixed, groups can be named like other tags (eg. name)
+		names := []string{}
+		for _, group := range ordered {
+			if group != "all" && group != "ungrouped" {
+				h.Tags[ansibleGroupTagPrefix+group] = "true"
+				names = append(names, group)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			h.Tags["groups"] = strings.Join(names, ",")
+		}
+
+		hosts = append(hosts, h)
+	}
+
+	return hosts
+}
+
+// ansibleGroupTagPrefix is the prefix of the tags of the groups of a host (eg. group:web)
+const ansibleGroupTagPrefix = "group:"
+
+// AnsibleDefaultTags are the host variables and groups displayed by default
+var AnsibleDefaultTags = []string{"name", "groups", "env", "environment", "role"}
+
+// ansibleVar returns the first variable set, templated values (eg. {{ var }}) can't be resolved
+// and are ignored
+func ansibleVar(vars map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := vars[name]; ok && len(value) > 0 && !strings.Contains(value, "{{") {
+			return value
+		}
+	}
+
+	return ""
+}
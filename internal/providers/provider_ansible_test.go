@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// ansibleTestdata is the directory of the inventories used by the tests
+var ansibleTestdata = filepath.Join("testdata", "ansible")
+
+// loadAnsible loads an inventory of the test data
+func loadAnsible(t *testing.T, inventory string) (*AnsibleProvider, error) {
+	t.Helper()
+
+	p := NewAnsibleProvider(&config.Profile{ID: "ansible", Inventory: filepath.Join(ansibleTestdata, inventory)})
+	return p, p.LoadInstances(context.Background())
+}
+
+// instanceVars returns the variables of an instance, displayed in its details
+func instanceVars(i *Instance) map[string]string {
+	vars := make(map[string]string)
+	for _, detail := range i.extra {
+		if detail.Section == "Variables" {
+			vars[detail.Key] = detail.Value
+		}
+	}
+
+	return vars
+}
+
+// ansibleHostTest is the expected connection settings, tags and variables of a host
+type ansibleHostTest struct {
+	id   string
+	ip   string
+	user string
+	port int
+	tags map[string]string
+	vars map[string]string
+}
+
+func checkAnsibleHosts(t *testing.T, p *AnsibleProvider, ids []string, hosts []ansibleHostTest) {
+	t.Helper()
+
+	if got := strings.Join(instanceIDs(p), " "); got != strings.Join(ids, " ") {
+		t.Errorf("hosts = %s, want %s", got, strings.Join(ids, " "))
+	}
+
+	for _, host := range hosts {
+		i := p.GetInstanceByID(host.id)
+		if i == nil {
+			t.Errorf("host %s not loaded", host.id)
+			continue
+		}
+
+		if ip := i.PrivateIP + i.PublicIP; ip != host.ip || i.User != host.user || i.Port != host.port {
+			t.Errorf("host %s connects to %s@%s:%d, want %s@%s:%d", host.id, i.User, ip, i.Port, host.user, host.ip, host.port)
+		}
+
+		for key, value := range host.tags {
+			if got, ok := i.Tags[key]; value == "" && ok || value != "" && got != value {
+				t.Errorf("host %s tag %s = %q, want %q", host.id, key, got, value)
+			}
+		}
+
+		vars := instanceVars(i)
+		for key, value := range host.vars {
+			if vars[key] != value {
+				t.Errorf("host %s variable %s = %q, want %q", host.id, key, vars[key], value)
+			}
+		}
+	}
+}
+
+func TestAnsibleINI(t *testing.T) {
+	ids := []string{"bastion.example.com", "db-a.internal", "db-b.internal", "web-canary", "web01", "web02", "web03"}
+	hosts := []ansibleHostTest{
+		{
+			id: "bastion.example.com", ip: "bastion.example.com", user: "jump",
+			tags: map[string]string{"name": "bastion.example.com", "groups": ""},
+		},
+		{
+			// host_vars, then inventory host vars, then group_vars, then inventory group vars
+			id: "web01", ip: "10.0.1.1", user: "root",
+			tags: map[string]string{"name": "web01", "group:name": "true", "group:web": "true", "group:prod": "true", "groups": "name,prod,web"},
+			vars: map[string]string{"env": "production", "tier": "web", "http_port": "8080", "ntp": "ntp.internal"},
+		},
+		{
+			id: "web03", ip: "web03", user: "deploy",
+			tags: map[string]string{"name": "web03", "group:name": "", "groups": "prod,web"},
+			vars: map[string]string{"env": "production", "tier": "web", "http_port": "8080"},
+		},
+		{
+			id: "web-canary", ip: "web-canary", user: "admin", port: 2222,
+			vars: map[string]string{"canary": "true", "ansible_port": "2222"},
+		},
+		{
+			// child groups override the variables of their parents
+			id: "db-a.internal", ip: "db-a.internal", user: "admin",
+			tags: map[string]string{"group:db": "true", "group:web": "", "groups": "db,prod"},
+			vars: map[string]string{"env": "production", "tier": "prod", "ntp": "ntp.internal"},
+		},
+	}
+
+	for _, inventory := range []string{"ini/hosts.ini", "ini"} {
+		t.Run(inventory, func(t *testing.T) {
+			p, err := loadAnsible(t, inventory)
+			if err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			checkAnsibleHosts(t, p, ids, hosts)
+
+			// the inventory, group_vars and host_vars files are watched
+			if len(p.files) != 5 {
+				t.Errorf("watched files = %v, want 5 files", p.files)
+			}
+		})
+	}
+}
+
+func TestAnsibleYAML(t *testing.T) {
+	p, err := loadAnsible(t, "yaml/hosts.yml")
+	if err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	checkAnsibleHosts(t, p,
+		[]string{"bastion.example.com", "db-a.internal", "db-b.internal", "web-canary", "web01", "web02", "web03"},
+		[]ansibleHostTest{
+			{id: "bastion.example.com", ip: "bastion.example.com", user: "jump", tags: map[string]string{"groups": ""}},
+			{
+				id: "web02", ip: "web02", user: "deploy",
+				tags: map[string]string{"group:web": "true", "group:prod": "true", "groups": "prod,web"},
+				vars: map[string]string{"env": "prod", "tier": "web"},
+			},
+			{id: "web-canary", ip: "web-canary", user: "admin", port: 2222},
+			{id: "db-b.internal", ip: "db-b.internal", user: "admin", tags: map[string]string{"groups": "db,prod"}},
+		})
+}
+
+func TestAnsibleScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inventory scripts are shell scripts")
+	}
+
+	p, err := loadAnsible(t, "script/inventory")
+	if err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	checkAnsibleHosts(t, p,
+		[]string{"db01", "web01", "web02"},
+		[]ansibleHostTest{
+			{
+				id: "web01", ip: "10.0.1.1", user: "deploy", port: 2222,
+				tags: map[string]string{"groups": "prod,web"},
+				vars: map[string]string{"env": "prod", "tier": "web"},
+			},
+			{id: "db01", ip: "db01", tags: map[string]string{"groups": "db,prod"}, vars: map[string]string{"env": "prod"}},
+		})
+
+	_, err = loadAnsible(t, "script-failure/inventory")
+	if err == nil || !strings.HasSuffix(err.Error(), "failed: unable to reach the cmdb") {
+		t.Errorf("LoadInstances() error = %v, want the last line of the script errors", err)
+	}
+}
+
+func TestAnsibleErrors(t *testing.T) {
+	_, err := loadAnsible(t, "errors/hosts.ini")
+
+	var invErr *InventoryError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("LoadInstances() error = %v, want an inventory error", err)
+	}
+
+	path := filepath.Join(ansibleTestdata, "errors", "hosts.ini")
+	want := []string{
+		path + ":1: invalid section '[web'",
+		path + ":2: invalid section type 'hosts' (expected vars or children)",
+		path + ":4: invalid host range 'web[01:xx]'",
+		path + ":5: invalid host variable 'port' (expected key=value)",
+		path + ":6: host 'web02' has an invalid ansible_port 'ssh'",
+		path + ":8: invalid variable 'novalue' (expected key=value)",
+	}
+
+	got := []string{}
+	for _, lineErr := range invErr.Errors {
+		got = append(got, lineErr.Error())
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExpandHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		err     bool
+	}{
+		{"web", "web", false},
+		{"web[1:3]", "web1 web2 web3", false},
+		{"web[01:03].example.com", "web01.example.com web02.example.com web03.example.com", false},
+		{"web[0:6:3]", "web0 web3 web6", false},
+		{"db-[a:c]", "db-a db-b db-c", false},
+		{"rack[1:2]-[a:b]", "rack1-a rack1-b rack2-a rack2-b", false},
+		{"web[3:1]", "", true},
+		{"web[1:3:0]", "", true},
+		{"web[a:10]", "", true},
+		{"web[1:3", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			names, err := expandHostPattern(test.pattern)
+			if (err != nil) != test.err {
+				t.Fatalf("expandHostPattern(%q) error = %v, want error %v", test.pattern, err, test.err)
+			}
+
+			if got := strings.Join(names, " "); got != test.want {
+				t.Errorf("expandHostPattern(%q) = %s, want %s", test.pattern, got, test.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package providers
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"net/http"
 	"sort"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ec2instanceconnect"
+	"github.com/aws/aws-sdk-go/service/ec2instanceconnect/ec2instanceconnectiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/yogin/gosh/internal/config"
 )
 
@@ -16,9 +24,12 @@ var AWSDefaultTags = []string{"name", "env", "environment", "stage", "role", "bu
 
 type AWSProvider struct {
 	profile   *config.Profile
-	svc       *ec2.EC2
+	svc       ec2iface.EC2API
+	ssm       ssmiface.SSMAPI
+	connect   ec2instanceconnectiface.EC2InstanceConnectAPI
 	instances map[string]*Instance
 	mutex     sync.Mutex
+	err       error // session error, returned when loading instances
 }
 
 func NewAWSProvider(profile *config.Profile) *AWSProvider {
@@ -40,54 +51,164 @@ func NewAWSProvider(profile *config.Profile) *AWSProvider {
 	conf := aws.Config{
 		Credentials: creds,
 		// CredentialsChainVerboseErrors: aws.Bool(true),
-		// HTTPClient:                    &http.Client{Timeout: 10 * time.Second},
+		HTTPClient: &http.Client{Timeout: p.profile.GetTimeout()},
 	}
 
 	if len(p.profile.Region) > 0 {
 		conf.Region = aws.String(p.profile.Region)
 	}
 
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
+	sess, err := session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 		Config:            conf,
 		Profile:           p.profile.Name,
-	}))
+	})
+	if err != nil {
+		p.err = fmt.Errorf("unable to create aws session for profile '%s': %w", p.profile.Name, err)
+		return p
+	}
 
 	p.svc = ec2.New(sess)
+	p.ssm = ssm.New(sess)
+	p.connect = ec2instanceconnect.New(sess)
 
 	return p
 }
 
+// NewAWSProviderWithClient returns a provider using the given EC2, SSM and EC2 Instance Connect clients (eg. stubs for tests)
+func NewAWSProviderWithClient(profile *config.Profile, svc ec2iface.EC2API, ssmSvc ssmiface.SSMAPI, connectSvc ec2instanceconnectiface.EC2InstanceConnectAPI) *AWSProvider {
+	return &AWSProvider{
+		profile:   profile,
+		svc:       svc,
+		ssm:       ssmSvc,
+		connect:   connectSvc,
+		instances: make(map[string]*Instance),
+		mutex:     sync.Mutex{},
+	}
+}
+
 func (p *AWSProvider) Type() ProviderType {
 	return ProviderTypeAWS
 }
 
 func (p *AWSProvider) Headers() []string {
 	// return []string{"ID", "Name", "Type", "State", "Public IP", "Private IP"}
-	return []string{"ID", "Private IP", "Public IP", "State", "AZ", "Type", "AMI", "Running"}
+	headers := []string{"ID", "Private IP", "Public IP", "State", "AZ", "Type", "AMI", "Running"}
+	if p.profile.Connect.UsesSSM() {
+		headers = append(headers, "SSM")
+	}
+
+	return headers
 }
 
-func (p *AWSProvider) LoadInstances() error {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+func (p *AWSProvider) Fields() []string {
+	fields := []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+	if p.profile.Connect.UsesSSM() {
+		fields = append(fields, "ssm")
+	}
 
-	res, err := p.svc.DescribeInstances(&ec2.DescribeInstancesInput{})
-	if err != nil {
-		log.Fatalln(err.Error())
+	return fields
+}
+
+// LoadInstances lists the instances, the lock is only held to replace them so that the UI can
+// read the previous instances during the requests
+func (p *AWSProvider) LoadInstances(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	input := &ec2.DescribeInstancesInput{
+		Filters: p.apiFilters(),
 	}
 
 	insts := make(map[string]*Instance)
-	for _, reservation := range res.Reservations {
-		for _, instance := range reservation.Instances {
-			i := NewInstance(instance)
-			insts[i.ID] = i
+	err := p.svc.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				i := NewInstance(instance)
+				insts[i.ID] = i
+			}
+		}
+		return true
+	})
+	if err != nil {
+		// keep the previously loaded instances
+		return err
+	}
+
+	if p.usesSSM() {
+		if err := p.loadSSMInstances(ctx, insts); err != nil {
+			return fmt.Errorf("unable to list ssm managed instances: %w", err)
 		}
 	}
+
+	p.mutex.Lock()
 	p.instances = insts
+	p.mutex.Unlock()
 
 	return nil
 }
 
+// usesSSM indicates if the SSM managed instances should be loaded
+func (p *AWSProvider) usesSSM() bool {
+	if p.ssm == nil {
+		return false
+	}
+
+	if p.profile.Connect.UsesSSM() {
+		return true
+	}
+
+	for _, column := range p.profile.Columns {
+		if column.Field == "ssm" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadSSMInstances marks the instances managed by SSM (with an online agent)
+func (p *AWSProvider) loadSSMInstances(ctx context.Context, insts map[string]*Instance) error {
+	input := &ssm.DescribeInstanceInformationInput{}
+
+	return p.ssm.DescribeInstanceInformationPagesWithContext(ctx, input, func(page *ssm.DescribeInstanceInformationOutput, lastPage bool) bool {
+		for _, info := range page.InstanceInformationList {
+			if info.InstanceId == nil || aws.StringValue(info.PingStatus) != ssm.PingStatusOnline {
+				continue
+			}
+
+			if i, ok := insts[*info.InstanceId]; ok {
+				i.SSM = true
+			}
+		}
+		return true
+	})
+}
+
+// apiFilters returns the profile server-side filters (eg. instance-state-name, tag:env, vpc-id)
+func (p *AWSProvider) apiFilters() []*ec2.Filter {
+	if len(p.profile.APIFilters) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(p.profile.APIFilters))
+	for name := range p.profile.APIFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filters := make([]*ec2.Filter, 0, len(names))
+	for _, name := range names {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(name),
+			Values: aws.StringSlice(p.profile.APIFilters[name]),
+		})
+	}
+
+	return filters
+}
+
 func (p *AWSProvider) InstancesCount() int {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
@@ -157,3 +278,101 @@ func (p *AWSProvider) GetInstanceIPByID(id string) string {
 
 	return instance.PrivateIP
 }
+
+// PushSSHPublicKey pushes a public key with EC2 Instance Connect, usable for 60 seconds to connect as the user
+func (p *AWSProvider) PushSSHPublicKey(ctx context.Context, instance *Instance, user string, publicKey string) error {
+	if p.connect == nil {
+		return fmt.Errorf("ec2 instance connect is not available")
+	}
+
+	input := &ec2instanceconnect.SendSSHPublicKeyInput{
+		InstanceId:       aws.String(instance.ID),
+		InstanceOSUser:   aws.String(user),
+		AvailabilityZone: aws.String(instance.AZ),
+		SSHPublicKey:     aws.String(publicKey),
+	}
+
+	res, err := p.connect.SendSSHPublicKeyWithContext(ctx, input)
+	if err != nil {
+		return instanceConnectError(err)
+	}
+
+	if !aws.BoolValue(res.Success) {
+		return fmt.Errorf("ec2 instance connect did not accept the key (request %s)", aws.StringValue(res.RequestId))
+	}
+
+	return nil
+}
+
+// instanceConnectError describes the common EC2 Instance Connect failures
+func instanceConnectError(err error) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+
+	switch aerr.Code() {
+	case "AccessDeniedException":
+		return fmt.Errorf("missing IAM permission ec2-instance-connect:SendSSHPublicKey: %s", aerr.Message())
+	case ec2instanceconnect.ErrCodeEC2InstanceNotFoundException:
+		return fmt.Errorf("instance not found by ec2 instance connect: %s", aerr.Message())
+	case ec2instanceconnect.ErrCodeInvalidArgsException:
+		return fmt.Errorf("instance does not support ec2 instance connect (unsupported AMI or user): %s", aerr.Message())
+	case ec2instanceconnect.ErrCodeAuthException:
+		return fmt.Errorf("not authorized to use ec2 instance connect: %s", aerr.Message())
+	}
+
+	return err
+}
+
+// Actions returns the lifecycle actions available in the current state of the instance
+func (p *AWSProvider) Actions(instance *Instance) []Action {
+	if p.profile.ReadOnly {
+		return nil
+	}
+
+	switch instance.State {
+	case ec2.InstanceStateNamePending:
+		return []Action{ActionTerminate}
+	case ec2.InstanceStateNameRunning:
+		actions := []Action{ActionStop, ActionReboot, ActionTerminate}
+
+		// hibernation must be enabled when launching the instance
+		if instance.data != nil && instance.data.HibernationOptions != nil && aws.BoolValue(instance.data.HibernationOptions.Configured) {
+			actions = []Action{ActionStop, ActionHibernate, ActionReboot, ActionTerminate}
+		}
+
+		return actions
+	case ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped:
+		return []Action{ActionStart, ActionTerminate}
+	default:
+		return nil
+	}
+}
+
+// RunAction requests a lifecycle action on the instance
+func (p *AWSProvider) RunAction(ctx context.Context, instance *Instance, action Action) error {
+	if p.profile.ReadOnly {
+		return fmt.Errorf("profile '%s' is read-only", p.profile.ID)
+	}
+
+	ids := []*string{aws.String(instance.ID)}
+	var err error
+
+	switch action {
+	case ActionStart:
+		_, err = p.svc.StartInstancesWithContext(ctx, &ec2.StartInstancesInput{InstanceIds: ids})
+	case ActionStop:
+		_, err = p.svc.StopInstancesWithContext(ctx, &ec2.StopInstancesInput{InstanceIds: ids})
+	case ActionHibernate:
+		_, err = p.svc.StopInstancesWithContext(ctx, &ec2.StopInstancesInput{InstanceIds: ids, Hibernate: aws.Bool(true)})
+	case ActionReboot:
+		_, err = p.svc.RebootInstancesWithContext(ctx, &ec2.RebootInstancesInput{InstanceIds: ids})
+	case ActionTerminate:
+		_, err = p.svc.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{InstanceIds: ids})
+	default:
+		return fmt.Errorf("unsupported action '%s'", action)
+	}
+
+	return err
+}
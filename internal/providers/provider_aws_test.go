@@ -0,0 +1,444 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/yogin/gosh/internal/config"
+)
+
+// fakeEC2 returns the pages of DescribeInstances and records the lifecycle requests, the other
+// methods are not implemented
+type fakeEC2 struct {
+	ec2iface.EC2API
+
+	pages  [][]string // instance IDs by page
+	err    error      // returned instead of the first page when set
+	input  *ec2.DescribeInstancesInput
+	served int // pages returned
+
+	onPage func(page int) // called after a page is returned
+
+	requests []string // lifecycle requests, eg. stop i-1
+}
+
+func (f *fakeEC2) request(name string, ids []*string) {
+	f.requests = append(f.requests, name+" "+strings.Join(aws.StringValueSlice(ids), ","))
+}
+
+func (f *fakeEC2) StartInstancesWithContext(ctx aws.Context, input *ec2.StartInstancesInput, opts ...request.Option) (*ec2.StartInstancesOutput, error) {
+	f.request("start", input.InstanceIds)
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) StopInstancesWithContext(ctx aws.Context, input *ec2.StopInstancesInput, opts ...request.Option) (*ec2.StopInstancesOutput, error) {
+	if aws.BoolValue(input.Hibernate) {
+		f.request("hibernate", input.InstanceIds)
+	} else {
+		f.request("stop", input.InstanceIds)
+	}
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) RebootInstancesWithContext(ctx aws.Context, input *ec2.RebootInstancesInput, opts ...request.Option) (*ec2.RebootInstancesOutput, error) {
+	f.request("reboot", input.InstanceIds)
+	return &ec2.RebootInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) TerminateInstancesWithContext(ctx aws.Context, input *ec2.TerminateInstancesInput, opts ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	f.request("terminate", input.InstanceIds)
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeInstancesPagesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	f.input = input
+
+	if f.err != nil {
+		return f.err
+	}
+
+	for idx, ids := range f.pages {
+		// the SDK checks the context before sending each request
+		if err := ctx.Err(); err != nil {
+			return awserr.New(request.CanceledErrorCode, "request context canceled", err)
+		}
+
+		out := &ec2.DescribeInstancesOutput{}
+		for _, id := range ids {
+			out.Reservations = append(out.Reservations, &ec2.Reservation{Instances: []*ec2.Instance{testEC2Instance(id)}})
+		}
+
+		f.served++
+		last := idx == len(f.pages)-1
+		if !fn(out, last) {
+			return nil
+		}
+
+		if f.onPage != nil {
+			f.onPage(idx)
+		}
+	}
+
+	return nil
+}
+
+// fakeSSM returns the pages of DescribeInstanceInformation, the other methods are not implemented
+type fakeSSM struct {
+	ssmiface.SSMAPI
+
+	pages  []map[string]string // ping status by instance ID, by page
+	err    error               // returned after the first page when set
+	served int                 // pages returned
+}
+
+func (f *fakeSSM) DescribeInstanceInformationPagesWithContext(ctx aws.Context, input *ssm.DescribeInstanceInformationInput, fn func(*ssm.DescribeInstanceInformationOutput, bool) bool, opts ...request.Option) error {
+	for idx, statuses := range f.pages {
+		if idx > 0 && f.err != nil {
+			return f.err
+		}
+
+		out := &ssm.DescribeInstanceInformationOutput{}
+		for id, status := range statuses {
+			out.InstanceInformationList = append(out.InstanceInformationList, &ssm.InstanceInformation{
+				InstanceId: aws.String(id),
+				PingStatus: aws.String(status),
+			})
+		}
+
+		f.served++
+		if !fn(out, idx == len(f.pages)-1) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func testEC2Instance(id string) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:   aws.String(id),
+		State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+		Placement:    &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")},
+		InstanceType: aws.String("t3.micro"),
+		ImageId:      aws.String("ami-1"),
+		LaunchTime:   aws.Time(time.Now()),
+		Tags:         []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(id)}},
+	}
+}
+
+func instanceIDs(p Provider) []string {
+	ids := []string{}
+	for _, i := range p.GetInstances() {
+		ids = append(ids, i.ID)
+	}
+
+	return ids
+}
+
+func TestAWSLoadInstancesPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages [][]string
+		want  []string
+	}{
+		{"no instances", [][]string{{}}, []string{}},
+		{"single page", [][]string{{"i-1", "i-2"}}, []string{"i-1", "i-2"}},
+		{"several pages", [][]string{{"i-1", "i-2"}, {"i-3"}, {"i-4", "i-5"}}, []string{"i-1", "i-2", "i-3", "i-4", "i-5"}},
+		{"empty page", [][]string{{"i-1"}, {}, {"i-2"}}, []string{"i-1", "i-2"}},
+		{"duplicates across pages", [][]string{{"i-1"}, {"i-1", "i-2"}}, []string{"i-1", "i-2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &fakeEC2{pages: test.pages}
+			p := NewAWSProviderWithClient(&config.Profile{}, svc, nil, nil)
+
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			if svc.served != len(test.pages) {
+				t.Errorf("served %d pages, want %d", svc.served, len(test.pages))
+			}
+			if got := instanceIDs(p); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("instances = %v, want %v", got, test.want)
+			}
+			if got := p.InstancesCount(); got != len(test.want) {
+				t.Errorf("InstancesCount() = %d, want %d", got, len(test.want))
+			}
+		})
+	}
+}
+
+func TestAWSAPIFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters map[string][]string
+		want    []*ec2.Filter
+	}{
+		{"none", nil, nil},
+		{"empty", map[string][]string{}, nil},
+		{
+			"single",
+			map[string][]string{"instance-state-name": {"running"}},
+			[]*ec2.Filter{{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{"running"})}},
+		},
+		{
+			"sorted by name with several values",
+			map[string][]string{
+				"vpc-id":              {"vpc-1"},
+				"tag:env":             {"prod", "staging"},
+				"instance-state-name": {"running", "stopped"},
+			},
+			[]*ec2.Filter{
+				{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{"running", "stopped"})},
+				{Name: aws.String("tag:env"), Values: aws.StringSlice([]string{"prod", "staging"})},
+				{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{"vpc-1"})},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &fakeEC2{pages: [][]string{{"i-1"}}}
+			p := NewAWSProviderWithClient(&config.Profile{APIFilters: test.filters}, svc, nil, nil)
+
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			if got := svc.input.Filters; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filters = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAWSLoadInstancesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		svc    func(cancel context.CancelFunc) *fakeEC2
+		served int
+	}{
+		{
+			"canceled during pagination",
+			func(cancel context.CancelFunc) *fakeEC2 {
+				return &fakeEC2{
+					pages: [][]string{{"i-10"}, {"i-11"}, {"i-12"}},
+					onPage: func(page int) {
+						if page == 0 {
+							cancel()
+						}
+					},
+				}
+			},
+			1,
+		},
+		{
+			"api error",
+			func(cancel context.CancelFunc) *fakeEC2 {
+				return &fakeEC2{err: awserr.New("UnauthorizedOperation", "not authorized", nil)}
+			},
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &fakeEC2{pages: [][]string{{"i-1", "i-2"}}}
+			p := NewAWSProviderWithClient(&config.Profile{}, svc, nil, nil)
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			failing := test.svc(cancel)
+			p.svc = failing
+
+			err := p.LoadInstances(ctx)
+			if err == nil {
+				t.Fatal("LoadInstances() error = nil, want an error")
+			}
+
+			var aerr awserr.Error
+			if !errors.As(err, &aerr) {
+				t.Errorf("LoadInstances() error = %v, want an aws error", err)
+			}
+			if failing.served != test.served {
+				t.Errorf("served %d pages, want %d", failing.served, test.served)
+			}
+
+			// the previous instances are kept
+			if got, want := instanceIDs(p), []string{"i-1", "i-2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("instances = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAWSLoadSSMInstances(t *testing.T) {
+	ssmPages := []map[string]string{
+		{"i-1": ssm.PingStatusOnline, "i-2": ssm.PingStatusConnectionLost},
+		{"i-3": ssm.PingStatusOnline, "i-other": ssm.PingStatusOnline},
+		{"i-4": ssm.PingStatusInactive},
+	}
+
+	tests := []struct {
+		name       string
+		profile    *config.Profile
+		wantServed int
+		wantSSM    []string // instances with an online agent
+		wantColumn bool     // the ssm column is displayed by default
+	}{
+		{"ssh", &config.Profile{}, 0, []string{}, false},
+		{"ssm", &config.Profile{Connect: config.Connect{Method: config.ConnectMethodSSM}}, 3, []string{"i-1", "i-3"}, true},
+		{"ssh over ssm", &config.Profile{Connect: config.Connect{Method: config.ConnectMethodSSHSSM}}, 3, []string{"i-1", "i-3"}, true},
+		{"ssm column", &config.Profile{Columns: []*config.Column{{Field: "id"}, {Field: "ssm"}}}, 3, []string{"i-1", "i-3"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ssmSvc := &fakeSSM{pages: ssmPages}
+			p := NewAWSProviderWithClient(test.profile, &fakeEC2{pages: [][]string{{"i-1", "i-2"}, {"i-3", "i-4"}}}, ssmSvc, nil)
+
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			if ssmSvc.served != test.wantServed {
+				t.Errorf("served %d ssm pages, want %d", ssmSvc.served, test.wantServed)
+			}
+
+			managed := []string{}
+			for _, i := range p.GetInstances() {
+				if i.SSM != (i.FieldValue("ssm") == "yes") {
+					t.Errorf("instance %s ssm column = %q with SSM = %t", i.ID, i.FieldValue("ssm"), i.SSM)
+				}
+				if i.SSM {
+					managed = append(managed, i.ID)
+				}
+			}
+			if !reflect.DeepEqual(managed, test.wantSSM) {
+				t.Errorf("ssm instances = %v, want %v", managed, test.wantSSM)
+			}
+
+			column := false
+			for _, field := range p.Fields() {
+				column = column || field == "ssm"
+			}
+			if column != test.wantColumn {
+				t.Errorf("Fields() = %v, want the ssm column: %t", p.Fields(), test.wantColumn)
+			}
+		})
+	}
+}
+
+func TestAWSLoadSSMInstancesError(t *testing.T) {
+	profile := &config.Profile{Connect: config.Connect{Method: config.ConnectMethodSSM}}
+	ssmSvc := &fakeSSM{pages: []map[string]string{{"i-1": ssm.PingStatusOnline}}}
+	p := NewAWSProviderWithClient(profile, &fakeEC2{pages: [][]string{{"i-1", "i-2"}}}, ssmSvc, nil)
+
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	// the error of a later page
+	denied := awserr.New("AccessDeniedException", "not authorized to perform ssm:DescribeInstanceInformation", nil)
+	ssmSvc.pages = append(ssmSvc.pages, map[string]string{"i-2": ssm.PingStatusOnline})
+	ssmSvc.err = denied
+	p.svc = &fakeEC2{pages: [][]string{{"i-1", "i-2", "i-3"}}}
+
+	err := p.LoadInstances(context.Background())
+	if !errors.Is(err, denied) || !strings.Contains(err.Error(), "unable to list ssm managed instances") {
+		t.Errorf("LoadInstances() error = %v, want the wrapped ssm error", err)
+	}
+
+	// the previous instances are kept
+	if got, want := instanceIDs(p), []string{"i-1", "i-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("instances = %v, want %v", got, want)
+	}
+	if i := p.GetInstanceByID("i-1"); i == nil || !i.SSM {
+		t.Errorf("instance i-1 = %+v, want it still managed by ssm", i)
+	}
+}
+
+func TestAWSRunAction(t *testing.T) {
+	svc := &fakeEC2{}
+	p := NewAWSProviderWithClient(&config.Profile{ID: "test"}, svc, nil, nil)
+	instance := &Instance{ID: "i-1"}
+
+	for _, action := range []Action{ActionStart, ActionStop, ActionHibernate, ActionReboot, ActionTerminate} {
+		if err := p.RunAction(context.Background(), instance, action); err != nil {
+			t.Errorf("RunAction(%s) error = %v", action, err)
+		}
+	}
+
+	want := []string{"start i-1", "stop i-1", "hibernate i-1", "reboot i-1", "terminate i-1"}
+	if !reflect.DeepEqual(svc.requests, want) {
+		t.Errorf("requests = %v, want %v", svc.requests, want)
+	}
+
+	if err := p.RunAction(context.Background(), instance, Action("resize")); err == nil {
+		t.Error("RunAction(resize) succeeded, want an error")
+	}
+}
+
+func TestAWSActions(t *testing.T) {
+	hibernation := testEC2Instance("i-1")
+	hibernation.HibernationOptions = &ec2.HibernationOptions{Configured: aws.Bool(true)}
+
+	tests := []struct {
+		name     string
+		instance *Instance
+		want     []Action
+	}{
+		{"pending", &Instance{State: ec2.InstanceStateNamePending}, []Action{ActionTerminate}},
+		{"running", &Instance{State: ec2.InstanceStateNameRunning}, []Action{ActionStop, ActionReboot, ActionTerminate}},
+		{"running with hibernation", &Instance{State: ec2.InstanceStateNameRunning, data: hibernation}, []Action{ActionStop, ActionHibernate, ActionReboot, ActionTerminate}},
+		{"stopped", &Instance{State: ec2.InstanceStateNameStopped}, []Action{ActionStart, ActionTerminate}},
+		{"terminated", &Instance{State: ec2.InstanceStateNameTerminated}, nil},
+	}
+
+	p := NewAWSProviderWithClient(&config.Profile{ID: "test"}, &fakeEC2{}, nil, nil)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := p.Actions(test.instance); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Actions() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAWSReadOnly(t *testing.T) {
+	svc := &fakeEC2{}
+	p := NewAWSProviderWithClient(&config.Profile{ID: "test", ReadOnly: true}, svc, nil, nil)
+	instance := &Instance{ID: "i-1", State: ec2.InstanceStateNameRunning}
+
+	if actions := p.Actions(instance); len(actions) != 0 {
+		t.Errorf("Actions() = %v, want none on a read-only profile", actions)
+	}
+
+	for _, action := range []Action{ActionStart, ActionStop, ActionHibernate, ActionReboot, ActionTerminate} {
+		if err := p.RunAction(context.Background(), instance, action); err == nil || !strings.Contains(err.Error(), "read-only") {
+			t.Errorf("RunAction(%s) error = %v, want read-only", action, err)
+		}
+	}
+
+	if len(svc.requests) > 0 {
+		t.Errorf("requests = %v, want none on a read-only profile", svc.requests)
+	}
+}
@@ -0,0 +1,466 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const (
+	azureComputeAPIVersion = "2023-03-01"
+	azureNetworkAPIVersion = "2023-05-01"
+)
+
+// AzureDefaultTags are the virtual machine tags displayed by default
+var AzureDefaultTags = []string{"name", "env", "environment", "application", "role"}
+
+type AzureProvider struct {
+	instanceStore
+
+	client       *http.Client
+	tokens       TokenSource
+	endpoint     string
+	subscription string
+	err          error // credentials error, returned when loading instances
+}
+
+func NewAzureProvider(profile *config.Profile) *AzureProvider {
+	client := &http.Client{Timeout: profile.GetTimeout()}
+
+	p := &AzureProvider{
+		instanceStore: newInstanceStore(profile, AzureDefaultTags),
+		client:        client,
+		endpoint:      AzureDefaultEndpoint,
+		subscription:  profile.Project,
+	}
+
+	creds, err := loadAzureCredentials(profile.Credentials)
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	tokens := &azureTokenSource{client: client, creds: creds, resource: AzureDefaultEndpoint + "/"}
+
+	if creds != nil {
+		if len(creds.ResourceURL) > 0 {
+			// sovereign clouds (eg. https://management.usgovcloudapi.net/)
+			p.endpoint = creds.ResourceURL
+			tokens.resource = creds.ResourceURL
+		}
+
+		if len(p.subscription) == 0 {
+			p.subscription = creds.SubscriptionID
+		}
+	}
+
+	if len(p.subscription) == 0 {
+		p.subscription = os.Getenv("AZURE_SUBSCRIPTION_ID")
+	}
+
+	p.tokens = tokens
+
+	return p
+}
+
+// NewAzureProviderWithClient returns a provider using the given HTTP client and token source (eg. a
+// local fake of the Azure Resource Manager API set as the profile endpoint, for tests)
+func NewAzureProviderWithClient(profile *config.Profile, client *http.Client, tokens TokenSource) *AzureProvider {
+	return &AzureProvider{
+		instanceStore: newInstanceStore(profile, AzureDefaultTags),
+		client:        client,
+		tokens:        tokens,
+		endpoint:      AzureDefaultEndpoint,
+		subscription:  profile.Project,
+	}
+}
+
+func (p *AzureProvider) Type() ProviderType {
+	return ProviderTypeAzure
+}
+
+func (p *AzureProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Zone", "Size", "Image", "Running"}
+}
+
+func (p *AzureProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+}
+
+// azureResource is the common part of the ARM resources
+type azureResource struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Location string            `json:"location"`
+	Zones    []string          `json:"zones"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// azureReference is a reference to another ARM resource
+type azureReference struct {
+	ID string `json:"id"`
+}
+
+// azureVM is the subset of the virtual machine resource used by gosh
+type azureVM struct {
+	azureResource
+
+	Properties struct {
+		VMID            string `json:"vmId"`
+		TimeCreated     string `json:"timeCreated"`
+		Priority        string `json:"priority"`
+		HardwareProfile struct {
+			VMSize string `json:"vmSize"`
+		} `json:"hardwareProfile"`
+		StorageProfile struct {
+			ImageReference struct {
+				ID        string `json:"id"`
+				Publisher string `json:"publisher"`
+				Offer     string `json:"offer"`
+				SKU       string `json:"sku"`
+			} `json:"imageReference"`
+			OSDisk struct {
+				Name   string `json:"name"`
+				OSType string `json:"osType"`
+			} `json:"osDisk"`
+		} `json:"storageProfile"`
+		OSProfile struct {
+			ComputerName  string `json:"computerName"`
+			AdminUsername string `json:"adminUsername"`
+		} `json:"osProfile"`
+		NetworkProfile struct {
+			NetworkInterfaces []struct {
+				azureReference
+				Properties struct {
+					Primary bool `json:"primary"`
+				} `json:"properties"`
+			} `json:"networkInterfaces"`
+		} `json:"networkProfile"`
+		InstanceView *struct {
+			Statuses []struct {
+				Code string `json:"code"`
+			} `json:"statuses"`
+		} `json:"instanceView"`
+	} `json:"properties"`
+}
+
+// azureNIC is the subset of the network interface resource used by gosh
+type azureNIC struct {
+	azureResource
+
+	Properties struct {
+		NetworkSecurityGroup *azureReference `json:"networkSecurityGroup"`
+		IPConfigurations     []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Primary          bool            `json:"primary"`
+				PrivateIPAddress string          `json:"privateIPAddress"`
+				Subnet           *azureReference `json:"subnet"`
+				PublicIPAddress  *azureReference `json:"publicIPAddress"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+// azurePublicIP is the subset of the public IP address resource used by gosh
+type azurePublicIP struct {
+	azureResource
+
+	Properties struct {
+		IPAddress string `json:"ipAddress"`
+	} `json:"properties"`
+}
+
+// LoadInstances lists the virtual machines of the subscription (or of the profile resource groups)
+// in the profile region, with their network interfaces and public IPs
+func (p *AzureProvider) LoadInstances(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	// the subscription of the Azure CLI account is known once a token was requested
+	if _, err := p.tokens.Token(ctx); err != nil {
+		return err
+	}
+
+	subscription := p.subscription
+	if cli, ok := p.tokens.(*azureTokenSource); ok && len(subscription) == 0 {
+		subscription = cli.Subscription()
+	}
+
+	if len(subscription) == 0 {
+		return errors.New("no azure subscription configured (set the profile project)")
+	}
+
+	scopes := p.scopes(subscription)
+
+	// each lists the resources of all the scopes
+	each := func(resource string, version string, query url.Values, add func(json.RawMessage) error) error {
+		for _, scope := range scopes {
+			if err := p.list(ctx, scope+resource, version, query, add); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	vms := []*azureVM{}
+	if err := each("/providers/Microsoft.Compute/virtualMachines", azureComputeAPIVersion, nil, func(raw json.RawMessage) error {
+		vm := &azureVM{}
+		if err := json.Unmarshal(raw, vm); err != nil {
+			return err
+		}
+
+		if len(p.profile.Region) == 0 || strings.EqualFold(vm.Location, p.profile.Region) {
+			vms = append(vms, vm)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// the power states are only returned by the status list
+	states := make(map[string]string)
+	if err := each("/providers/Microsoft.Compute/virtualMachines", azureComputeAPIVersion, url.Values{"statusOnly": {"true"}}, func(raw json.RawMessage) error {
+		vm := &azureVM{}
+		if err := json.Unmarshal(raw, vm); err != nil {
+			return err
+		}
+
+		if view := vm.Properties.InstanceView; view != nil {
+			for _, status := range view.Statuses {
+				if strings.HasPrefix(status.Code, "PowerState/") {
+					states[strings.ToLower(vm.ID)] = strings.TrimPrefix(status.Code, "PowerState/")
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	nics := make(map[string]*azureNIC)
+	if err := each("/providers/Microsoft.Network/networkInterfaces", azureNetworkAPIVersion, nil, func(raw json.RawMessage) error {
+		nic := &azureNIC{}
+		if err := json.Unmarshal(raw, nic); err != nil {
+			return err
+		}
+
+		nics[strings.ToLower(nic.ID)] = nic
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ips := make(map[string]string)
+	if err := each("/providers/Microsoft.Network/publicIPAddresses", azureNetworkAPIVersion, nil, func(raw json.RawMessage) error {
+		ip := &azurePublicIP{}
+		if err := json.Unmarshal(raw, ip); err != nil {
+			return err
+		}
+
+		ips[strings.ToLower(ip.ID)] = ip.Properties.IPAddress
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	insts := make(map[string]*Instance)
+	for _, vm := range vms {
+		i := newAzureInstance(vm, states[strings.ToLower(vm.ID)], nics, ips)
+		insts[i.ID] = i
+	}
+
+	p.setInstances(insts)
+
+	return nil
+}
+
+// scopes returns the paths of the profile resource groups, or of the whole subscription, the
+// network interfaces and public IPs of the virtual machines must be in the same resource groups
+func (p *AzureProvider) scopes(subscription string) []string {
+	scope := "/subscriptions/" + url.PathEscape(subscription)
+
+	if len(p.profile.ResourceGroups) == 0 {
+		return []string{scope}
+	}
+
+	scopes := make([]string, 0, len(p.profile.ResourceGroups))
+	for _, group := range p.profile.ResourceGroups {
+		scopes = append(scopes, scope+"/resourceGroups/"+url.PathEscape(group))
+	}
+
+	return scopes
+}
+
+// list fetches all the pages of an ARM list, and calls add with each resource
+func (p *AzureProvider) list(ctx context.Context, path string, version string, query url.Values, add func(json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", version)
+
+	endpoint := p.profile.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = p.endpoint
+	}
+	u := strings.TrimSuffix(endpoint, "/") + path + "?" + query.Encode()
+
+	for len(u) > 0 {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		if err := getJSON(ctx, p.client, u, token, &page); err != nil {
+			return err
+		}
+
+		for _, raw := range page.Value {
+			if err := add(raw); err != nil {
+				return fmt.Errorf("invalid azure api response: %w", err)
+			}
+		}
+
+		u = page.NextLink
+	}
+
+	return nil
+}
+
+// azureStates maps the Azure power states to the states used by the other providers
+var azureStates = map[string]string{
+	"starting":     "pending",
+	"running":      "running",
+	"stopping":     "stopping",
+	"deallocating": "stopping",
+	"stopped":      "stopped",
+	"deallocated":  "stopped",
+}
+
+func newAzureInstance(vm *azureVM, power string, nics map[string]*azureNIC, ips map[string]string) *Instance {
+	props := vm.Properties
+
+	i := &Instance{
+		ID:    props.VMID,
+		State: power,
+		AZ:    vm.Location,
+		Type:  props.HardwareProfile.VMSize,
+		Tags:  make(map[string]string),
+	}
+
+	if len(i.ID) == 0 {
+		i.ID = vm.Name
+	}
+	if state, ok := azureStates[power]; ok {
+		i.State = state
+	}
+	if len(i.State) == 0 {
+		i.State = "unknown"
+	}
+	if len(vm.Zones) > 0 {
+		i.AZ = vm.Location + "-" + vm.Zones[0]
+	}
+
+	i.Launched, _ = time.Parse(time.RFC3339, props.TimeCreated)
+
+	image := props.StorageProfile.ImageReference
+	if len(image.Offer) > 0 {
+		i.AMI = strings.Join([]string{image.Publisher, image.Offer, image.SKU}, ":")
+	} else {
+		i.AMI = lastSegment(image.ID)
+	}
+
+	for key, value := range vm.Tags {
+		i.Tags[strings.ToLower(key)] = value
+	}
+	if _, ok := i.Tags["name"]; !ok {
+		i.Tags["name"] = vm.Name
+	}
+
+	i.addExtra("Instance", "Name", vm.Name)
+	i.addExtra("Instance", "Resource group", azureResourceGroup(vm.ID))
+	i.addExtra("Instance", "Computer name", props.OSProfile.ComputerName)
+	i.addExtra("Instance", "Admin user", props.OSProfile.AdminUsername)
+	i.addExtra("Instance", "OS type", props.StorageProfile.OSDisk.OSType)
+	i.addExtra("Instance", "OS disk", props.StorageProfile.OSDisk.Name)
+	i.addExtra("Instance", "Priority", props.Priority)
+	i.addExtra("Instance", "Resource ID", vm.ID)
+
+	for _, ref := range props.NetworkProfile.NetworkInterfaces {
+		nic, ok := nics[strings.ToLower(ref.ID)]
+		if !ok {
+			continue
+		}
+
+		primaryNIC := ref.Properties.Primary || len(props.NetworkProfile.NetworkInterfaces) == 1
+		section := fmt.Sprintf("Network interface %s", nic.Name)
+
+		if nsg := nic.Properties.NetworkSecurityGroup; nsg != nil {
+			i.addExtra(section, "Security group", lastSegment(nsg.ID))
+		}
+
+		for _, config := range nic.Properties.IPConfigurations {
+			publicIP := ""
+			if ref := config.Properties.PublicIPAddress; ref != nil {
+				publicIP = ips[strings.ToLower(ref.ID)]
+			}
+
+			vnet, subnet := "", ""
+			if ref := config.Properties.Subnet; ref != nil {
+				vnet, subnet = azureSubnet(ref.ID)
+			}
+
+			// the primary configuration of the primary interface, or the first one
+			if primaryNIC && (config.Properties.Primary || len(i.PrivateIP) == 0) {
+				i.PrivateIP = config.Properties.PrivateIPAddress
+				i.PublicIP = publicIP
+				i.VPC = vnet
+			}
+
+			i.addExtra(section, "Virtual network", vnet)
+			i.addExtra(section, "Subnet", subnet)
+			i.addExtra(section, "Private IP", config.Properties.PrivateIPAddress)
+			i.addExtra(section, "Public IP", publicIP)
+		}
+	}
+
+	return i
+}
+
+// azureResourceGroup returns the resource group of a resource ID
+// (eg. /subscriptions/<id>/resourceGroups/<group>/providers/...)
+func azureResourceGroup(id string) string {
+	parts := strings.Split(id, "/")
+	for idx := 0; idx < len(parts)-1; idx++ {
+		if strings.EqualFold(parts[idx], "resourceGroups") {
+			return parts[idx+1]
+		}
+	}
+
+	return ""
+}
+
+// azureSubnet returns the virtual network and subnet names of a subnet ID
+// (eg. .../virtualNetworks/<vnet>/subnets/<subnet>)
+func azureSubnet(id string) (string, string) {
+	parts := strings.Split(id, "/")
+	if len(parts) < 4 {
+		return "", lastSegment(id)
+	}
+
+	return parts[len(parts)-3], parts[len(parts)-1]
+}
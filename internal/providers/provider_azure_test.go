@@ -0,0 +1,409 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// fakeARM is a local fake of the Azure Resource Manager API, serving the virtual machines, network
+// interfaces and public IPs of resource groups, one resource per page
+type fakeARM struct {
+	*httptest.Server
+
+	vms   map[string][]string // VM names by resource group
+	fail  int                 // status code returned to all the requests when set
+	mutex sync.Mutex
+	paths []string // paths requested, without the pages
+}
+
+const fakeSubscription = "00000000-0000-0000-0000-000000000001"
+
+func newFakeARM(t *testing.T, vms map[string][]string) *fakeARM {
+	f := &fakeARM{vms: vms}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeARM) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"code": "InvalidAuthenticationToken", "message": "invalid token"}}`)
+		return
+	}
+
+	if f.fail != 0 {
+		w.WriteHeader(f.fail)
+		fmt.Fprint(w, `{"error": {"code": "Failure", "message": "fake failure"}}`)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("api-version") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"code": "MissingApiVersionParameter", "message": "missing api-version"}}`)
+		return
+	}
+
+	if query.Get("page") == "" {
+		f.mutex.Lock()
+		path := r.URL.Path
+		if query.Get("statusOnly") == "true" {
+			path += "?statusOnly"
+		}
+		f.paths = append(f.paths, path)
+		f.mutex.Unlock()
+	}
+
+	prefix := "/subscriptions/" + fakeSubscription
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": "SubscriptionNotFound", "message": "subscription not found"}}`)
+		return
+	}
+
+	scope := strings.TrimPrefix(r.URL.Path, prefix)
+	groups := []string{}
+	if strings.HasPrefix(scope, "/resourceGroups/") {
+		parts := strings.SplitN(strings.TrimPrefix(scope, "/resourceGroups/"), "/", 2)
+		if _, ok := f.vms[parts[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": {"code": "ResourceGroupNotFound", "message": "Resource group '%s' could not be found."}}`, parts[0])
+			return
+		}
+		groups = append(groups, parts[0])
+		scope = "/" + parts[1]
+	} else {
+		for group := range f.vms {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+	}
+
+	resources := []map[string]interface{}{}
+	for _, group := range groups {
+		for idx, name := range f.vms[group] {
+			resources = append(resources, f.resource(scope, query.Get("statusOnly") == "true", group, name, idx))
+		}
+	}
+
+	// one resource per page
+	page := 0
+	fmt.Sscan(query.Get("page"), &page)
+
+	body := map[string]interface{}{"value": []interface{}{}}
+	if page < len(resources) {
+		body["value"] = resources[page : page+1]
+	}
+	if page+1 < len(resources) {
+		query.Set("page", fmt.Sprint(page+1))
+		body["nextLink"] = f.URL + r.URL.Path + "?" + query.Encode()
+	}
+
+	json.NewEncoder(w).Encode(body)
+}
+
+// resource returns a resource of a VM (eg. its network interface), by resource type
+func (f *fakeARM) resource(scope string, statusOnly bool, group string, name string, idx int) map[string]interface{} {
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers", fakeSubscription, group)
+	vmID := id + "/Microsoft.Compute/virtualMachines/" + name
+	nicID := id + "/Microsoft.Network/networkInterfaces/" + name + "-nic"
+	ipID := id + "/Microsoft.Network/publicIPAddresses/" + name + "-ip"
+
+	switch scope {
+	case "/providers/Microsoft.Compute/virtualMachines":
+		if statusOnly {
+			power := "PowerState/running"
+			if strings.HasSuffix(name, "-stopped") {
+				power = "PowerState/deallocated"
+			}
+
+			return map[string]interface{}{
+				"id":   vmID,
+				"name": name,
+				"properties": map[string]interface{}{
+					"instanceView": map[string]interface{}{
+						"statuses": []map[string]string{{"code": "ProvisioningState/succeeded"}, {"code": power}},
+					},
+				},
+			}
+		}
+
+		location := "eastus"
+		if strings.HasPrefix(name, "west-") {
+			location = "westeurope"
+		}
+
+		return map[string]interface{}{
+			"id":       vmID,
+			"name":     name,
+			"location": location,
+			"zones":    []string{"2"},
+			"tags":     map[string]string{"Env": group},
+			"properties": map[string]interface{}{
+				"vmId":            "vm-" + name,
+				"timeCreated":     "2024-01-02T03:04:05Z",
+				"hardwareProfile": map[string]string{"vmSize": "Standard_B2s"},
+				"storageProfile": map[string]interface{}{
+					"imageReference": map[string]string{"publisher": "Canonical", "offer": "ubuntu-24_04-lts", "sku": "server"},
+				},
+				"networkProfile": map[string]interface{}{
+					"networkInterfaces": []map[string]interface{}{{"id": nicID, "properties": map[string]bool{"primary": true}}},
+				},
+			},
+		}
+
+	case "/providers/Microsoft.Network/networkInterfaces":
+		return map[string]interface{}{
+			"id":   nicID,
+			"name": name + "-nic",
+			"properties": map[string]interface{}{
+				"ipConfigurations": []map[string]interface{}{{
+					"name": "ipconfig1",
+					"properties": map[string]interface{}{
+						"primary":          true,
+						"privateIPAddress": fmt.Sprintf("10.%d.0.%d", len(group), idx+4),
+						"subnet":           map[string]string{"id": id + "/Microsoft.Network/virtualNetworks/vnet/subnets/default"},
+						"publicIPAddress":  map[string]string{"id": ipID},
+					},
+				}},
+			},
+		}
+
+	case "/providers/Microsoft.Network/publicIPAddresses":
+		return map[string]interface{}{
+			"id":         ipID,
+			"name":       name + "-ip",
+			"properties": map[string]string{"ipAddress": fmt.Sprintf("20.%d.0.%d", len(group), idx+4)},
+		}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (f *fakeARM) requested() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.paths...)
+}
+
+func TestAzureLoadInstances(t *testing.T) {
+	arm := newFakeARM(t, map[string][]string{
+		"web": {"web-1", "web-2-stopped", "west-web"},
+		"db":  {"db-1"},
+	})
+
+	tests := []struct {
+		name   string
+		groups []string
+		region string
+		want   []string
+		paths  []string
+	}{
+		{
+			name: "subscription",
+			want: []string{"vm-db-1", "vm-web-1", "vm-web-2-stopped", "vm-west-web"},
+			paths: []string{
+				"/subscriptions/" + fakeSubscription + "/providers/Microsoft.Compute/virtualMachines",
+				"/subscriptions/" + fakeSubscription + "/providers/Microsoft.Compute/virtualMachines?statusOnly",
+				"/subscriptions/" + fakeSubscription + "/providers/Microsoft.Network/networkInterfaces",
+				"/subscriptions/" + fakeSubscription + "/providers/Microsoft.Network/publicIPAddresses",
+			},
+		},
+		{
+			name:   "resource groups",
+			groups: []string{"web"},
+			want:   []string{"vm-web-1", "vm-web-2-stopped", "vm-west-web"},
+			paths: []string{
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Compute/virtualMachines",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Compute/virtualMachines?statusOnly",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Network/networkInterfaces",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Network/publicIPAddresses",
+			},
+		},
+		{
+			name:   "resource groups and region",
+			groups: []string{"db", "web"},
+			region: "eastus",
+			want:   []string{"vm-db-1", "vm-web-1", "vm-web-2-stopped"},
+			paths: []string{
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/db/providers/Microsoft.Compute/virtualMachines",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Compute/virtualMachines",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/db/providers/Microsoft.Compute/virtualMachines?statusOnly",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Compute/virtualMachines?statusOnly",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/db/providers/Microsoft.Network/networkInterfaces",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Network/networkInterfaces",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/db/providers/Microsoft.Network/publicIPAddresses",
+				"/subscriptions/" + fakeSubscription + "/resourceGroups/web/providers/Microsoft.Network/publicIPAddresses",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arm.mutex.Lock()
+			arm.paths = nil
+			arm.mutex.Unlock()
+
+			profile := &config.Profile{Project: fakeSubscription, Endpoint: arm.URL, ResourceGroups: test.groups, Region: test.region}
+			p := NewAzureProviderWithClient(profile, arm.Client(), apiToken("test-token"))
+
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			ids := []string{}
+			for _, i := range p.GetInstances() {
+				ids = append(ids, i.ID)
+			}
+			sort.Strings(ids)
+
+			if !reflect.DeepEqual(ids, test.want) {
+				t.Errorf("instances = %v, want %v", ids, test.want)
+			}
+			if got := arm.requested(); !reflect.DeepEqual(got, test.paths) {
+				t.Errorf("requested %v, want %v", got, test.paths)
+			}
+		})
+	}
+}
+
+func TestAzureInstanceFields(t *testing.T) {
+	arm := newFakeARM(t, map[string][]string{"web": {"web-1", "web-2-stopped"}})
+
+	profile := &config.Profile{Project: fakeSubscription, Endpoint: arm.URL}
+	p := NewAzureProviderWithClient(profile, arm.Client(), apiToken("test-token"))
+
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	i := p.GetInstanceByID("vm-web-1")
+	if i == nil {
+		t.Fatal("instance vm-web-1 not found")
+	}
+
+	got := map[string]string{
+		"private_ip": i.PrivateIP,
+		"public_ip":  i.PublicIP,
+		"state":      i.State,
+		"az":         i.AZ,
+		"type":       i.Type,
+		"ami":        i.AMI,
+		"vpc":        i.VPC,
+		"launched":   i.Launched.Format("2006-01-02"),
+		"tag:name":   i.Tags["name"],
+		"tag:env":    i.Tags["env"],
+	}
+	want := map[string]string{
+		"private_ip": "10.3.0.4",
+		"public_ip":  "20.3.0.4",
+		"state":      "running",
+		"az":         "eastus-2",
+		"type":       "Standard_B2s",
+		"ami":        "Canonical:ubuntu-24_04-lts:server",
+		"vpc":        "vnet",
+		"launched":   "2024-01-02",
+		"tag:name":   "web-1",
+		"tag:env":    "web",
+	}
+
+	for field, value := range want {
+		if got[field] != value {
+			t.Errorf("%s = %q, want %q", field, got[field], value)
+		}
+	}
+
+	if stopped := p.GetInstanceByID("vm-web-2-stopped"); stopped == nil || stopped.State != "stopped" {
+		t.Errorf("deallocated instance = %+v, want state stopped", stopped)
+	}
+}
+
+func TestAzureLoadInstancesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile func(url string) *config.Profile
+		token   string
+		fail    int
+		want    string
+		status  int
+	}{
+		{
+			name:    "missing subscription",
+			profile: func(url string) *config.Profile { return &config.Profile{Endpoint: url} },
+			token:   "test-token",
+			want:    "no azure subscription configured",
+		},
+		{
+			name:    "invalid token",
+			profile: func(url string) *config.Profile { return &config.Profile{Project: fakeSubscription, Endpoint: url} },
+			token:   "expired",
+			want:    "invalid token",
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name: "unknown resource group",
+			profile: func(url string) *config.Profile {
+				return &config.Profile{Project: fakeSubscription, Endpoint: url, ResourceGroups: []string{"web", "missing"}}
+			},
+			token:  "test-token",
+			want:   "Resource group 'missing' could not be found.",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "throttled",
+			profile: func(url string) *config.Profile { return &config.Profile{Project: fakeSubscription, Endpoint: url} },
+			token:   "test-token",
+			fail:    http.StatusTooManyRequests,
+			want:    "fake failure",
+			status:  http.StatusTooManyRequests,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arm := newFakeARM(t, map[string][]string{"web": {"web-1"}})
+
+			// instances loaded before the failure are kept
+			p := NewAzureProviderWithClient(&config.Profile{Project: fakeSubscription, Endpoint: arm.URL}, arm.Client(), apiToken("test-token"))
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			arm.fail = test.fail
+			p.profile = test.profile(arm.URL)
+			p.subscription = p.profile.Project
+			p.tokens = apiToken(test.token)
+
+			err := p.LoadInstances(context.Background())
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("LoadInstances() error = %v, want %q", err, test.want)
+			}
+
+			var httpErr *HTTPError
+			if test.status != 0 && (!errors.As(err, &httpErr) || httpErr.StatusCode != test.status) {
+				t.Errorf("LoadInstances() error = %#v, want status %d", err, test.status)
+			}
+			if test.status == http.StatusTooManyRequests && !IsThrottlingError(err) {
+				t.Error("IsThrottlingError() = false, want true")
+			}
+
+			if p.GetInstanceByID("vm-web-1") == nil {
+				t.Error("previous instances were not kept")
+			}
+		})
+	}
+}
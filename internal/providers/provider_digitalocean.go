@@ -0,0 +1,173 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const DigitalOceanDefaultEndpoint = "https://api.digitalocean.com" // DigitalOceanDefaultEndpoint is the public DigitalOcean API endpoint
+
+// DigitalOceanDefaultTags are the droplet tags displayed by default, tags like env:prod are split
+var DigitalOceanDefaultTags = []string{"name", "env", "environment", "role"}
+
+type DigitalOceanProvider struct {
+	restProvider
+}
+
+func NewDigitalOceanProvider(profile *config.Profile) *DigitalOceanProvider {
+	tokens, err := loadAPIToken(profile.Credentials, "DIGITALOCEAN_TOKEN", "DIGITALOCEAN_ACCESS_TOKEN")
+
+	p := NewDigitalOceanProviderWithClient(profile, &http.Client{Timeout: profile.GetTimeout()}, tokens)
+	p.err = err
+
+	return p
+}
+
+// NewDigitalOceanProviderWithClient returns a provider using the given HTTP client and token source
+// (eg. a local stand-in of the DigitalOcean API set as the profile endpoint, for tests)
+func NewDigitalOceanProviderWithClient(profile *config.Profile, client *http.Client, tokens TokenSource) *DigitalOceanProvider {
+	return &DigitalOceanProvider{
+		restProvider: newRESTProvider(profile, client, tokens, DigitalOceanDefaultEndpoint, DigitalOceanDefaultTags),
+	}
+}
+
+func (p *DigitalOceanProvider) Type() ProviderType {
+	return ProviderTypeDigitalOcean
+}
+
+func (p *DigitalOceanProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Region", "Size", "Image", "Running"}
+}
+
+func (p *DigitalOceanProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+}
+
+// LoadInstances lists the droplets of the account (eg. api_filters tag_name: [web])
+func (p *DigitalOceanProvider) LoadInstances(ctx context.Context) error {
+	query := url.Values{"per_page": {"200"}}
+
+	return p.loadInstances(ctx, "/v2/droplets", query, func() restPage {
+		return &doDropletsPage{}
+	})
+}
+
+// doDroplet is the subset of the droplet resource used by gosh
+type doDroplet struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	CreatedAt string   `json:"created_at"`
+	SizeSlug  string   `json:"size_slug"`
+	Memory    int      `json:"memory"`
+	VCPUs     int      `json:"vcpus"`
+	Disk      int      `json:"disk"`
+	VPCUUID   string   `json:"vpc_uuid"`
+	Tags      []string `json:"tags"`
+	Features  []string `json:"features"`
+	Region    struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	} `json:"region"`
+	Image struct {
+		Slug         string `json:"slug"`
+		Name         string `json:"name"`
+		Distribution string `json:"distribution"`
+	} `json:"image"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+		V6 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v6"`
+	} `json:"networks"`
+}
+
+// doDropletsPage is a page of the droplets list
+type doDropletsPage struct {
+	Droplets []*doDroplet `json:"droplets"`
+	Links    struct {
+		Pages struct {
+			Next string `json:"next"`
+		} `json:"pages"`
+	} `json:"links"`
+}
+
+func (page *doDropletsPage) instances() []*Instance {
+	insts := make([]*Instance, 0, len(page.Droplets))
+	for _, droplet := range page.Droplets {
+		insts = append(insts, newDigitalOceanInstance(droplet))
+	}
+
+	return insts
+}
+
+func (page *doDropletsPage) next() bool {
+	return len(page.Links.Pages.Next) > 0
+}
+
+// doStates maps the droplet statuses to the states used by the other providers
+var doStates = map[string]string{
+	"new":    "pending",
+	"active": "running",
+	"off":    "stopped",
+}
+
+func newDigitalOceanInstance(droplet *doDroplet) *Instance {
+	i := &Instance{
+		ID:    strconv.FormatInt(droplet.ID, 10),
+		State: droplet.Status,
+		AZ:    droplet.Region.Slug,
+		Type:  droplet.SizeSlug,
+		AMI:   droplet.Image.Slug,
+		VPC:   droplet.VPCUUID,
+		Tags:  splitTags(droplet.Tags),
+	}
+
+	if state, ok := doStates[droplet.Status]; ok {
+		i.State = state
+	}
+	if len(i.AMI) == 0 {
+		// snapshots and custom images have no slug
+		i.AMI = strings.TrimSpace(droplet.Image.Distribution + " " + droplet.Image.Name)
+	}
+	if _, ok := i.Tags["name"]; !ok {
+		i.Tags["name"] = droplet.Name
+	}
+
+	i.Launched, _ = time.Parse(time.RFC3339, droplet.CreatedAt)
+
+	for _, network := range droplet.Networks.V4 {
+		switch {
+		case network.Type == "private" && len(i.PrivateIP) == 0:
+			i.PrivateIP = network.IPAddress
+		case network.Type == "public" && len(i.PublicIP) == 0:
+			i.PublicIP = network.IPAddress
+		}
+
+		i.addExtra("Networks", "IPv4 "+network.Type, network.IPAddress)
+	}
+	for _, network := range droplet.Networks.V6 {
+		i.addExtra("Networks", "IPv6 "+network.Type, network.IPAddress)
+	}
+
+	i.addExtra("Instance", "Name", droplet.Name)
+	i.addExtra("Instance", "Region", droplet.Region.Name)
+	i.addExtra("Instance", "vCPUs", strconv.Itoa(droplet.VCPUs))
+	i.addExtra("Instance", "Memory", fmt.Sprintf("%d MB", droplet.Memory))
+	i.addExtra("Instance", "Disk", fmt.Sprintf("%d GB", droplet.Disk))
+	i.addExtra("Instance", "Image", strings.TrimSpace(droplet.Image.Distribution+" "+droplet.Image.Name))
+	i.addExtra("Instance", "Features", strings.Join(droplet.Features, ", "))
+
+	return i
+}
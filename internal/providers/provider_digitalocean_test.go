@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// doDropletsBody returns a page of the droplets list, with the next page link when next is set
+func doDropletsBody(next bool, droplets ...string) string {
+	links := `{}`
+	if next {
+		links = `{"pages": {"next": "https://api.digitalocean.com/v2/droplets?page=2"}}`
+	}
+
+	return fmt.Sprintf(`{"droplets": [%s], "links": %s, "meta": {"total": 3}}`, strings.Join(droplets, ", "), links)
+}
+
+func doDropletBody(id int, status string) string {
+	return fmt.Sprintf(`{"id": %d, "name": "droplet-%d", "status": %q, "region": {"slug": "nyc1"}, "image": {"slug": "ubuntu-22-04-x64"}}`, id, id, status)
+}
+
+func newTestDigitalOceanProvider(f *fakeREST, profile *config.Profile) *DigitalOceanProvider {
+	profile.ID = "do"
+	profile.Endpoint = f.URL
+
+	return NewDigitalOceanProviderWithClient(profile, f.Client(), apiToken("test-token"))
+}
+
+func TestDigitalOceanLoadInstances(t *testing.T) {
+	f := newFakeREST(t, "/v2/droplets",
+		doDropletsBody(true, doDropletBody(1, "new"), doDropletBody(2, "active")),
+		doDropletsBody(true, doDropletBody(3, "off")),
+		doDropletsBody(false, doDropletBody(4, "archive")),
+	)
+
+	p := newTestDigitalOceanProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if got := f.requestedPages(); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("requested pages = %v, want 1 to 3", got)
+	}
+
+	states := map[string]string{}
+	for _, i := range p.GetInstances() {
+		states[i.ID] = i.State
+	}
+
+	// unknown statuses are kept
+	want := map[string]string{"1": "pending", "2": "running", "3": "stopped", "4": "archive"}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+}
+
+func TestDigitalOceanInstanceFields(t *testing.T) {
+	f := newFakeREST(t, "/v2/droplets", doDropletsBody(false, `{
+		"id": 42,
+		"name": "web-1",
+		"status": "active",
+		"created_at": "2024-01-10T20:00:00Z",
+		"size_slug": "s-1vcpu-1gb",
+		"vpc_uuid": "vpc-1",
+		"tags": ["env:prod", "k8s"],
+		"region": {"slug": "nyc1", "name": "New York 1"},
+		"image": {"slug": "ubuntu-22-04-x64"},
+		"networks": {"v4": [
+			{"ip_address": "10.0.0.1", "type": "private"},
+			{"ip_address": "164.0.0.1", "type": "public"},
+			{"ip_address": "164.0.0.2", "type": "public"}
+		]}
+	}`, `{
+		"id": 43,
+		"name": "snapshot-1",
+		"status": "active",
+		"tags": ["name:db"],
+		"region": {"slug": "nyc1"},
+		"image": {"name": "db-snapshot", "distribution": "Ubuntu"}
+	}`))
+
+	p := newTestDigitalOceanProvider(f, &config.Profile{APIFilters: map[string][]string{"tag_name": {"web"}}})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	f.mutex.Lock()
+	if len(f.queries) != 1 || f.queries[0].Get("tag_name") != "web" || f.queries[0].Get("per_page") != "200" {
+		t.Errorf("queries = %v, want the tag_name filter and 200 droplets per page", f.queries)
+	}
+	f.mutex.Unlock()
+
+	i := p.GetInstanceByID("42")
+	if i == nil {
+		t.Fatal("droplet 42 not loaded")
+	}
+
+	fields := map[string]string{
+		"private_ip": i.PrivateIP,
+		"public_ip":  i.PublicIP,
+		"state":      i.State,
+		"az":         i.AZ,
+		"type":       i.Type,
+		"ami":        i.AMI,
+		"vpc":        i.VPC,
+		"name":       i.Tags["name"],
+		"env":        i.Tags["env"],
+		"k8s":        i.Tags["k8s"],
+	}
+	wantFields := map[string]string{
+		"private_ip": "10.0.0.1",
+		"public_ip":  "164.0.0.1",
+		"state":      "running",
+		"az":         "nyc1",
+		"type":       "s-1vcpu-1gb",
+		"ami":        "ubuntu-22-04-x64",
+		"vpc":        "vpc-1",
+		"name":       "web-1",
+		"env":        "prod",
+		"k8s":        "true",
+	}
+	for field, value := range wantFields {
+		if fields[field] != value {
+			t.Errorf("%s = %q, want %q", field, fields[field], value)
+		}
+	}
+
+	if launched := i.Launched.UTC().Format("2006-01-02 15:04"); launched != "2024-01-10 20:00" {
+		t.Errorf("launched = %s, want 2024-01-10 20:00", launched)
+	}
+
+	// snapshots have no image slug, and the name tag overrides the droplet name
+	i = p.GetInstanceByID("43")
+	if i == nil || i.AMI != "Ubuntu db-snapshot" || i.Tags["name"] != "db" {
+		t.Errorf("droplet 43 = %+v, want the Ubuntu db-snapshot image and the db name", i)
+	}
+}
@@ -0,0 +1,398 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// FileProvider reads the instances from a static YAML, JSON or CSV inventory file
+type FileProvider struct {
+	instanceStore
+
+	path string
+}
+
+func NewFileProvider(profile *config.Profile) *FileProvider {
+	return &FileProvider{
+		instanceStore: newInstanceStore(profile, AWSDefaultTags),
+		path:          utils.ExpandHome(profile.Inventory),
+	}
+}
+
+func (p *FileProvider) Type() ProviderType {
+	return ProviderTypeFile
+}
+
+func (p *FileProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Zone", "Type"}
+}
+
+func (p *FileProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type"}
+}
+
+// LoadInstances reads the inventory file, the previous instances are kept when it is invalid
+func (p *FileProvider) LoadInstances(ctx context.Context) error {
+	if len(p.path) == 0 {
+		return errors.New("no inventory file configured (set the profile inventory)")
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("unable to read inventory: %w", err)
+	}
+
+	errs := &InventoryError{}
+
+	var hosts []*inventoryHost
+	switch strings.ToLower(filepath.Ext(p.path)) {
+	case ".json":
+		hosts = parseJSONInventory(p.path, data, errs)
+	case ".csv":
+		hosts = parseCSVInventory(p.path, data, errs)
+	default:
+		hosts = parseYAMLInventory(p.path, data, errs)
+	}
+
+	insts := inventoryInstances(p.path, hosts, errs)
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	p.setInstances(insts)
+
+	return nil
+}
+
+// Watch reloads the instances when the inventory file changes
+func (p *FileProvider) Watch(ctx context.Context, changed func()) {
+	watchFiles(ctx, func() []string { return []string{p.path} }, changed)
+}
+
+// inventoryFields are the fields of the inventory hosts
+var inventoryFields = map[string]struct{}{
+	"id": {}, "name": {}, "private_ip": {}, "public_ip": {}, "user": {}, "port": {}, "state": {}, "zone": {}, "type": {}, "tags": {},
+}
+
+// parseYAMLInventory reads a list of hosts, or a mapping with a hosts list
+func parseYAMLInventory(path string, data []byte, errs *InventoryError) []*inventoryHost {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		line, message := yamlError(err)
+		errs.add(path, line, "%s", message)
+		return nil
+	}
+
+	if len(root.Content) == 0 {
+		return nil // empty file
+	}
+
+	list := root.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = yamlValue(list, "hosts")
+		if list == nil {
+			errs.add(path, root.Content[0].Line, "expected a list of hosts, or a hosts list")
+			return nil
+		}
+	}
+
+	if list.Kind != yaml.SequenceNode {
+		errs.add(path, list.Line, "expected a list of hosts")
+		return nil
+	}
+
+	hosts := []*inventoryHost{}
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			errs.add(path, item.Line, "expected a host mapping")
+			continue
+		}
+
+		valid := true
+		for idx := 0; idx < len(item.Content)-1; idx += 2 {
+			key := item.Content[idx]
+			if _, ok := inventoryFields[key.Value]; !ok {
+				errs.add(path, key.Line, "unknown field '%s'", key.Value)
+				valid = false
+			}
+		}
+
+		host := &inventoryHost{line: item.Line}
+		if err := item.Decode(host); err != nil {
+			errs.add(path, item.Line, "%s", yamlErrorMessage(err))
+			continue
+		}
+
+		if valid {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// yamlValue returns the value of a key of a mapping node
+func yamlValue(mapping *yaml.Node, key string) *yaml.Node {
+	for idx := 0; idx < len(mapping.Content)-1; idx += 2 {
+		if mapping.Content[idx].Value == key {
+			return mapping.Content[idx+1]
+		}
+	}
+
+	return nil
+}
+
+// yamlErrorMessage returns the message of a decoding error, without the yaml prefix and line
+// (the errors are reported at the line of the entry)
+func yamlErrorMessage(err error) string {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		message := typeErr.Errors[0]
+		if idx := strings.Index(message, ": "); strings.HasPrefix(message, "line ") && idx > 0 {
+			message = message[idx+2:]
+		}
+
+		return message
+	}
+
+	return strings.TrimPrefix(err.Error(), "yaml: ")
+}
+
+// parseJSONInventory reads a list of hosts, or an object with a hosts list
+func parseJSONInventory(path string, data []byte, errs *InventoryError) []*inventoryHost {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	syntaxError := func(err error) []*inventoryHost {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			errs.add(path, lineAt(data, syntaxErr.Offset), "%s", err)
+		} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			errs.add(path, lineAt(data, int64(len(data))), "unexpected end of file")
+		} else {
+			errs.add(path, lineAt(data, dec.InputOffset()), "%s", err)
+		}
+		return nil
+	}
+
+	token, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return nil // empty file
+	}
+	if err != nil {
+		return syntaxError(err)
+	}
+
+	if token == json.Delim('{') {
+		found := false
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return syntaxError(err)
+			}
+
+			if key == "hosts" {
+				found = true
+				if token, err = dec.Token(); err != nil {
+					return syntaxError(err)
+				}
+				break
+			}
+
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return syntaxError(err)
+			}
+		}
+
+		if !found {
+			errs.add(path, 1, "expected a list of hosts, or a hosts list")
+			return nil
+		}
+	}
+
+	if token != json.Delim('[') {
+		errs.add(path, lineAt(data, dec.InputOffset()), "expected a list of hosts")
+		return nil
+	}
+
+	hosts := []*inventoryHost{}
+	for dec.More() {
+		line := lineAt(data, nextValue(data, dec.InputOffset()))
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return syntaxError(err)
+		}
+
+		host := &inventoryHost{line: line}
+		hostDec := json.NewDecoder(bytes.NewReader(raw))
+		hostDec.DisallowUnknownFields()
+
+		if err := hostDec.Decode(host); err != nil {
+			errs.add(path, line, "%s", jsonErrorMessage(err))
+			continue
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts
+}
+
+// jsonErrorMessage returns the message of a decoding error, without the Go types
+func jsonErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		expected := "string"
+		switch typeErr.Type.Kind() {
+		case reflect.Int:
+			expected = "number"
+		case reflect.Map:
+			expected = "object"
+		}
+
+		return fmt.Sprintf("invalid %s (expected a %s, not a %s)", typeErr.Field, expected, typeErr.Value)
+	}
+
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+// nextValue returns the offset of the next value, after the whitespace and separator
+func nextValue(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,", data[offset]) >= 0 {
+		offset++
+	}
+
+	return offset
+}
+
+// lineAt returns the line number of an offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// parseCSVInventory reads hosts from a CSV file with a header, the tags column contains key=value
+// pairs separated by semicolons, and the unknown columns are tags (eg. env or tag:env)
+func parseCSVInventory(path string, data []byte, errs *InventoryError) []*inventoryHost {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil // empty file
+	}
+	if err != nil {
+		errs.add(path, csvErrorLine(err), "%s", csvErrorMessage(err))
+		return nil
+	}
+
+	for idx, column := range header {
+		header[idx] = strings.ToLower(strings.TrimSpace(column))
+	}
+
+	hosts := []*inventoryHost{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs.add(path, csvErrorLine(err), "%s", csvErrorMessage(err))
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		host := &inventoryHost{line: line, Tags: make(map[string]string)}
+		valid := true
+
+		for idx, value := range record {
+			value = strings.TrimSpace(value)
+			if len(value) == 0 {
+				continue
+			}
+
+			switch column := header[idx]; column {
+			case "id":
+				host.ID = value
+			case "name":
+				host.Name = value
+			case "private_ip":
+				host.PrivateIP = value
+			case "public_ip":
+				host.PublicIP = value
+			case "user":
+				host.User = value
+			case "state":
+				host.State = value
+			case "zone":
+				host.Zone = value
+			case "type":
+				host.Type = value
+			case "port":
+				port, err := strconv.Atoi(value)
+				if err != nil {
+					errs.add(path, line, "invalid port '%s'", value)
+					valid = false
+				}
+				host.Port = port
+			case "tags":
+				for _, pair := range strings.Split(value, ";") {
+					key, tag, ok := strings.Cut(pair, "=")
+					if !ok || len(strings.TrimSpace(key)) == 0 {
+						errs.add(path, line, "invalid tag '%s' (expected key=value)", pair)
+						valid = false
+						continue
+					}
+					host.Tags[strings.TrimSpace(key)] = strings.TrimSpace(tag)
+				}
+			default:
+				host.Tags[strings.TrimPrefix(column, TagFieldPrefix)] = value
+			}
+		}
+
+		if valid {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// csvErrorLine returns the line of a CSV parsing error
+func csvErrorLine(err error) int {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Line
+	}
+
+	return 0
+}
+
+// csvErrorMessage returns the message of a CSV parsing error, without the position
+func csvErrorMessage(err error) string {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Err.Error()
+	}
+
+	return err.Error()
+}
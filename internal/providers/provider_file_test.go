@@ -0,0 +1,239 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// loadInventory loads an inventory file written in a temporary directory
+func loadInventory(t *testing.T, name string, data string) (*FileProvider, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewFileProvider(&config.Profile{ID: "file", Inventory: path})
+	return p, p.LoadInstances(context.Background())
+}
+
+func TestFileInventory(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want []string
+	}{
+		{"yaml list", "hosts.yaml", `
+- name: web-1
+  private_ip: 10.0.0.1
+- id: db
+  public_ip: db.example.com
+`, []string{"db", "web-1"}},
+		{"yaml hosts", "hosts.yml", `
+hosts:
+  - name: web-1
+    private_ip: 10.0.0.1
+`, []string{"web-1"}},
+		{"yaml empty", "hosts.yaml", "", nil},
+		{"json list", "hosts.json", `[{"name": "web-1", "private_ip": "10.0.0.1"}, {"id": "db", "public_ip": "192.0.2.1"}]`, []string{"db", "web-1"}},
+		{"json hosts", "hosts.json", `{"version": 1, "hosts": [{"name": "web-1", "private_ip": "10.0.0.1"}]}`, []string{"web-1"}},
+		{"json empty", "hosts.json", "", nil},
+		{"csv", "hosts.csv", "name,private_ip\n# comment\nweb-1,10.0.0.1\nweb-2,10.0.0.2\n", []string{"web-1", "web-2"}},
+		{"csv header only", "hosts.csv", "name,private_ip\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := loadInventory(t, test.file, test.data)
+			if err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			if got := strings.Join(instanceIDs(p), " "); got != strings.Join(test.want, " ") {
+				t.Errorf("instances = %s, want %s", got, strings.Join(test.want, " "))
+			}
+		})
+	}
+}
+
+func TestFileInventoryFields(t *testing.T) {
+	inventories := map[string]string{
+		"hosts.yaml": `
+- name: nas
+  private_ip: 192.168.1.10
+  user: admin
+  port: 2222
+  zone: rack-1
+  type: ds920
+  tags:
+    Env: home
+- name: spare
+  private_ip: 192.168.1.11
+  state: stopped
+`,
+		"hosts.json": `[
+  {"name": "nas", "private_ip": "192.168.1.10", "user": "admin", "port": 2222, "zone": "rack-1", "type": "ds920", "tags": {"Env": "home"}},
+  {"name": "spare", "private_ip": "192.168.1.11", "state": "stopped"}
+]`,
+		"hosts.csv": `name,private_ip,user,port,zone,type,tags,state
+nas,192.168.1.10,admin,2222,rack-1,ds920,Env=home,
+spare,192.168.1.11,,,,,,stopped
+`,
+	}
+
+	for file, data := range inventories {
+		t.Run(file, func(t *testing.T) {
+			p, err := loadInventory(t, file, data)
+			if err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			i := p.GetInstanceByID("nas")
+			if i == nil {
+				t.Fatal("host nas not loaded")
+			}
+
+			got := []interface{}{i.PrivateIP, i.User, i.Port, i.AZ, i.Type, i.Tags["env"], i.Tags["name"], i.State}
+			want := []interface{}{"192.168.1.10", "admin", 2222, "rack-1", "ds920", "home", "nas", "running"}
+			for idx := range want {
+				if got[idx] != want[idx] {
+					t.Errorf("nas = %v, want %v", got, want)
+					break
+				}
+			}
+
+			if i := p.GetInstanceByID("spare"); i == nil || i.State != "stopped" {
+				t.Errorf("spare = %+v, want a stopped host", i)
+			}
+		})
+	}
+}
+
+func TestFileInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want []string // errors, in order
+	}{
+		{"yaml syntax", "hosts.yaml", "- name: web\n\tprivate_ip: 10.0.0.1\n", []string{":2: found a tab character that violates indentation"}},
+		{"yaml not a list", "hosts.yaml", "name: web\n", []string{":1: expected a list of hosts, or a hosts list"}},
+		{"yaml unknown field", "hosts.yaml", "- name: web\n  private_ip: 10.0.0.1\n  adress: 10.0.0.2\n", []string{":3: unknown field 'adress'"}},
+		{"yaml invalid port", "hosts.yaml", "- name: web\n  private_ip: 10.0.0.1\n  port: ssh\n", []string{":1: cannot unmarshal !!str `ssh` into int"}},
+		{"yaml validation", "hosts.yaml", `
+- name: web
+- private_ip: 10.0.0.1
+- name: db
+  private_ip: "10.0.0.1 "
+- name: cache
+  public_ip: 10.0.0.2
+  port: 70000
+`, []string{
+			":2: host 'web' has no private_ip or public_ip",
+			":3: host without id or name",
+			":4: host 'db' has an invalid private_ip '10.0.0.1 '",
+			":6: host 'cache' has an invalid port 70000",
+		}},
+		{"yaml duplicate", "hosts.yaml", "- name: web\n  private_ip: 10.0.0.1\n- name: web\n  private_ip: 10.0.0.2\n", []string{":3: duplicate host 'web' (first defined on line 1)"}},
+		{"json syntax", "hosts.json", "[\n  {\"name\": \"web\",}\n]", []string{":2: invalid character '}'"}},
+		{"json truncated", "hosts.json", "[\n  {\"name\": \"web\"", []string{":2: unexpected end of file"}},
+		{"json not a list", "hosts.json", `{"name": "web"}`, []string{":1: expected a list of hosts, or a hosts list"}},
+		{"json unknown field", "hosts.json", "[\n  {\"name\": \"web\", \"private_ip\": \"10.0.0.1\"},\n  {\"name\": \"db\", \"adress\": \"10.0.0.2\"}\n]", []string{`:3: unknown field "adress"`}},
+		{"json invalid port", "hosts.json", "[\n  {\"name\": \"web\", \"private_ip\": \"10.0.0.1\", \"port\": \"22\"}\n]", []string{":2: invalid port (expected a number, not a string)"}},
+		{"json duplicate", "hosts.json", "[\n  {\"name\": \"web\", \"private_ip\": \"10.0.0.1\"},\n  {\"name\": \"web\", \"private_ip\": \"10.0.0.2\"}\n]", []string{":3: duplicate host 'web' (first defined on line 2)"}},
+		{"csv fields", "hosts.csv", "name,private_ip\nweb,10.0.0.1,extra\n", []string{":2: wrong number of fields"}},
+		{"csv quote", "hosts.csv", "name,private_ip\nweb,\"10.0.0.1\n", []string{":2: extraneous or missing \" in quoted-field"}},
+		{"csv port and tags", "hosts.csv", "name,private_ip,port,tags\nweb,10.0.0.1,ssh,env\n", []string{":2: invalid port 'ssh'", ":2: invalid tag 'env' (expected key=value)"}},
+		{"csv duplicate", "hosts.csv", "name,private_ip\n# first\nweb,10.0.0.1\nweb,10.0.0.2\n", []string{":4: duplicate host 'web' (first defined on line 3)"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadInventory(t, test.file, test.data)
+
+			var invErr *InventoryError
+			if !errors.As(err, &invErr) {
+				t.Fatalf("LoadInstances() error = %v, want an inventory error", err)
+			}
+
+			got := []string{}
+			for _, lineErr := range invErr.Errors {
+				got = append(got, lineErr.Error())
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("errors = %q, want %q", got, test.want)
+			}
+			for idx, want := range test.want {
+				if !strings.Contains(got[idx], test.file+want) {
+					t.Errorf("error %d = %q, want %q", idx, got[idx], test.file+want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileInventoryKeepsInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	p := NewFileProvider(&config.Profile{ID: "file", Inventory: path})
+
+	if err := p.LoadInstances(context.Background()); err == nil {
+		t.Error("LoadInstances() of a missing inventory must fail")
+	}
+
+	if err := os.WriteFile(path, []byte("- name: web\n  private_ip: 10.0.0.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("- name: web\n- name: db\n  private_ip: 10.0.0.2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadInstances(context.Background()); err == nil {
+		t.Error("LoadInstances() of an invalid inventory must fail")
+	}
+
+	if got := instanceIDs(p); len(got) != 1 || got[0] != "web" {
+		t.Errorf("instances = %v, want the previous ones", got)
+	}
+}
+
+func TestInventoryInstancesDuplicates(t *testing.T) {
+	// hosts without lines (eg. from dynamic inventories), or from other files
+	hosts := []*inventoryHost{
+		{ID: "web", PrivateIP: "10.0.0.1"},
+		{ID: "web", PrivateIP: "10.0.0.2"},
+		{ID: "db", PrivateIP: "10.0.0.3", path: "a.ini", line: 4},
+		{ID: "db", PrivateIP: "10.0.0.4", path: "b.ini", line: 2},
+	}
+
+	errs := &InventoryError{}
+	insts := inventoryInstances("script", hosts, errs)
+
+	got := []string{}
+	for _, err := range errs.Errors {
+		got = append(got, err.Error())
+	}
+
+	want := []string{
+		"script: duplicate host 'web' (first defined in script)",
+		"b.ini:2: duplicate host 'db' (first defined in a.ini:4)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+
+	if insts["web"] == nil || insts["web"].PrivateIP != "10.0.0.1" || insts["db"] == nil || insts["db"].PrivateIP != "10.0.0.3" {
+		t.Errorf("instances = %v, want the first hosts", insts)
+	}
+}
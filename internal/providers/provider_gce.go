@@ -0,0 +1,408 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const GCEDefaultEndpoint = "https://compute.googleapis.com" // GCEDefaultEndpoint is the public Compute Engine API endpoint
+
+// GCEDefaultTags are the instance labels displayed by default
+var GCEDefaultTags = []string{"name", "env", "environment", "app", "role"}
+
+type GCEProvider struct {
+	instanceStore
+
+	client  *http.Client
+	tokens  TokenSource
+	project string
+	err     error             // credentials error, returned when loading instances
+	images  map[string]string // source images of the boot disks by instance ID and disk URL (empty for disks without one), loaded once
+}
+
+func NewGCEProvider(profile *config.Profile) *GCEProvider {
+	client := &http.Client{Timeout: profile.GetTimeout()}
+
+	p := &GCEProvider{
+		instanceStore: newInstanceStore(profile, GCEDefaultTags),
+		client:        client,
+		project:       profile.Project,
+	}
+
+	creds, err := loadGCECredentials(profile.Credentials)
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	p.tokens = &gceTokenSource{client: client, creds: creds}
+
+	if len(p.project) == 0 && creds != nil {
+		p.project = creds.ProjectID
+		if len(p.project) == 0 {
+			p.project = creds.QuotaProject
+		}
+	}
+
+	for _, env := range []string{"GOOGLE_CLOUD_PROJECT", "CLOUDSDK_CORE_PROJECT"} {
+		if len(p.project) == 0 {
+			p.project = os.Getenv(env)
+		}
+	}
+
+	return p
+}
+
+// NewGCEProviderWithClient returns a provider using the given HTTP client and token source (eg. a
+// local stand-in of the Compute Engine API set as the profile endpoint, for tests)
+func NewGCEProviderWithClient(profile *config.Profile, client *http.Client, tokens TokenSource) *GCEProvider {
+	return &GCEProvider{
+		instanceStore: newInstanceStore(profile, GCEDefaultTags),
+		client:        client,
+		tokens:        tokens,
+		project:       profile.Project,
+	}
+}
+
+func (p *GCEProvider) Type() ProviderType {
+	return ProviderTypeGCP
+}
+
+func (p *GCEProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Zone", "Type", "Image", "Running"}
+}
+
+func (p *GCEProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+}
+
+// gceInstance is the subset of the Compute Engine instance resource used by gosh
+type gceInstance struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Status            string            `json:"status"`
+	StatusMessage     string            `json:"statusMessage"`
+	Zone              string            `json:"zone"`
+	MachineType       string            `json:"machineType"`
+	CPUPlatform       string            `json:"cpuPlatform"`
+	CreationTimestamp string            `json:"creationTimestamp"`
+	LastStartTime     string            `json:"lastStartTimestamp"`
+	Labels            map[string]string `json:"labels"`
+	Tags              struct {
+		Items []string `json:"items"`
+	} `json:"tags"`
+	Scheduling struct {
+		Preemptible       bool   `json:"preemptible"`
+		ProvisioningModel string `json:"provisioningModel"`
+	} `json:"scheduling"`
+	NetworkInterfaces []struct {
+		Name          string `json:"name"`
+		Network       string `json:"network"`
+		Subnetwork    string `json:"subnetwork"`
+		NetworkIP     string `json:"networkIP"`
+		IPv6Address   string `json:"ipv6Address"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+	Disks []struct {
+		DeviceName string `json:"deviceName"`
+		Boot       bool   `json:"boot"`
+		Source     string `json:"source"`
+	} `json:"disks"`
+	ServiceAccounts []struct {
+		Email string `json:"email"`
+	} `json:"serviceAccounts"`
+}
+
+// gceDisk is the subset of the Compute Engine disk resource used by gosh
+type gceDisk struct {
+	SelfLink    string `json:"selfLink"`
+	SourceImage string `json:"sourceImage"`
+}
+
+// gceAggregatedList is a page of the instances aggregated list, by zone
+type gceAggregatedList struct {
+	Items map[string]struct {
+		Instances []*gceInstance `json:"instances"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// gceAggregatedDisks is a page of the disks aggregated list, by zone
+type gceAggregatedDisks struct {
+	Items map[string]struct {
+		Disks []*gceDisk `json:"disks"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// LoadInstances lists the instances of the project in all the zones (or the zones of the profile
+// region), the image of an instance is the source image of its boot disk, from the disks list
+func (p *GCEProvider) LoadInstances(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	if len(p.project) == 0 {
+		return errors.New("no gcp project configured (set the profile project)")
+	}
+
+	insts := make(map[string]*Instance)
+	bootDisks := make(map[string]*Instance) // instances by boot disk URL
+	pageToken := ""
+
+	for {
+		page := &gceAggregatedList{}
+		if err := p.listPage(ctx, "instances", p.apiFilter(), pageToken, page); err != nil {
+			// keep the previously loaded instances
+			return err
+		}
+
+		for _, scope := range page.Items {
+			for _, instance := range scope.Instances {
+				i := newGCEInstance(instance)
+
+				if len(p.profile.Region) > 0 && !strings.HasPrefix(i.AZ, p.profile.Region+"-") {
+					continue
+				}
+
+				insts[i.ID] = i
+
+				for _, disk := range instance.Disks {
+					if disk.Boot && len(disk.Source) > 0 {
+						bootDisks[disk.Source] = i
+					}
+				}
+			}
+		}
+
+		if len(page.NextPageToken) == 0 {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	// the disks are only listed for the instances seen for the first time, an instance (ID) keeps
+	// the image of its boot disk
+	images := make(map[string]string)
+	missing := make(map[string]*Instance)
+
+	for disk, i := range bootDisks {
+		if image, ok := p.images[imageKey(i, disk)]; ok {
+			images[imageKey(i, disk)] = image
+		} else {
+			missing[disk] = i
+		}
+	}
+
+	if len(missing) > 0 {
+		if err := p.loadImages(ctx, missing, images); err != nil {
+			return err
+		}
+	}
+
+	for disk, i := range bootDisks {
+		if image := images[imageKey(i, disk)]; len(image) > 0 {
+			i.AMI = lastSegment(image)
+			i.addExtra("Instance", "Image", image)
+		}
+	}
+
+	p.images = images
+
+	p.setInstances(insts)
+
+	return nil
+}
+
+// imageKey identifies the boot disk of an instance in the images
+func imageKey(i *Instance, disk string) string {
+	return i.ID + " " + disk
+}
+
+// loadImages adds the source images of the boot disks to images, disks created from a snapshot or
+// another disk have no source image
+func (p *GCEProvider) loadImages(ctx context.Context, bootDisks map[string]*Instance, images map[string]string) error {
+	for disk, i := range bootDisks {
+		images[imageKey(i, disk)] = ""
+	}
+
+	pageToken := ""
+
+	for {
+		page := &gceAggregatedDisks{}
+		if err := p.listPage(ctx, "disks", "", pageToken, page); err != nil {
+			return err
+		}
+
+		for _, scope := range page.Items {
+			for _, disk := range scope.Disks {
+				if i, ok := bootDisks[disk.SelfLink]; ok {
+					images[imageKey(i, disk.SelfLink)] = disk.SourceImage
+				}
+			}
+		}
+
+		if len(page.NextPageToken) == 0 {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// listPage fetches a page of the aggregated list of a resource (eg. instances) into page
+func (p *GCEProvider) listPage(ctx context.Context, resource string, filter string, pageToken string, page interface{}) error {
+	endpoint := p.profile.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = GCEDefaultEndpoint
+	}
+
+	query := url.Values{}
+	query.Set("maxResults", "500")
+	query.Set("returnPartialSuccess", "true")
+	if len(filter) > 0 {
+		query.Set("filter", filter)
+	}
+	if len(pageToken) > 0 {
+		query.Set("pageToken", pageToken)
+	}
+
+	u := fmt.Sprintf("%s/compute/v1/projects/%s/aggregated/%s?%s", strings.TrimSuffix(endpoint, "/"), url.PathEscape(p.project), resource, query.Encode())
+
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	return getJSON(ctx, p.client, u, token, page)
+}
+
+// apiFilter returns the profile server-side filters as a Compute Engine filter expression, the
+// values of a filter are alternatives (eg. status: [RUNNING, STOPPING])
+func (p *GCEProvider) apiFilter() string {
+	names := make([]string, 0, len(p.profile.APIFilters))
+	for name := range p.profile.APIFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expressions := []string{}
+	for _, name := range names {
+		values := []string{}
+		for _, value := range p.profile.APIFilters[name] {
+			values = append(values, fmt.Sprintf("(%s = %q)", name, value))
+		}
+
+		if len(values) > 0 {
+			expressions = append(expressions, "("+strings.Join(values, " OR ")+")")
+		}
+	}
+
+	return strings.Join(expressions, " AND ")
+}
+
+// gceStates maps the Compute Engine statuses to the states used by the other providers
+var gceStates = map[string]string{
+	"PROVISIONING": "pending",
+	"STAGING":      "pending",
+	"RUNNING":      "running",
+	"STOPPING":     "stopping",
+	"SUSPENDING":   "stopping",
+	"SUSPENDED":    "stopped",
+	"TERMINATED":   "stopped",
+}
+
+func newGCEInstance(ins *gceInstance) *Instance {
+	i := &Instance{
+		ID:    ins.ID,
+		State: strings.ToLower(ins.Status),
+		AZ:    lastSegment(ins.Zone),
+		Type:  lastSegment(ins.MachineType),
+		Tags:  make(map[string]string),
+	}
+
+	if state, ok := gceStates[ins.Status]; ok {
+		i.State = state
+	}
+
+	launched := ins.LastStartTime
+	if len(launched) == 0 {
+		launched = ins.CreationTimestamp
+	}
+	i.Launched, _ = time.Parse(time.RFC3339, launched)
+
+	for key, value := range ins.Labels {
+		i.Tags[strings.ToLower(key)] = value
+	}
+	if _, ok := i.Tags["name"]; !ok {
+		i.Tags["name"] = ins.Name
+	}
+
+	i.addExtra("Instance", "Name", ins.Name)
+	i.addExtra("Instance", "Status message", ins.StatusMessage)
+	i.addExtra("Instance", "CPU platform", ins.CPUPlatform)
+	i.addExtra("Instance", "Network tags", strings.Join(ins.Tags.Items, ", "))
+
+	model := strings.ToLower(ins.Scheduling.ProvisioningModel)
+	if ins.Scheduling.Preemptible {
+		model = "preemptible"
+	}
+	if len(model) == 0 {
+		model = "standard"
+	}
+	i.addExtra("Instance", "Provisioning model", model)
+
+	for _, account := range ins.ServiceAccounts {
+		i.addExtra("Instance", "Service account", account.Email)
+	}
+
+	for idx, nic := range ins.NetworkInterfaces {
+		if idx == 0 {
+			i.PrivateIP = nic.NetworkIP
+			i.VPC = lastSegment(nic.Network)
+		}
+
+		section := fmt.Sprintf("Network interface %s", nic.Name)
+		i.addExtra(section, "Network", lastSegment(nic.Network))
+		i.addExtra(section, "Subnetwork", lastSegment(nic.Subnetwork))
+		i.addExtra(section, "Private IP", nic.NetworkIP)
+		i.addExtra(section, "IPv6", nic.IPv6Address)
+
+		for _, access := range nic.AccessConfigs {
+			if len(i.PublicIP) == 0 {
+				i.PublicIP = access.NatIP
+			}
+			i.addExtra(section, "Public IP", access.NatIP)
+		}
+	}
+
+	for _, disk := range ins.Disks {
+		value := lastSegment(disk.Source)
+		if disk.Boot {
+			value += " (boot)"
+		}
+
+		i.addExtra("Disks", disk.DeviceName, value)
+	}
+
+	return i
+}
+
+// lastSegment returns the name at the end of a resource URL (eg. .../zones/us-central1-a)
+func lastSegment(url string) string {
+	if idx := strings.LastIndex(url, "/"); idx >= 0 {
+		return url[idx+1:]
+	}
+
+	return url
+}
@@ -0,0 +1,323 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const fakeGCEProject = "test-project"
+
+// fakeCompute is a local fake of the Compute Engine API, serving the aggregated lists of instances
+// and disks, one zone per page
+type fakeCompute struct {
+	*httptest.Server
+
+	zones   map[string][]string // instance names by zone
+	fail    int                 // status code returned to all the requests when set
+	mutex   sync.Mutex
+	filters []string // filter of the instances list requests
+	disks   int      // disks lists (requests of their first page)
+}
+
+func newFakeCompute(t *testing.T, zones map[string][]string) *fakeCompute {
+	f := &fakeCompute{zones: zones}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeCompute) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"code": 401, "message": "invalid credentials"}}`)
+		return
+	}
+
+	if f.fail != 0 {
+		w.WriteHeader(f.fail)
+		fmt.Fprint(w, `{"error": {"code": 403, "message": "fake failure"}}`)
+		return
+	}
+
+	prefix := "/compute/v1/projects/" + fakeGCEProject + "/aggregated/"
+	resource := strings.TrimPrefix(r.URL.Path, prefix)
+	if resource == r.URL.Path || (resource != "instances" && resource != "disks") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": {"code": 404, "message": "not found: %s"}}`, r.URL.Path)
+		return
+	}
+
+	query := r.URL.Query()
+	f.mutex.Lock()
+	if resource == "instances" {
+		f.filters = append(f.filters, query.Get("filter"))
+	} else if len(query.Get("pageToken")) == 0 {
+		f.disks++
+	}
+	f.mutex.Unlock()
+
+	zones := []string{}
+	for zone := range f.zones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	// one zone per page, the page token is the index of the zone
+	page := 0
+	if token := query.Get("pageToken"); len(token) > 0 {
+		page, _ = strconv.Atoi(token)
+	}
+
+	body := map[string]interface{}{"items": map[string]interface{}{}}
+	if page < len(zones) {
+		zone := zones[page]
+		items := []map[string]interface{}{}
+		for idx, name := range f.zones[zone] {
+			items = append(items, f.resource(resource, zone, name, idx))
+		}
+
+		body["items"] = map[string]interface{}{"zones/" + zone: map[string]interface{}{resource: items}}
+	}
+	if page+1 < len(zones) {
+		body["nextPageToken"] = strconv.Itoa(page + 1)
+	}
+
+	json.NewEncoder(w).Encode(body)
+}
+
+// resource returns the instance or the boot disk of an instance, the disks of the instances named
+// "*-snapshot" are created from a snapshot (without a source image)
+func (f *fakeCompute) resource(resource string, zone string, name string, idx int) map[string]interface{} {
+	base := "https://www.googleapis.com/compute/v1/projects/" + fakeGCEProject
+	disk := base + "/zones/" + zone + "/disks/" + name
+
+	if resource == "disks" {
+		d := map[string]interface{}{"name": name, "selfLink": disk}
+		if !strings.HasSuffix(name, "-snapshot") {
+			d["sourceImage"] = "https://www.googleapis.com/compute/v1/projects/debian-cloud/global/images/debian-12-" + name
+		}
+
+		return d
+	}
+
+	return map[string]interface{}{
+		"id":                 "id-" + name,
+		"name":               name,
+		"status":             "RUNNING",
+		"zone":               base + "/zones/" + zone,
+		"machineType":        base + "/zones/" + zone + "/machineTypes/e2-small",
+		"lastStartTimestamp": "2024-01-10T12:00:00.000-08:00",
+		"labels":             map[string]string{"Env": "prod"},
+		"networkInterfaces": []map[string]interface{}{{
+			"name":          "nic0",
+			"network":       base + "/global/networks/default",
+			"networkIP":     fmt.Sprintf("10.0.0.%d", idx+1),
+			"accessConfigs": []map[string]string{{"natIP": fmt.Sprintf("34.0.0.%d", idx+1)}},
+		}},
+		"disks": []map[string]interface{}{
+			{"deviceName": "boot", "boot": true, "source": disk},
+			{"deviceName": "data", "source": disk + "-data"},
+		},
+	}
+}
+
+// newTestGCEProvider returns a provider using the fake Compute Engine API
+func newTestGCEProvider(f *fakeCompute, profile *config.Profile) *GCEProvider {
+	profile.ID = "gcp"
+	profile.Project = fakeGCEProject
+	profile.Endpoint = f.URL
+
+	return NewGCEProviderWithClient(profile, f.Client(), apiToken("test-token"))
+}
+
+func TestGCELoadInstances(t *testing.T) {
+	f := newFakeCompute(t, map[string][]string{
+		"europe-west1-b": {"eu-1"},
+		"us-central1-a":  {"us-1", "us-2"},
+		"us-central1-f":  {"us-3"},
+		"us-east1-b":     {},
+	})
+
+	tests := []struct {
+		name   string
+		region string
+		want   []string
+	}{
+		{"all zones", "", []string{"id-eu-1", "id-us-1", "id-us-2", "id-us-3"}},
+		{"region", "us-central1", []string{"id-us-1", "id-us-2", "id-us-3"}},
+		{"region prefix", "us-central", nil},
+		{"region without instances", "us-east1", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestGCEProvider(f, &config.Profile{Region: test.region})
+
+			if err := p.LoadInstances(context.Background()); err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+
+			if got := strings.Join(instanceIDs(p), " "); got != strings.Join(test.want, " ") {
+				t.Errorf("instances = %s, want %s", got, strings.Join(test.want, " "))
+			}
+		})
+	}
+}
+
+func TestGCEInstanceFields(t *testing.T) {
+	f := newFakeCompute(t, map[string][]string{
+		"us-central1-a": {"web", "web-snapshot"},
+	})
+
+	p := newTestGCEProvider(f, &config.Profile{APIFilters: map[string][]string{"status": {"RUNNING", "STAGING"}, "labels.env": {"prod"}}})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	want := `((labels.env = "prod")) AND ((status = "RUNNING") OR (status = "STAGING"))`
+	if len(f.filters) != 1 || f.filters[0] != want {
+		t.Errorf("filters = %q, want %q", f.filters, want)
+	}
+
+	i := p.GetInstanceByID("id-web")
+	if i == nil {
+		t.Fatal("instance id-web not loaded")
+	}
+
+	fields := map[string]string{
+		"private_ip": i.PrivateIP,
+		"public_ip":  i.PublicIP,
+		"state":      i.State,
+		"az":         i.AZ,
+		"type":       i.Type,
+		"ami":        i.AMI,
+		"vpc":        i.VPC,
+		"name":       i.Tags["name"],
+		"env":        i.Tags["env"],
+	}
+	wantFields := map[string]string{
+		"private_ip": "10.0.0.1",
+		"public_ip":  "34.0.0.1",
+		"state":      "running",
+		"az":         "us-central1-a",
+		"type":       "e2-small",
+		"ami":        "debian-12-web",
+		"vpc":        "default",
+		"name":       "web",
+		"env":        "prod",
+	}
+	for field, value := range wantFields {
+		if fields[field] != value {
+			t.Errorf("%s = %q, want %q", field, fields[field], value)
+		}
+	}
+
+	if launched := i.Launched.UTC().Format("2006-01-02 15:04"); launched != "2024-01-10 20:00" {
+		t.Errorf("launched = %s, want 2024-01-10 20:00", launched)
+	}
+
+	// a boot disk created from a snapshot has no image
+	if i := p.GetInstanceByID("id-web-snapshot"); i == nil || len(i.AMI) > 0 {
+		t.Errorf("instance id-web-snapshot = %+v, want no image", i)
+	}
+}
+
+func TestGCELoadInstancesErrors(t *testing.T) {
+	f := newFakeCompute(t, map[string][]string{"us-central1-a": {"web"}})
+
+	p := newTestGCEProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	// the previous instances are kept when loading fails
+	f.fail = http.StatusForbidden
+	err := p.LoadInstances(context.Background())
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden || httpErr.Message != "fake failure" {
+		t.Errorf("LoadInstances() error = %v, want a 403 fake failure", err)
+	}
+
+	if got := instanceIDs(p); len(got) != 1 || got[0] != "id-web" {
+		t.Errorf("instances = %v, want the previous ones", got)
+	}
+
+	// invalid token
+	p = NewGCEProviderWithClient(&config.Profile{ID: "gcp", Project: fakeGCEProject, Endpoint: f.URL}, f.Client(), apiToken("bad-token"))
+	if err := p.LoadInstances(context.Background()); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("LoadInstances() error = %v, want a 401", err)
+	}
+
+	// no project
+	p = NewGCEProviderWithClient(&config.Profile{ID: "gcp", Endpoint: f.URL}, f.Client(), apiToken("test-token"))
+	if err := p.LoadInstances(context.Background()); err == nil || !strings.Contains(err.Error(), "no gcp project") {
+		t.Errorf("LoadInstances() error = %v, want a missing project error", err)
+	}
+}
+
+func TestGCELoadInstancesImagesCached(t *testing.T) {
+	f := newFakeCompute(t, map[string][]string{
+		"us-central1-a": {"us-1", "us-snapshot"},
+	})
+	p := newTestGCEProvider(f, &config.Profile{})
+
+	// the disks are listed for the instances seen for the first time only
+	steps := []struct {
+		name      string
+		zones     map[string][]string
+		wantDisks int
+	}{
+		{"first refresh", nil, 1},
+		{"same instances", nil, 1},
+		{"new instance", map[string][]string{"us-central1-a": {"us-1", "us-snapshot"}, "us-central1-f": {"us-2"}}, 2},
+		{"instance removed", map[string][]string{"us-central1-f": {"us-2"}}, 2},
+	}
+
+	for _, step := range steps {
+		if step.zones != nil {
+			f.mutex.Lock()
+			f.zones = step.zones
+			f.mutex.Unlock()
+		}
+
+		if err := p.LoadInstances(context.Background()); err != nil {
+			t.Fatalf("%s: LoadInstances() error = %v", step.name, err)
+		}
+
+		f.mutex.Lock()
+		disks := f.disks
+		f.mutex.Unlock()
+
+		if disks != step.wantDisks {
+			t.Errorf("%s: %d disks lists, want %d", step.name, disks, step.wantDisks)
+		}
+
+		// the images are kept between the refreshes
+		for _, i := range p.GetInstances() {
+			want := "debian-12-" + strings.TrimPrefix(i.ID, "id-")
+			if strings.HasSuffix(i.ID, "-snapshot") {
+				want = ""
+			}
+
+			if i.AMI != want {
+				t.Errorf("%s: instance %s image = %q, want %q", step.name, i.ID, i.AMI, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const HetznerDefaultEndpoint = "https://api.hetzner.cloud" // HetznerDefaultEndpoint is the public Hetzner Cloud API endpoint
+
+// HetznerDefaultTags are the server labels displayed by default
+var HetznerDefaultTags = []string{"name", "env", "environment", "role"}
+
+type HetznerProvider struct {
+	restProvider
+}
+
+func NewHetznerProvider(profile *config.Profile) *HetznerProvider {
+	tokens, err := loadAPIToken(profile.Credentials, "HCLOUD_TOKEN")
+
+	p := NewHetznerProviderWithClient(profile, &http.Client{Timeout: profile.GetTimeout()}, tokens)
+	p.err = err
+
+	return p
+}
+
+// NewHetznerProviderWithClient returns a provider using the given HTTP client and token source (eg. a
+// local stand-in of the Hetzner Cloud API set as the profile endpoint, for tests)
+func NewHetznerProviderWithClient(profile *config.Profile, client *http.Client, tokens TokenSource) *HetznerProvider {
+	return &HetznerProvider{
+		restProvider: newRESTProvider(profile, client, tokens, HetznerDefaultEndpoint, HetznerDefaultTags),
+	}
+}
+
+func (p *HetznerProvider) Type() ProviderType {
+	return ProviderTypeHetzner
+}
+
+func (p *HetznerProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Datacenter", "Type", "Image", "Running"}
+}
+
+func (p *HetznerProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+}
+
+// LoadInstances lists the servers of the project (eg. api_filters label_selector: [env=prod])
+func (p *HetznerProvider) LoadInstances(ctx context.Context) error {
+	query := url.Values{"per_page": {"50"}}
+
+	return p.loadInstances(ctx, "/v1/servers", query, func() restPage {
+		return &hetznerServersPage{}
+	})
+}
+
+// hetznerServer is the subset of the server resource used by gosh
+type hetznerServer struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	Created   string            `json:"created"`
+	Labels    map[string]string `json:"labels"`
+	PublicNet struct {
+		IPv4 *struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+		IPv6 *struct {
+			IP string `json:"ip"`
+		} `json:"ipv6"`
+	} `json:"public_net"`
+	PrivateNet []struct {
+		Network int64  `json:"network"`
+		IP      string `json:"ip"`
+	} `json:"private_net"`
+	ServerType struct {
+		Name   string  `json:"name"`
+		Cores  int     `json:"cores"`
+		Memory float64 `json:"memory"`
+		Disk   int     `json:"disk"`
+	} `json:"server_type"`
+	Datacenter struct {
+		Name     string `json:"name"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"location"`
+	} `json:"datacenter"`
+	Image *struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"image"`
+}
+
+// hetznerServersPage is a page of the servers list
+type hetznerServersPage struct {
+	Servers []*hetznerServer `json:"servers"`
+	Meta    struct {
+		Pagination struct {
+			NextPage *int `json:"next_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+func (page *hetznerServersPage) instances() []*Instance {
+	insts := make([]*Instance, 0, len(page.Servers))
+	for _, server := range page.Servers {
+		insts = append(insts, newHetznerInstance(server))
+	}
+
+	return insts
+}
+
+func (page *hetznerServersPage) next() bool {
+	return page.Meta.Pagination.NextPage != nil
+}
+
+// hetznerStates maps the server statuses to the states used by the other providers
+var hetznerStates = map[string]string{
+	"initializing": "pending",
+	"starting":     "pending",
+	"running":      "running",
+	"stopping":     "stopping",
+	"off":          "stopped",
+}
+
+func newHetznerInstance(server *hetznerServer) *Instance {
+	i := &Instance{
+		ID:    strconv.FormatInt(server.ID, 10),
+		State: server.Status,
+		AZ:    server.Datacenter.Name,
+		Type:  server.ServerType.Name,
+		Tags:  make(map[string]string),
+	}
+
+	if state, ok := hetznerStates[server.Status]; ok {
+		i.State = state
+	}
+
+	if image := server.Image; image != nil {
+		// snapshots and backups have no name
+		i.AMI = image.Name
+		if len(i.AMI) == 0 {
+			i.AMI = image.Description
+		}
+	}
+
+	for key, value := range server.Labels {
+		i.Tags[strings.ToLower(key)] = value
+	}
+	if _, ok := i.Tags["name"]; !ok {
+		i.Tags["name"] = server.Name
+	}
+
+	i.Launched, _ = time.Parse(time.RFC3339, server.Created)
+
+	if ipv4 := server.PublicNet.IPv4; ipv4 != nil {
+		i.PublicIP = ipv4.IP
+	}
+	if ipv6 := server.PublicNet.IPv6; ipv6 != nil {
+		i.addExtra("Networks", "Public IPv6", ipv6.IP)
+	}
+
+	for idx, network := range server.PrivateNet {
+		id := strconv.FormatInt(network.Network, 10)
+		if idx == 0 {
+			i.PrivateIP = network.IP
+			i.VPC = id
+		}
+
+		i.addExtra("Networks", "Private network "+id, network.IP)
+	}
+
+	i.addExtra("Instance", "Name", server.Name)
+	i.addExtra("Instance", "Location", strings.TrimSuffix(server.Datacenter.Location.City+", "+server.Datacenter.Location.Country, ", "))
+	i.addExtra("Instance", "Cores", strconv.Itoa(server.ServerType.Cores))
+	i.addExtra("Instance", "Memory", fmt.Sprintf("%g GB", server.ServerType.Memory))
+	i.addExtra("Instance", "Disk", fmt.Sprintf("%d GB", server.ServerType.Disk))
+
+	return i
+}
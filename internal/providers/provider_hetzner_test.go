@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// hetznerServersBody returns a page of the servers list, next is the next page number (0 on the last page)
+func hetznerServersBody(next int, servers ...string) string {
+	nextPage := "null"
+	if next > 0 {
+		nextPage = fmt.Sprint(next)
+	}
+
+	return fmt.Sprintf(`{"servers": [%s], "meta": {"pagination": {"page": 1, "next_page": %s}}}`, strings.Join(servers, ", "), nextPage)
+}
+
+func hetznerServerBody(id int, status string, datacenter string) string {
+	return fmt.Sprintf(`{"id": %d, "name": "server-%d", "status": %q, "datacenter": {"name": %q}}`, id, id, status, datacenter)
+}
+
+func newTestHetznerProvider(f *fakeREST, profile *config.Profile) *HetznerProvider {
+	profile.ID = "hetzner"
+	profile.Endpoint = f.URL
+
+	return NewHetznerProviderWithClient(profile, f.Client(), apiToken("test-token"))
+}
+
+func TestHetznerLoadInstances(t *testing.T) {
+	f := newFakeREST(t, "/v1/servers",
+		hetznerServersBody(2, hetznerServerBody(1, "initializing", "fsn1-dc14"), hetznerServerBody(2, "starting", "fsn1-dc14")),
+		hetznerServersBody(3, hetznerServerBody(3, "running", "nbg1-dc3"), hetznerServerBody(4, "stopping", "nbg1-dc3")),
+		hetznerServersBody(0, hetznerServerBody(5, "off", "fsn1-dc14"), hetznerServerBody(6, "migrating", "hel1-dc2")),
+	)
+
+	p := newTestHetznerProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if got := f.requestedPages(); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("requested pages = %v, want 1 to 3", got)
+	}
+
+	states := map[string]string{}
+	for _, i := range p.GetInstances() {
+		states[i.ID] = i.State
+	}
+
+	// unknown statuses are kept
+	want := map[string]string{"1": "pending", "2": "pending", "3": "running", "4": "stopping", "5": "stopped", "6": "migrating"}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+
+	// the region is the location prefix of the datacenters
+	p = newTestHetznerProvider(f, &config.Profile{Region: "fsn1"})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if got := sortedIDs(p); !reflect.DeepEqual(got, []string{"1", "2", "5"}) {
+		t.Errorf("instances = %v, want the fsn1 servers", got)
+	}
+}
+
+func TestHetznerInstanceFields(t *testing.T) {
+	f := newFakeREST(t, "/v1/servers", hetznerServersBody(0, `{
+		"id": 42,
+		"name": "web-1",
+		"status": "running",
+		"created": "2024-01-10T21:00:00+01:00",
+		"labels": {"Env": "prod", "role": "web"},
+		"public_net": {"ipv4": {"ip": "95.0.0.1"}, "ipv6": {"ip": "2a01:4f8::/64"}},
+		"private_net": [{"network": 7, "ip": "10.0.0.2"}, {"network": 8, "ip": "10.1.0.2"}],
+		"server_type": {"name": "cx22"},
+		"datacenter": {"name": "fsn1-dc14"},
+		"image": {"name": "ubuntu-24.04", "description": "Ubuntu 24.04"}
+	}`, `{
+		"id": 43,
+		"name": "backup-1",
+		"status": "off",
+		"labels": {"name": "db"},
+		"public_net": {"ipv4": null},
+		"datacenter": {"name": "fsn1-dc14"},
+		"image": {"name": null, "description": "db backup"}
+	}`, `{
+		"id": 44,
+		"name": "no-image",
+		"status": "running",
+		"datacenter": {"name": "fsn1-dc14"},
+		"image": null
+	}`))
+
+	p := newTestHetznerProvider(f, &config.Profile{APIFilters: map[string][]string{"label_selector": {"env=prod"}}})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	f.mutex.Lock()
+	if len(f.queries) != 1 || f.queries[0].Get("label_selector") != "env=prod" || f.queries[0].Get("per_page") != "50" {
+		t.Errorf("queries = %v, want the label selector and 50 servers per page", f.queries)
+	}
+	f.mutex.Unlock()
+
+	i := p.GetInstanceByID("42")
+	if i == nil {
+		t.Fatal("server 42 not loaded")
+	}
+
+	fields := map[string]string{
+		"private_ip": i.PrivateIP,
+		"public_ip":  i.PublicIP,
+		"state":      i.State,
+		"az":         i.AZ,
+		"type":       i.Type,
+		"ami":        i.AMI,
+		"vpc":        i.VPC,
+		"name":       i.Tags["name"],
+		"env":        i.Tags["env"],
+		"role":       i.Tags["role"],
+	}
+	wantFields := map[string]string{
+		"private_ip": "10.0.0.2",
+		"public_ip":  "95.0.0.1",
+		"state":      "running",
+		"az":         "fsn1-dc14",
+		"type":       "cx22",
+		"ami":        "ubuntu-24.04",
+		"vpc":        "7",
+		"name":       "web-1",
+		"env":        "prod",
+		"role":       "web",
+	}
+	for field, value := range wantFields {
+		if fields[field] != value {
+			t.Errorf("%s = %q, want %q", field, fields[field], value)
+		}
+	}
+
+	if launched := i.Launched.UTC().Format("2006-01-02 15:04"); launched != "2024-01-10 20:00" {
+		t.Errorf("launched = %s, want 2024-01-10 20:00", launched)
+	}
+
+	// backups and snapshots have no image name, and the name label overrides the server name
+	i = p.GetInstanceByID("43")
+	if i == nil || i.AMI != "db backup" || i.Tags["name"] != "db" || len(i.PublicIP) > 0 {
+		t.Errorf("server 43 = %+v, want the db backup image, the db name and no public IP", i)
+	}
+
+	if i := p.GetInstanceByID("44"); i == nil || len(i.AMI) > 0 {
+		t.Errorf("server 44 = %+v, want no image", i)
+	}
+}
@@ -0,0 +1,155 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+const LinodeDefaultEndpoint = "https://api.linode.com" // LinodeDefaultEndpoint is the public Linode API endpoint
+
+// LinodeDefaultTags are the linode tags displayed by default, tags like env:prod are split
+var LinodeDefaultTags = []string{"name", "env", "environment", "role"}
+
+type LinodeProvider struct {
+	restProvider
+}
+
+func NewLinodeProvider(profile *config.Profile) *LinodeProvider {
+	tokens, err := loadAPIToken(profile.Credentials, "LINODE_TOKEN", "LINODE_CLI_TOKEN")
+
+	p := NewLinodeProviderWithClient(profile, &http.Client{Timeout: profile.GetTimeout()}, tokens)
+	p.err = err
+
+	return p
+}
+
+// NewLinodeProviderWithClient returns a provider using the given HTTP client and token source (eg. a
+// local stand-in of the Linode API set as the profile endpoint, for tests)
+func NewLinodeProviderWithClient(profile *config.Profile, client *http.Client, tokens TokenSource) *LinodeProvider {
+	return &LinodeProvider{
+		restProvider: newRESTProvider(profile, client, tokens, LinodeDefaultEndpoint, LinodeDefaultTags),
+	}
+}
+
+func (p *LinodeProvider) Type() ProviderType {
+	return ProviderTypeLinode
+}
+
+func (p *LinodeProvider) Headers() []string {
+	return []string{"ID", "Private IP", "Public IP", "State", "Region", "Type", "Image", "Running"}
+}
+
+func (p *LinodeProvider) Fields() []string {
+	return []string{"id", "private_ip", "public_ip", "state", "az", "type", "ami", "running"}
+}
+
+// LoadInstances lists the linodes of the account
+func (p *LinodeProvider) LoadInstances(ctx context.Context) error {
+	query := url.Values{"page_size": {"500"}}
+
+	return p.loadInstances(ctx, "/v4/linode/instances", query, func() restPage {
+		return &linodeInstancesPage{}
+	})
+}
+
+// linodeInstance is the subset of the linode resource used by gosh
+type linodeInstance struct {
+	ID         int64    `json:"id"`
+	Label      string   `json:"label"`
+	Status     string   `json:"status"`
+	Created    string   `json:"created"`
+	Type       string   `json:"type"`
+	Region     string   `json:"region"`
+	Image      string   `json:"image"`
+	IPv4       []string `json:"ipv4"`
+	IPv6       string   `json:"ipv6"`
+	Tags       []string `json:"tags"`
+	Hypervisor string   `json:"hypervisor"`
+	Specs      struct {
+		VCPUs  int `json:"vcpus"`
+		Memory int `json:"memory"`
+		Disk   int `json:"disk"`
+	} `json:"specs"`
+}
+
+// linodeInstancesPage is a page of the linodes list
+type linodeInstancesPage struct {
+	Data  []*linodeInstance `json:"data"`
+	Page  int               `json:"page"`
+	Pages int               `json:"pages"`
+}
+
+func (page *linodeInstancesPage) instances() []*Instance {
+	insts := make([]*Instance, 0, len(page.Data))
+	for _, linode := range page.Data {
+		insts = append(insts, newLinodeInstance(linode))
+	}
+
+	return insts
+}
+
+func (page *linodeInstancesPage) next() bool {
+	return page.Page < page.Pages
+}
+
+// linodeStates maps the linode statuses to the states used by the other providers
+var linodeStates = map[string]string{
+	"provisioning":  "pending",
+	"booting":       "pending",
+	"rebooting":     "pending",
+	"running":       "running",
+	"shutting_down": "stopping",
+	"offline":       "stopped",
+	"stopped":       "stopped",
+}
+
+func newLinodeInstance(linode *linodeInstance) *Instance {
+	i := &Instance{
+		ID:    strconv.FormatInt(linode.ID, 10),
+		State: linode.Status,
+		AZ:    linode.Region,
+		Type:  linode.Type,
+		AMI:   linode.Image,
+		Tags:  splitTags(linode.Tags),
+	}
+
+	if state, ok := linodeStates[linode.Status]; ok {
+		i.State = state
+	}
+	if _, ok := i.Tags["name"]; !ok {
+		i.Tags["name"] = linode.Label
+	}
+
+	// the creation time is in UTC, without time zone
+	i.Launched, _ = time.Parse("2006-01-02T15:04:05", linode.Created)
+
+	for _, address := range linode.IPv4 {
+		ip := net.ParseIP(address)
+		switch {
+		case ip == nil:
+			continue
+		case ip.IsPrivate() && len(i.PrivateIP) == 0:
+			i.PrivateIP = address
+		case !ip.IsPrivate() && len(i.PublicIP) == 0:
+			i.PublicIP = address
+		}
+
+		i.addExtra("Networks", "IPv4", address)
+	}
+	i.addExtra("Networks", "IPv6", linode.IPv6)
+
+	i.addExtra("Instance", "Label", linode.Label)
+	i.addExtra("Instance", "vCPUs", strconv.Itoa(linode.Specs.VCPUs))
+	i.addExtra("Instance", "Memory", fmt.Sprintf("%d MB", linode.Specs.Memory))
+	i.addExtra("Instance", "Disk", fmt.Sprintf("%d MB", linode.Specs.Disk))
+	i.addExtra("Instance", "Hypervisor", linode.Hypervisor)
+
+	return i
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// linodeInstancesBody returns the page of the linodes list
+func linodeInstancesBody(page int, pages int, linodes ...string) string {
+	return fmt.Sprintf(`{"data": [%s], "page": %d, "pages": %d, "results": 7}`, strings.Join(linodes, ", "), page, pages)
+}
+
+func linodeInstanceBody(id int, status string) string {
+	return fmt.Sprintf(`{"id": %d, "label": "linode-%d", "status": %q, "region": "us-east"}`, id, id, status)
+}
+
+func newTestLinodeProvider(f *fakeREST, profile *config.Profile) *LinodeProvider {
+	profile.ID = "linode"
+	profile.Endpoint = f.URL
+
+	return NewLinodeProviderWithClient(profile, f.Client(), apiToken("test-token"))
+}
+
+func TestLinodeLoadInstances(t *testing.T) {
+	f := newFakeREST(t, "/v4/linode/instances",
+		linodeInstancesBody(1, 3, linodeInstanceBody(1, "provisioning"), linodeInstanceBody(2, "booting"), linodeInstanceBody(3, "rebooting")),
+		linodeInstancesBody(2, 3, linodeInstanceBody(4, "running"), linodeInstanceBody(5, "shutting_down")),
+		linodeInstancesBody(3, 3, linodeInstanceBody(6, "offline"), linodeInstanceBody(7, "stopped"), linodeInstanceBody(8, "migrating")),
+	)
+
+	p := newTestLinodeProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if got := f.requestedPages(); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("requested pages = %v, want 1 to 3", got)
+	}
+
+	states := map[string]string{}
+	for _, i := range p.GetInstances() {
+		states[i.ID] = i.State
+	}
+
+	// unknown statuses are kept
+	want := map[string]string{
+		"1": "pending", "2": "pending", "3": "pending",
+		"4": "running", "5": "stopping",
+		"6": "stopped", "7": "stopped", "8": "migrating",
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+
+	// a single page
+	f = newFakeREST(t, "/v4/linode/instances", linodeInstancesBody(1, 1, linodeInstanceBody(1, "running")))
+
+	p = newTestLinodeProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	if got := f.requestedPages(); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("requested pages = %v, want 1", got)
+	}
+}
+
+func TestLinodeInstanceFields(t *testing.T) {
+	f := newFakeREST(t, "/v4/linode/instances", linodeInstancesBody(1, 1, `{
+		"id": 42,
+		"label": "web-1",
+		"status": "running",
+		"created": "2024-01-10T20:00:00",
+		"type": "g6-standard-1",
+		"region": "us-east",
+		"image": "linode/ubuntu22.04",
+		"ipv4": ["172.0.0.1", "192.168.0.1", "172.0.0.2"],
+		"ipv6": "2600:3c00::1/128",
+		"tags": ["env=prod", "k8s"]
+	}`, `{
+		"id": 43,
+		"label": "db-1",
+		"status": "offline",
+		"region": "us-east",
+		"image": null,
+		"ipv4": ["192.168.0.2"],
+		"tags": ["name:db"]
+	}`))
+
+	p := newTestLinodeProvider(f, &config.Profile{})
+	if err := p.LoadInstances(context.Background()); err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+
+	f.mutex.Lock()
+	if len(f.queries) != 1 || f.queries[0].Get("page_size") != "500" {
+		t.Errorf("queries = %v, want 500 linodes per page", f.queries)
+	}
+	f.mutex.Unlock()
+
+	i := p.GetInstanceByID("42")
+	if i == nil {
+		t.Fatal("linode 42 not loaded")
+	}
+
+	fields := map[string]string{
+		"private_ip": i.PrivateIP,
+		"public_ip":  i.PublicIP,
+		"state":      i.State,
+		"az":         i.AZ,
+		"type":       i.Type,
+		"ami":        i.AMI,
+		"name":       i.Tags["name"],
+		"env":        i.Tags["env"],
+		"k8s":        i.Tags["k8s"],
+	}
+	wantFields := map[string]string{
+		"private_ip": "192.168.0.1",
+		"public_ip":  "172.0.0.1",
+		"state":      "running",
+		"az":         "us-east",
+		"type":       "g6-standard-1",
+		"ami":        "linode/ubuntu22.04",
+		"name":       "web-1",
+		"env":        "prod",
+		"k8s":        "true",
+	}
+	for field, value := range wantFields {
+		if fields[field] != value {
+			t.Errorf("%s = %q, want %q", field, fields[field], value)
+		}
+	}
+
+	// the creation time is in UTC, without time zone
+	if launched := i.Launched.UTC().Format("2006-01-02 15:04"); launched != "2024-01-10 20:00" {
+		t.Errorf("launched = %s, want 2024-01-10 20:00", launched)
+	}
+
+	// the name tag overrides the label
+	i = p.GetInstanceByID("43")
+	if i == nil || i.Tags["name"] != "db" || i.PrivateIP != "192.168.0.2" || len(i.PublicIP) > 0 || len(i.AMI) > 0 {
+		t.Errorf("linode 43 = %+v, want the db name, a private IP only and no image", i)
+	}
+}
@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/utils"
+)
+
+// TokenSource returns access tokens for a provider REST API
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// getJSON sends an authenticated GET request, and decodes the JSON response into out
+func getJSON(ctx context.Context, client *http.Client, url string, token string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return apiError(res.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid api response: %w", err)
+	}
+
+	return nil
+}
+
+// apiError returns the error of an API response, using the message of a JSON error body
+// (eg. {"error": {"message": "..."}}) when there is one
+func apiError(status int, body []byte) error {
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &res); err == nil && len(res.Error.Message) > 0 {
+		message = res.Error.Message
+	}
+
+	return &HTTPError{StatusCode: status, Message: message}
+}
+
+// apiToken is a static API token
+type apiToken string
+
+func (t apiToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// loadAPIToken reads the API token from the token file of the profile, or from the first environment
+// variable set, tokens are never read from the configuration itself
+func loadAPIToken(path string, envs ...string) (TokenSource, error) {
+	if len(path) > 0 {
+		data, err := os.ReadFile(utils.ExpandHome(path))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read api token: %w", err)
+		}
+
+		token := strings.TrimSpace(string(data))
+		if len(token) == 0 {
+			return nil, fmt.Errorf("empty api token file %s", path)
+		}
+
+		return apiToken(token), nil
+	}
+
+	for _, env := range envs {
+		if token := strings.TrimSpace(os.Getenv(env)); len(token) > 0 {
+			return apiToken(token), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no api token found (set %s, or the profile credentials token file)", strings.Join(envs, " or "))
+}
+
+// restPage is a page of a REST API list, decoded from the JSON response
+type restPage interface {
+	instances() []*Instance // instances returns the instances of the page
+	next() bool             // next indicates if there are more pages
+}
+
+// restProvider is the base of the providers listing instances from a JSON REST API paginated with
+// page numbers, and authenticated with an API token
+type restProvider struct {
+	instanceStore
+
+	client   *http.Client
+	tokens   TokenSource
+	endpoint string // default API endpoint, overridden by the profile endpoint
+	err      error  // token error, returned when loading instances
+}
+
+func newRESTProvider(profile *config.Profile, client *http.Client, tokens TokenSource, endpoint string, defaultTags []string) restProvider {
+	return restProvider{
+		instanceStore: newInstanceStore(profile, defaultTags),
+		client:        client,
+		tokens:        tokens,
+		endpoint:      endpoint,
+	}
+}
+
+// loadInstances fetches all the pages of the list at path, the profile API filters are sent as query
+// parameters and the instances are kept when they are in the profile region
+func (p *restProvider) loadInstances(ctx context.Context, path string, query url.Values, newPage func() restPage) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	for name, values := range p.profile.APIFilters {
+		for _, value := range values {
+			query.Add(name, value)
+		}
+	}
+
+	endpoint := p.profile.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = p.endpoint
+	}
+
+	insts := make(map[string]*Instance)
+
+	for number := 1; ; number++ {
+		query.Set("page", strconv.Itoa(number))
+		u := strings.TrimSuffix(endpoint, "/") + path + "?" + query.Encode()
+
+		page := newPage()
+		if err := getJSON(ctx, p.client, u, token, page); err != nil {
+			// keep the previously loaded instances
+			return err
+		}
+
+		for _, i := range page.instances() {
+			if len(p.profile.Region) > 0 && !strings.HasPrefix(i.AZ, p.profile.Region) {
+				continue
+			}
+
+			insts[i.ID] = i
+		}
+
+		if !page.next() {
+			break
+		}
+	}
+
+	p.setInstances(insts)
+
+	return nil
+}
+
+// splitTags returns the tags of providers using a list of strings as tags, a tag key:value (or
+// key=value) is split, other tags are set to true
+func splitTags(tags []string) map[string]string {
+	t := make(map[string]string)
+	for _, tag := range tags {
+		if idx := strings.IndexAny(tag, ":="); idx > 0 {
+			t[strings.ToLower(tag[:idx])] = tag[idx+1:]
+		} else {
+			t[strings.ToLower(tag)] = "true"
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,316 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// fakeREST is a local fake of a REST API paginated with page numbers, serving the JSON pages of the
+// list at path
+type fakeREST struct {
+	*httptest.Server
+
+	path    string
+	pages   []string // JSON bodies of the pages, by page number from 1
+	fail    int      // status code returned to all the requests when set
+	mutex   sync.Mutex
+	queries []url.Values // queries of the list requests
+}
+
+func newFakeREST(t *testing.T, path string, pages ...string) *fakeREST {
+	f := &fakeREST{path: path, pages: pages}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeREST) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"message": "invalid token"}}`)
+		return
+	}
+
+	if r.URL.Path != f.path {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": {"message": "%s not found"}}`, r.URL.Path)
+		return
+	}
+
+	if f.fail != 0 {
+		w.WriteHeader(f.fail)
+		fmt.Fprint(w, "fake failure")
+		return
+	}
+
+	query := r.URL.Query()
+	f.mutex.Lock()
+	f.queries = append(f.queries, query)
+	f.mutex.Unlock()
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 || page > len(f.pages) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": {"message": "invalid page %q"}}`, query.Get("page"))
+		return
+	}
+
+	fmt.Fprint(w, f.pages[page-1])
+}
+
+// requestedPages returns the page numbers of the list requests
+func (f *fakeREST) requestedPages() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	pages := []string{}
+	for _, query := range f.queries {
+		pages = append(pages, query.Get("page"))
+	}
+
+	return pages
+}
+
+// sortedIDs returns the sorted IDs of the loaded instances
+func sortedIDs(p interface{ GetInstances() []*Instance }) []string {
+	ids := []string{}
+	for _, i := range p.GetInstances() {
+		ids = append(ids, i.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// testPage is a page of a generic list, the instance IDs are the keys of the zones
+type testPage struct {
+	Zones map[string]string `json:"zones"`
+	More  bool              `json:"more"`
+}
+
+func (page *testPage) instances() []*Instance {
+	insts := []*Instance{}
+	for id, zone := range page.Zones {
+		insts = append(insts, &Instance{ID: id, AZ: zone, Tags: map[string]string{}})
+	}
+
+	return insts
+}
+
+func (page *testPage) next() bool {
+	return page.More
+}
+
+func newTestRESTProvider(f *fakeREST, profile *config.Profile) *restProvider {
+	profile.Endpoint = f.URL
+	p := newRESTProvider(profile, f.Client(), apiToken("test-token"), "http://invalid.test", nil)
+
+	return &p
+}
+
+func (p *restProvider) loadTestInstances(ctx context.Context) error {
+	return p.loadInstances(ctx, "/v1/list", url.Values{"limit": {"2"}}, func() restPage {
+		return &testPage{}
+	})
+}
+
+func TestRESTLoadInstances(t *testing.T) {
+	f := newFakeREST(t, "/v1/list",
+		`{"zones": {"a": "nyc1", "b": "ams3"}, "more": true}`,
+		`{"zones": {"c": "nyc3"}, "more": true}`,
+		`{"zones": {"d": "sfo2"}}`,
+	)
+
+	tests := []struct {
+		name   string
+		region string
+		want   []string
+	}{
+		{"all regions", "", []string{"a", "b", "c", "d"}},
+		{"region prefix", "nyc", []string{"a", "c"}},
+		{"region", "sfo2", []string{"d"}},
+		{"unknown region", "fra1", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestRESTProvider(f, &config.Profile{ID: "rest", Region: test.region})
+			if err := p.loadTestInstances(context.Background()); err != nil {
+				t.Fatalf("loadInstances() error = %v", err)
+			}
+
+			if got := sortedIDs(p); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("instances = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// all the pages are requested for every load
+	if got := f.requestedPages(); len(got) != 3*len(tests) || !reflect.DeepEqual(got[:3], []string{"1", "2", "3"}) {
+		t.Errorf("requested pages = %v, want pages 1 to 3 for each load", got)
+	}
+}
+
+func TestRESTLoadInstancesQuery(t *testing.T) {
+	f := newFakeREST(t, "/v1/list", `{"zones": {"a": "nyc1"}}`)
+
+	p := newTestRESTProvider(f, &config.Profile{ID: "rest", APIFilters: map[string][]string{"tag_name": {"web", "db"}}})
+	if err := p.loadTestInstances(context.Background()); err != nil {
+		t.Fatalf("loadInstances() error = %v", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	want := url.Values{"limit": {"2"}, "page": {"1"}, "tag_name": {"web", "db"}}
+	if len(f.queries) != 1 || !reflect.DeepEqual(f.queries[0], want) {
+		t.Errorf("queries = %v, want %v", f.queries, want)
+	}
+}
+
+func TestRESTLoadInstancesErrors(t *testing.T) {
+	f := newFakeREST(t, "/v1/list", `{"zones": {"a": "nyc1"}}`)
+
+	p := newTestRESTProvider(f, &config.Profile{ID: "rest"})
+	if err := p.loadTestInstances(context.Background()); err != nil {
+		t.Fatalf("loadInstances() error = %v", err)
+	}
+
+	// the previous instances are kept when loading fails
+	f.fail = http.StatusServiceUnavailable
+	err := p.loadTestInstances(context.Background())
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable || httpErr.Message != "fake failure" {
+		t.Errorf("loadInstances() error = %v, want a 503 fake failure", err)
+	}
+
+	if got := sortedIDs(p); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("instances = %v, want the previous ones", got)
+	}
+
+	// the message of JSON errors is used
+	p.tokens = apiToken("bad-token")
+	if err := p.loadTestInstances(context.Background()); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized || httpErr.Message != "invalid token" {
+		t.Errorf("loadInstances() error = %v, want a 401 invalid token", err)
+	}
+
+	// the token error is returned without sending requests
+	f.fail = 0
+	f.queries = nil
+	p.err = errors.New("no api token found")
+	if err := p.loadTestInstances(context.Background()); err != p.err {
+		t.Errorf("loadInstances() error = %v, want %v", err, p.err)
+	}
+	if got := f.requestedPages(); len(got) > 0 {
+		t.Errorf("requested pages = %v, want none", got)
+	}
+}
+
+func TestLoadAPIToken(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "token"), []byte("home-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		env  map[string]string
+		want string
+		err  string
+	}{
+		{"token file", write("token", " file-token\n"), map[string]string{"TEST_TOKEN": "env-token"}, "file-token", ""},
+		{"token file in home", "~/token", nil, "home-token", ""},
+		{"empty token file", write("empty", "\n"), map[string]string{"TEST_TOKEN": "env-token"}, "", "empty api token file"},
+		{"missing token file", filepath.Join(dir, "missing"), map[string]string{"TEST_TOKEN": "env-token"}, "", "unable to read api token"},
+		{"first env", "", map[string]string{"TEST_TOKEN": "env-token", "TEST_OTHER_TOKEN": "other-token"}, "env-token", ""},
+		{"second env", "", map[string]string{"TEST_TOKEN": " ", "TEST_OTHER_TOKEN": "other-token\n"}, "other-token", ""},
+		{"no token", "", nil, "", "no api token found (set TEST_TOKEN or TEST_OTHER_TOKEN, or the profile credentials token file)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TEST_TOKEN", test.env["TEST_TOKEN"])
+			t.Setenv("TEST_OTHER_TOKEN", test.env["TEST_OTHER_TOKEN"])
+
+			tokens, err := loadAPIToken(test.path, "TEST_TOKEN", "TEST_OTHER_TOKEN")
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("loadAPIToken() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadAPIToken() error = %v", err)
+			}
+
+			if token, _ := tokens.Token(context.Background()); token != test.want {
+				t.Errorf("token = %q, want %q", token, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	got := splitTags([]string{"Env:prod", "role=web", "k8s", "url:http://example.com"})
+	want := map[string]string{"env": "prod", "role": "web", "k8s": "true", "url": "http://example.com"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitTags() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultTags(t *testing.T) {
+	tags := map[string]string{"name": "web", "env": "prod", "app": "shop", "application": "shop", "groups": "web", "role": "front", "team": "ops"}
+	profile := &config.Profile{}
+
+	tests := []struct {
+		name  string
+		store *instanceStore
+		want  []string
+	}{
+		{"file", &NewFileProvider(profile).instanceStore, []string{"name", "env", "role"}},
+		{"digitalocean", &NewDigitalOceanProviderWithClient(profile, nil, nil).instanceStore, []string{"name", "env", "role"}},
+		{"hetzner", &NewHetznerProviderWithClient(profile, nil, nil).instanceStore, []string{"name", "env", "role"}},
+		{"linode", &NewLinodeProviderWithClient(profile, nil, nil).instanceStore, []string{"name", "env", "role"}},
+		{"gcp", &NewGCEProviderWithClient(profile, nil, nil).instanceStore, []string{"name", "env", "app", "role"}},
+		{"azure", &NewAzureProviderWithClient(profile, nil, nil).instanceStore, []string{"name", "env", "application", "role"}},
+		{"ansible", &NewAnsibleProvider(profile).instanceStore, []string{"name", "groups", "env", "role"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.store.setInstances(map[string]*Instance{"i-1": {ID: "i-1", Tags: tags}})
+
+			if got := test.store.GetTags(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetTags() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// instanceStore keeps the instances loaded by a provider, and implements the Provider methods
+// reading them
+type instanceStore struct {
+	profile     *config.Profile
+	defaultTags []string // tags displayed by default, in order, when the instances have them
+	instances   map[string]*Instance
+	mutex       sync.Mutex
+}
+
+func newInstanceStore(profile *config.Profile, defaultTags []string) instanceStore {
+	return instanceStore{
+		profile:     profile,
+		defaultTags: defaultTags,
+		instances:   make(map[string]*Instance),
+	}
+}
+
+// setInstances replaces the instances after a successful refresh
+func (s *instanceStore) setInstances(instances map[string]*Instance) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.instances = instances
+}
+
+func (s *instanceStore) InstancesCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.instances)
+}
+
+// GetTags returns the default tags of the provider found on the instances
+func (s *instanceStore) GetTags() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t := make(map[string]struct{})
+	for _, i := range s.instances {
+		for tag := range i.Tags {
+			t[tag] = struct{}{}
+		}
+	}
+
+	keys := []string{}
+	for _, tag := range s.defaultTags {
+		if _, ok := t[tag]; ok {
+			keys = append(keys, tag)
+		}
+	}
+
+	return keys
+}
+
+func (s *instanceStore) GetInstances() []*Instance {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	insts := make([]*Instance, 0, len(s.instances))
+	for _, i := range s.instances {
+		insts = append(insts, i)
+	}
+	sort.Sort(AWSInstanceSorter(insts))
+
+	return insts
+}
+
+func (s *instanceStore) GetInstanceByID(id string) *Instance {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.instances[id]
+}
+
+func (s *instanceStore) GetInstanceIPByID(id string) string {
+	instance := s.GetInstanceByID(id)
+	if instance == nil {
+		return ""
+	}
+
+	if (s.profile.PreferPublicIP || len(instance.PrivateIP) == 0) && len(instance.PublicIP) > 0 {
+		return instance.PublicIP
+	}
+
+	return instance.PrivateIP
+}
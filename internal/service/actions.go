@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// actionShortcuts are the keys selecting the actions in the menu
+var actionShortcuts = map[providers.Action]rune{
+	providers.ActionStart:     's',
+	providers.ActionStop:      'S',
+	providers.ActionHibernate: 'h',
+	providers.ActionReboot:    'b',
+	providers.ActionTerminate: 'T',
+}
+
+// ActionMenu lists the lifecycle actions available for the selected instance
+type ActionMenu struct {
+	slide    *Slide
+	view     *tview.List
+	instance *providers.Instance
+	visible  bool
+}
+
+func NewActionMenu(slide *Slide) *ActionMenu {
+	a := &ActionMenu{
+		slide: slide,
+	}
+
+	view := tview.NewList()
+	view.SetBorder(true)
+	view.SetTitle(" Actions ")
+	view.ShowSecondaryText(false)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			a.slide.closeActionMenu()
+			return nil
+		}
+
+		return event
+	})
+	a.view = view
+
+	return a
+}
+
+func (a *ActionMenu) Get() tview.Primitive {
+	return a.view
+}
+
+func (a *ActionMenu) Visible() bool {
+	return a.visible
+}
+
+// Show lists the actions available for the instance
+func (a *ActionMenu) Show(instance *providers.Instance, actions []providers.Action) {
+	a.instance = instance
+	a.view.Clear()
+	a.view.SetTitle(fmt.Sprintf(" %s ", tview.Escape(instanceName(instance))))
+
+	for _, action := range actions {
+		action := action
+		a.view.AddItem(string(action), "", actionShortcuts[action], func() {
+			a.slide.closeActionMenu()
+			a.slide.confirmAction(instance, action)
+		})
+	}
+
+	a.visible = true
+}
+
+func (a *ActionMenu) Hide() {
+	a.visible = false
+}
+
+// lifecycle returns the lifecycle manager of the provider, or nil if the profile is read-only or
+// the provider can't change the state of instances
+func (s *Slide) lifecycle() providers.LifecycleManager {
+	if s.profile.ReadOnly || s.provider == nil {
+		return nil
+	}
+
+	manager, _ := s.provider.(providers.LifecycleManager)
+	return manager
+}
+
+// openActionMenu displays the lifecycle actions of the selected instance next to the table
+func (s *Slide) openActionMenu() {
+	if s.profile.ReadOnly {
+		s.service.SetStatusText(s.profile.ID, "Profile '%s' is read-only", s.profile.ID)
+		return
+	}
+
+	manager := s.lifecycle()
+	if manager == nil {
+		s.service.SetStatusText(s.profile.ID, "Lifecycle actions are not supported by provider '%s'", s.profile.Provider)
+		return
+	}
+
+	instance := s.selectedInstance()
+	if instance == nil {
+		s.service.SetStatusText(s.profile.ID, "No instance selected")
+		return
+	}
+
+	actions := manager.Actions(instance)
+	if len(actions) == 0 {
+		s.service.SetStatusText(s.profile.ID, "No actions available for %s (%s)", instance.ID, instance.State)
+		return
+	}
+
+	s.actions.Show(instance, actions)
+	s.layout()
+	s.service.GetApp().SetFocus(s.actions.Get())
+}
+
+func (s *Slide) closeActionMenu() {
+	s.actions.Hide()
+	s.layout()
+	s.focusTable()
+}
+
+// confirmAction asks to confirm the action, destructive actions require typing the instance name
+func (s *Slide) confirmAction(instance *providers.Instance, action providers.Action) {
+	name := instanceName(instance)
+
+	if action.Destructive() {
+		label := fmt.Sprintf("Type '%s' to %s %s", name, action, instance.ID)
+		s.prompt.Ask(label, "", func(text string) {
+			if text != name {
+				s.service.SetStatusText(s.profile.ID, "Cancelled %s of %s, the name didn't match", action, instance.ID)
+				return
+			}
+
+			s.runAction(instance, action)
+		})
+		return
+	}
+
+	label := fmt.Sprintf("%s %s (%s)? [y/N]", strings.ToUpper(string(action[:1]))+string(action[1:]), name, instance.ID)
+	s.prompt.Ask(label, "", func(text string) {
+		if !strings.EqualFold(text, "y") && !strings.EqualFold(text, "yes") {
+			s.service.SetStatusText(s.profile.ID, "Cancelled %s of %s", action, instance.ID)
+			return
+		}
+
+		s.runAction(instance, action)
+	})
+}
+
+// runAction requests the action in the background, and refreshes the instances once accepted
+// so the transitional state (eg. stopping) is displayed
+func (s *Slide) runAction(instance *providers.Instance, action providers.Action) {
+	manager := s.lifecycle()
+	if manager == nil {
+		return
+	}
+
+	s.service.SetStatusText(s.profile.ID, "Requesting %s of %s", action, instance.ID)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(s.service.Context(), s.profile.GetTimeout())
+		defer cancel()
+
+		err := manager.RunAction(ctx, instance, action)
+
+		s.service.QueueUpdateDraw(func() {
+			if err != nil {
+				s.service.SetStatusText(s.profile.ID, "Unable to %s %s: %s", action, instance.ID, err)
+				return
+			}
+
+			s.service.SetStatusText(s.profile.ID, "Requested %s of %s", action, instance.ID)
+			s.update()
+		})
+	}()
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// fakeLifecycleProvider offers every action, and records the actions requested
+type fakeLifecycleProvider struct {
+	*fakeProvider
+
+	requestsMutex sync.Mutex
+	requests      []string // eg. stop web-1
+}
+
+func (p *fakeLifecycleProvider) Actions(instance *providers.Instance) []providers.Action {
+	return []providers.Action{providers.ActionStart, providers.ActionStop, providers.ActionHibernate, providers.ActionReboot, providers.ActionTerminate}
+}
+
+func (p *fakeLifecycleProvider) RunAction(ctx context.Context, instance *providers.Instance, action providers.Action) error {
+	p.requestsMutex.Lock()
+	defer p.requestsMutex.Unlock()
+
+	p.requests = append(p.requests, string(action)+" "+instance.ID)
+	return nil
+}
+
+func (p *fakeLifecycleProvider) requested() []string {
+	p.requestsMutex.Lock()
+	defer p.requestsMutex.Unlock()
+
+	return append([]string{}, p.requests...)
+}
+
+// newLifecycleSlide returns a page listing web-1 with a provider offering every action
+func newLifecycleSlide(t *testing.T, readOnly bool) (*Slide, *fakeLifecycleProvider) {
+	t.Helper()
+
+	slide, provider := newTestSlide(t, "web-1")
+	lifecycle := &fakeLifecycleProvider{fakeProvider: provider}
+
+	syncUI(t, slide.service, func() {
+		slide.provider = lifecycle
+		slide.profile.ReadOnly = readOnly
+	})
+	refresh(t, slide)
+
+	return slide, lifecycle
+}
+
+// answer enters a value in the prompt
+func answer(t *testing.T, slide *Slide, text string) {
+	t.Helper()
+
+	syncUI(t, slide.service, func() {
+		if !slide.prompt.Visible() {
+			t.Error("prompt not displayed")
+			return
+		}
+
+		slide.prompt.view.SetText(text)
+		pressKey(slide.prompt, tcell.KeyEnter)
+	})
+}
+
+// waitRequests waits until the provider received the requests
+func waitRequests(t *testing.T, provider *fakeLifecycleProvider, want int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(provider.requested()) < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return provider.requested()
+}
+
+func TestActionsReadOnly(t *testing.T) {
+	slide, provider := newLifecycleSlide(t, true)
+	selectInstance(t, slide, "web-1")
+
+	syncUI(t, slide.service, func() {
+		if slide.lifecycle() != nil {
+			t.Error("lifecycle() returned the provider of a read-only profile")
+		}
+
+		slide.openActionMenu()
+		if slide.actions.Visible() {
+			t.Error("action menu displayed on a read-only profile")
+		}
+
+		// even when requested directly
+		for _, action := range []providers.Action{providers.ActionStart, providers.ActionStop, providers.ActionHibernate, providers.ActionReboot, providers.ActionTerminate} {
+			slide.runAction(slide.selectedInstance(), action)
+		}
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	if requests := provider.requested(); len(requests) > 0 {
+		t.Errorf("requests = %v, want none on a read-only profile", requests)
+	}
+}
+
+func TestActionsConfirmation(t *testing.T) {
+	tests := []struct {
+		action providers.Action
+		wrong  string // cancels the action
+		right  string // confirms the action
+	}{
+		{providers.ActionStart, "n", "y"},
+		{providers.ActionStop, "y", "web-1"},
+		{providers.ActionHibernate, "yes", "web-1"},
+		{providers.ActionReboot, "web", "web-1"},
+		{providers.ActionTerminate, "WEB-1", "web-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.action), func(t *testing.T) {
+			slide, provider := newLifecycleSlide(t, false)
+			selectInstance(t, slide, "web-1")
+
+			confirm := func() {
+				syncUI(t, slide.service, func() {
+					slide.openActionMenu()
+					if !slide.actions.Visible() {
+						t.Fatal("action menu not displayed")
+					}
+
+					// select the action with its shortcut
+					event := tcell.NewEventKey(tcell.KeyRune, actionShortcuts[test.action], tcell.ModNone)
+					slide.actions.view.InputHandler()(event, func(p tview.Primitive) {})
+				})
+			}
+
+			confirm()
+			answer(t, slide, test.wrong)
+
+			time.Sleep(50 * time.Millisecond)
+			if requests := provider.requested(); len(requests) > 0 {
+				t.Fatalf("requests = %v after answering %q, want none", requests, test.wrong)
+			}
+
+			confirm()
+			answer(t, slide, test.right)
+
+			want := string(test.action) + " web-1"
+			if requests := waitRequests(t, provider, 1); len(requests) != 1 || requests[0] != want {
+				t.Errorf("requests = %v, want [%s]", requests, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// columns returns the columns configured in the profile, or the provider default columns
+func (s *Slide) columns() []*config.Column {
+	if len(s.profile.Columns) > 0 {
+		return s.profile.Columns
+	}
+
+	return s.defaultColumns()
+}
+
+// defaultColumns returns the tags found on the instances followed by the provider fields
+func (s *Slide) defaultColumns() []*config.Column {
+	columns := []*config.Column{}
+	if s.provider == nil {
+		return columns
+	}
+
+	for _, tag := range s.provider.GetTags() {
+		columns = append(columns, &config.Column{Field: providers.TagFieldPrefix + tag})
+	}
+
+	headers := s.provider.Headers()
+	for idx, field := range s.provider.Fields() {
+		column := &config.Column{Field: field}
+		if idx < len(headers) {
+			column.Label = headers[idx]
+		}
+		columns = append(columns, column)
+	}
+
+	return columns
+}
+
+// columnLabel returns the header label of a column
+func columnLabel(column *config.Column) string {
+	if len(column.Label) > 0 {
+		return column.Label
+	}
+
+	return providers.FieldLabel(column.Field)
+}
+
+// columnAlign returns the tview alignment of a column
+func columnAlign(column *config.Column) int {
+	switch column.Align {
+	case "center":
+		return tview.AlignCenter
+	case "right":
+		return tview.AlignRight
+	default:
+		return tview.AlignLeft
+	}
+}
+
+type pickerColumn struct {
+	column  *config.Column
+	enabled bool
+}
+
+// ColumnPicker lets the user toggle and reorder the columns of a page
+type ColumnPicker struct {
+	slide   *Slide
+	view    *tview.Table
+	columns []*pickerColumn
+	visible bool
+}
+
+func NewColumnPicker(slide *Slide) *ColumnPicker {
+	c := &ColumnPicker{
+		slide: slide,
+	}
+
+	view := tview.NewTable()
+	view.SetSelectable(true, false)
+	view.SetBorder(true)
+	view.SetTitle(" Columns ")
+	view.SetInputCapture(c.handleInput)
+	c.view = view
+
+	return c
+}
+
+func (c *ColumnPicker) Get() tview.Primitive {
+	return c.view
+}
+
+func (c *ColumnPicker) Visible() bool {
+	return c.visible
+}
+
+// Show lists the current columns (enabled) followed by all the other available fields and tags
+func (c *ColumnPicker) Show() {
+	c.columns = []*pickerColumn{}
+	used := make(map[string]struct{})
+
+	for _, column := range c.slide.columns() {
+		col := *column
+		c.columns = append(c.columns, &pickerColumn{column: &col, enabled: true})
+		used[column.Field] = struct{}{}
+	}
+
+	available := []string{}
+	if c.slide.provider != nil {
+		available = append(available, c.slide.provider.Fields()...)
+	}
+
+	fields := []string{}
+	for field := range providers.FieldLabels {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	available = append(available, fields...)
+	available = append(available, c.tagFields()...)
+
+	for _, field := range available {
+		if _, ok := used[field]; ok {
+			continue
+		}
+
+		c.columns = append(c.columns, &pickerColumn{column: &config.Column{Field: field}})
+		used[field] = struct{}{}
+	}
+
+	c.visible = true
+	c.render()
+	c.view.Select(0, 0)
+}
+
+func (c *ColumnPicker) Hide() {
+	c.visible = false
+}
+
+// tagFields returns all the tags found on the instances, sorted by name
+func (c *ColumnPicker) tagFields() []string {
+	if c.slide.provider == nil {
+		return nil
+	}
+
+	tags := make(map[string]struct{})
+	for _, instance := range c.slide.provider.GetInstances() {
+		for tag := range instance.Tags {
+			tags[tag] = struct{}{}
+		}
+	}
+
+	fields := make([]string, 0, len(tags))
+	for tag := range tags {
+		fields = append(fields, providers.TagFieldPrefix+tag)
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+func (c *ColumnPicker) render() {
+	c.view.Clear()
+
+	for row, col := range c.columns {
+		mark := "[ ]"
+		color := tcell.ColorGrey.TrueColor()
+		if col.enabled {
+			mark = "[x]"
+			color = tcell.ColorWhite.TrueColor()
+		}
+
+		cell := tview.NewTableCell(fmt.Sprintf("%s %s", mark, columnLabel(col.column))).
+			SetTextColor(color).
+			SetExpansion(1)
+		c.view.SetCell(row, 0, cell)
+	}
+}
+
+func (c *ColumnPicker) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	row, _ := c.view.GetSelection()
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		c.slide.closeColumnPicker(nil)
+		return nil
+
+	case tcell.KeyEnter:
+		c.slide.closeColumnPicker(c.selected())
+		return nil
+	}
+
+	switch event.Rune() {
+	case ' ': // toggle column
+		if row < len(c.columns) {
+			c.columns[row].enabled = !c.columns[row].enabled
+			c.render()
+		}
+		return nil
+
+	case 'K': // move column up
+		c.move(row, -1)
+		return nil
+
+	case 'J': // move column down
+		c.move(row, 1)
+		return nil
+	}
+
+	return event
+}
+
+func (c *ColumnPicker) move(row int, direction int) {
+	target := row + direction
+	if row < 0 || target < 0 || target >= len(c.columns) {
+		return
+	}
+
+	c.columns[row], c.columns[target] = c.columns[target], c.columns[row]
+	c.render()
+	c.view.Select(target, 0)
+}
+
+// selected returns the enabled columns in order
+func (c *ColumnPicker) selected() []*config.Column {
+	columns := []*config.Column{}
+	for _, col := range c.columns {
+		if col.enabled {
+			columns = append(columns, col.column)
+		}
+	}
+
+	return columns
+}
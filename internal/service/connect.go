@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/connect"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// target returns the connection target of an instance
+func (s *Slide) target(instance *providers.Instance) (*connect.Target, error) {
+	ip := s.provider.GetInstanceIPByID(instance.ID)
+	if ip == "" && !s.profile.Connect.UsesSSM() {
+		return nil, errors.New("IP address not found")
+	}
+
+	return connect.NewTarget(s.profile, instance, ip, s.provider.GetInstances()), nil
+}
+
+// connectTo connects to the instance, pushing a temporary key with EC2 Instance Connect first if enabled
+func (s *Slide) connectTo(instance *providers.Instance) {
+	s.prepareTargets([]*providers.Instance{instance}, func(targets []*connect.Target) {
+		s.runConnect(targets[0])
+	}, nil)
+}
+
+// prepareTargets resolves the connection targets of the instances, pushing temporary keys with
+// EC2 Instance Connect first if enabled, ready is called from the UI goroutine with the targets
+// that can be connected to, or failed (when not nil) when none can
+func (s *Slide) prepareTargets(instances []*providers.Instance, ready func([]*connect.Target), failed func()) {
+	targets := []*connect.Target{}
+
+	for _, instance := range instances {
+		target, err := s.target(instance)
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to connect to %s: %s", instance.ID, err)
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		notify(failed)
+		return
+	}
+
+	if !s.profile.Connect.InstanceConnect {
+		ready(targets)
+		return
+	}
+
+	pusher, ok := s.provider.(providers.SSHKeyPusher)
+	if !ok {
+		s.service.SetStatusText(s.profile.ID, "EC2 Instance Connect is not supported by provider '%s'", s.profile.Provider)
+		notify(failed)
+		return
+	}
+
+	users := make([]string, len(targets))
+	publicKey := ""
+
+	for i, target := range targets {
+		user, key, err := target.UseInstanceConnect()
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "EC2 Instance Connect failed: %s", err)
+			notify(failed)
+			return
+		}
+
+		users[i] = user
+		publicKey = key
+	}
+
+	s.service.SetStatusText(s.profile.ID, "Pushing ssh key for %s@%s with EC2 Instance Connect", users[0], targets[0].Instance.ID)
+
+	// push the keys in the background, then connect from the UI goroutine
+	go func() {
+		ctx, cancel := context.WithTimeout(s.service.Context(), s.profile.GetTimeout())
+		defer cancel()
+
+		pushed := []*connect.Target{}
+		failures := []string{}
+
+		for i, target := range targets {
+			if err := pusher.PushSSHPublicKey(ctx, target.Instance, users[i], publicKey); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", target.Instance.ID, err))
+				continue
+			}
+
+			pushed = append(pushed, target)
+		}
+
+		s.service.QueueUpdateDraw(func() {
+			if len(failures) > 0 {
+				s.service.SetStatusText(s.profile.ID, "EC2 Instance Connect failed for %s", strings.Join(failures, ", "))
+			}
+
+			if len(pushed) == 0 {
+				notify(failed)
+				return
+			}
+
+			ready(pushed)
+		})
+	}()
+}
+
+// notify calls f when it is not nil
+func notify(f func()) {
+	if f != nil {
+		f()
+	}
+}
+
+// runConnect suspends the UI and runs the connect command of the target
+func (s *Slide) runConnect(target *connect.Target) {
+	args, err := target.Command()
+	if err != nil {
+		s.service.SetStatusText(s.profile.ID, "Unable to connect to %s: %s", target.Instance.ID, err)
+		return
+	}
+
+	s.service.Log(s.profile.ID, "Connecting to instance %s via %s: %s", target.Instance.ID, target.IP, strings.Join(args, " "))
+	s.service.GetApp().Suspend(func() {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+
+		if err := cmd.Run(); err != nil {
+			s.service.SetStatusText(s.profile.ID, "Connection to %s failed: %s", target.Instance.ID, err)
+		}
+	})
+}
+
+// openTmux connects to the marked instances in tmux panes, or one after the other when tmux is not available
+func (s *Slide) openTmux(synchronize bool) {
+	if s.provider == nil {
+		return
+	}
+
+	instances := s.markedInstances()
+	if len(instances) == 0 {
+		s.service.SetStatusText(s.profile.ID, "No instances selected")
+		return
+	}
+
+	s.prepareTargets(instances, func(targets []*connect.Target) {
+		if !connect.TmuxAvailable() {
+			s.service.Log(s.profile.ID, "tmux not found, connecting to %d instances one after the other", len(targets))
+			for _, target := range targets {
+				s.runConnect(target)
+			}
+			return
+		}
+
+		commands := [][]string{}
+		for _, target := range targets {
+			args, err := target.Command()
+			if err != nil {
+				s.service.SetStatusText(s.profile.ID, "Unable to connect to %s: %s", target.Instance.ID, err)
+				continue
+			}
+
+			s.service.Log(s.profile.ID, "Opening tmux pane for instance %s: %s", target.Instance.ID, strings.Join(args, " "))
+			commands = append(commands, args)
+		}
+
+		if len(commands) == 0 {
+			return
+		}
+
+		// tmux doesn't allow dots and colons in session names
+		name := strings.NewReplacer(".", "-", ":", "-").Replace(fmt.Sprintf("gosh-%s-%s", s.profile.ID, time.Now().Format("150405")))
+
+		attach, err := connect.OpenTmux(name, commands, synchronize)
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to open tmux panes: %s", err)
+			return
+		}
+
+		if attach == nil {
+			s.service.SetStatusText(s.profile.ID, "Opened %d instances in tmux window %s", len(commands), name)
+			return
+		}
+
+		s.service.GetApp().Suspend(func() {
+			cmd := exec.Command(attach[0], attach[1:]...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			cmd.Stdin = os.Stdin
+
+			if err := cmd.Run(); err != nil {
+				s.service.SetStatusText(s.profile.ID, "Unable to attach to tmux session %s: %s", name, err)
+			}
+		})
+	}, nil)
+}
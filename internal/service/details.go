@@ -0,0 +1,158 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// DetailsPanel displays everything known about an instance next to the table
+type DetailsPanel struct {
+	slide   *Slide
+	view    *tview.Table
+	visible bool
+}
+
+func NewDetailsPanel(slide *Slide) *DetailsPanel {
+	d := &DetailsPanel{
+		slide: slide,
+	}
+
+	view := tview.NewTable()
+	view.SetSelectable(true, false)
+	view.SetBorder(true)
+	view.SetInputCapture(d.handleInput)
+	d.view = view
+
+	return d
+}
+
+func (d *DetailsPanel) Get() tview.Primitive {
+	return d.view
+}
+
+func (d *DetailsPanel) Visible() bool {
+	return d.visible
+}
+
+func (d *DetailsPanel) Hide() {
+	d.visible = false
+}
+
+// Show displays the details of the instance
+func (d *DetailsPanel) Show(instance *providers.Instance) {
+	d.view.Clear()
+	d.view.SetTitle(fmt.Sprintf(" %s ", tview.Escape(instanceName(instance))))
+
+	row := 0
+	section := ""
+
+	for _, detail := range d.details(instance) {
+		if detail.Section != section {
+			section = detail.Section
+			d.view.SetCell(row, 0, tview.NewTableCell(section).
+				SetSelectable(false).
+				SetAttributes(tcell.AttrBold).
+				SetTextColor(tcell.ColorYellow))
+			row++
+		}
+
+		d.view.SetCell(row, 0, tview.NewTableCell("  "+detail.Key).
+			SetTextColor(tcell.ColorDarkCyan).
+			SetReference(detail.Value))
+		d.view.SetCell(row, 1, tview.NewTableCell(detail.Value).
+			SetExpansion(1).
+			SetReference(detail.Value))
+		row++
+	}
+
+	d.visible = true
+	d.view.Select(1, 0)
+	d.view.ScrollToBeginning()
+}
+
+// details returns the instance details, with the launch time in both local and UTC time
+func (d *DetailsPanel) details(instance *providers.Instance) []*providers.Detail {
+	format := d.slide.service.timeFormat()
+	launched := []*providers.Detail{
+		{Section: "Instance", Key: "Launched (local)", Value: instance.Launched.Local().Format(format)},
+		{Section: "Instance", Key: "Launched (UTC)", Value: instance.Launched.UTC().Format(format)},
+	}
+
+	details := []*providers.Detail{}
+	inserted := instance.Launched.IsZero()
+
+	for _, detail := range instance.Details() {
+		// right after the instance section
+		if !inserted && detail.Section != "Instance" {
+			details = append(details, launched...)
+			inserted = true
+		}
+
+		details = append(details, detail)
+	}
+
+	if !inserted {
+		details = append(details, launched...)
+	}
+
+	return details
+}
+
+func (d *DetailsPanel) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		d.slide.closeDetails()
+		return nil
+	}
+
+	if event.Key() == tcell.KeyEnter {
+		d.copySelected()
+		return nil
+	}
+
+	switch event.Rune() {
+	case 'd', 'i': // close
+		d.slide.closeDetails()
+		return nil
+
+	case 'y': // copy the selected value
+		d.copySelected()
+		return nil
+	}
+
+	return event
+}
+
+// copySelected copies the selected value to the clipboard
+func (d *DetailsPanel) copySelected() {
+	row, _ := d.view.GetSelection()
+	value, ok := d.view.GetCell(row, 1).GetReference().(string)
+	if !ok {
+		return
+	}
+
+	key := strings.TrimSpace(d.view.GetCell(row, 0).Text)
+	d.slide.copy(key, value)
+}
+
+// openDetails displays the details of the selected instance next to the table
+func (s *Slide) openDetails() {
+	instance := s.selectedInstance()
+	if instance == nil {
+		s.service.SetStatusText(s.profile.ID, "No instance selected")
+		return
+	}
+
+	s.details.Show(instance)
+	s.layout()
+	s.service.GetApp().SetFocus(s.details.Get())
+}
+
+func (s *Slide) closeDetails() {
+	s.details.Hide()
+	s.layout()
+	s.focusTable()
+}
@@ -24,9 +24,7 @@ func NewDevLog(service *Service) *DevLog {
 	view.SetBorder(true)
 	view.SetTitle(" Dev Log ")
 	view.SetScrollable(true)
-	view.SetChangedFunc(func() {
-		view.ScrollToEnd()
-	})
+	view.ScrollToEnd() // keeps following new lines, written from any goroutine (the view is redrawn every second)
 	d.view = view
 
 	return d
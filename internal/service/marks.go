@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+const markerColumns = 1 // the first table column displays the marks
+
+// markerCell returns the cell of the marker column for an instance
+func (s *Slide) markerCell(instance *providers.Instance) *tview.TableCell {
+	text := " "
+	if s.isMarked(instance.ID) {
+		text = "*"
+	}
+
+	return tview.NewTableCell(text).
+		SetSelectable(true).
+		SetReference(instance.ID).
+		SetTextColor(tcell.ColorYellow.TrueColor()).
+		SetBackgroundColor(tcell.ColorBlack.TrueColor())
+}
+
+func (s *Slide) isMarked(id string) bool {
+	_, ok := s.marked[id]
+	return ok
+}
+
+// toggleMark marks (or unmarks) the selected instance, and moves to the next row
+func (s *Slide) toggleMark() {
+	row, col := s.table.GetSelection()
+	id := s.instanceIDAt(row)
+	if len(id) == 0 {
+		return
+	}
+
+	if s.isMarked(id) {
+		delete(s.marked, id)
+	} else {
+		s.marked[id] = struct{}{}
+	}
+
+	s.render()
+
+	if row+1 < s.table.GetRowCount() {
+		s.table.Select(row+1, col)
+	}
+
+	s.updatePageInfo()
+}
+
+// toggleMarkAll marks all the displayed instances, or unmarks them if they are all marked already
+func (s *Slide) toggleMarkAll() {
+	ids := []string{}
+	all := true
+
+	for row := 1; row < s.table.GetRowCount(); row++ {
+		if id := s.instanceIDAt(row); len(id) > 0 {
+			ids = append(ids, id)
+			all = all && s.isMarked(id)
+		}
+	}
+
+	for _, id := range ids {
+		if all {
+			delete(s.marked, id)
+		} else {
+			s.marked[id] = struct{}{}
+		}
+	}
+
+	s.render()
+	s.updatePageInfo()
+}
+
+// markedInstances returns the marked instances still known by the provider in the table order,
+// including the ones hidden by the filters, or the selected instance when none are marked
+func (s *Slide) markedInstances() []*providers.Instance {
+	instances := []*providers.Instance{}
+
+	if len(s.marked) == 0 {
+		if instance := s.selectedInstance(); instance != nil {
+			instances = append(instances, instance)
+		}
+
+		return instances
+	}
+
+	all := s.provider.GetInstances()
+	s.sortInstances(all)
+
+	for _, instance := range all {
+		if s.isMarked(instance.ID) {
+			instances = append(instances, instance)
+		}
+	}
+
+	return instances
+}
+
+// hiddenNote tells how many of the instances are hidden by the filters, or returns an empty string
+func (s *Slide) hiddenNote(instances []*providers.Instance) string {
+	hidden := 0
+	for _, instance := range instances {
+		if !s.matches(instance) {
+			hidden++
+		}
+	}
+
+	if hidden == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%d hidden by the filter)", hidden)
+}
+
+// promptCommand asks for a command and runs it on the marked instances
+func (s *Slide) promptCommand() {
+	if s.provider == nil || len(s.markedInstances()) == 0 {
+		s.service.SetStatusText(s.profile.ID, "No instances selected")
+		return
+	}
+
+	if s.results.Running() {
+		s.service.SetStatusText(s.profile.ID, "Commands are still running (ESC in the output to cancel)")
+		return
+	}
+
+	marked := s.markedInstances()
+	label := fmt.Sprintf("Command on %d instances%s", len(marked), s.hiddenNote(marked))
+
+	s.prompt.Ask(label, "", func(command string) {
+		instances := s.markedInstances()
+		if len(instances) == 0 {
+			s.service.SetStatusText(s.profile.ID, "No instances selected")
+			return
+		}
+
+		s.service.SetStatusText(s.profile.ID, "Running '%s' on %d instances%s", command, len(instances), s.hiddenNote(instances))
+		s.results.Run(command, instances)
+	})
+}
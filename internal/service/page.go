@@ -1,19 +1,24 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/connect"
+	"github.com/yogin/gosh/internal/filter"
 	"github.com/yogin/gosh/internal/providers"
 )
 
 type Slider interface {
 	Get(nextSlide func()) (title string, content tview.Primitive)
+	Cancel() bool // Cancel cancels the requests in progress
 }
 
 type Slide struct {
@@ -21,42 +26,210 @@ type Slide struct {
 	profile       *config.Profile
 	provider      providers.Provider
 	table         *tview.Table
+	column        int             // current column (used for sorting)
+	banner        *tview.TextView // error banner, displayed when the last refresh failed
+	message       *tview.TextView // displayed when there are no instances
+	search        *Search
+	picker        *ColumnPicker
+	actions       *ActionMenu
+	details       *DetailsPanel
+	prompt        *Prompt
+	results       *Results
 	view          *tview.Flex
-	refreshTicker *time.Ticker
+	scheduler     *RefreshScheduler
+	transferring  bool                // a file transfer is in progress
+	yanking       bool                // waiting for the second key of a yank shortcut (eg. yi)
+	marked        map[string]struct{} // marked instance IDs
+	filter        string              // search text
+	searchFilter  *filter.Filter      // compiled search text (last valid expression)
+	highlight     bool                // the search highlights the matching rows instead of hiding the others
+	profileFilter *filter.Filter      // filter from the profile configuration
+	lastUpdate    time.Time           // last successful refresh
+	lastError     error               // error of the last refresh, instances are stale when set
+	refreshCtx    context.Context     // context of the refresh in progress
+	cancelRefresh context.CancelFunc
+	refreshQueued bool // a refresh was requested while one was in progress, it starts once done
+	refreshMutex  sync.Mutex
 }
 
 func NewSlide(service *Service, profile *config.Profile) *Slide {
 	s := &Slide{
 		service: service,
 		profile: profile,
+		marked:  make(map[string]struct{}),
 	}
 
 	if p := providers.NewProvider(profile.Provider, profile); p != nil {
 		s.provider = p
 	}
 
+	if f, err := filter.Parse(profile.Filter); err != nil {
+		s.service.SetStatusText(s.profile.ID, "Profile filter error: %s", err)
+	} else {
+		s.profileFilter = f
+	}
+
+	for _, column := range profile.Columns {
+		if !providers.IsValidField(column.Field) {
+			s.service.SetStatusText(s.profile.ID, "Invalid column field '%s'", column.Field)
+		}
+	}
+
+	s.validateTunnels()
+
+	if profile.Sort != nil && !providers.IsValidField(profile.Sort.Field) {
+		s.service.SetStatusText(s.profile.ID, "Invalid sort field '%s'", profile.Sort.Field)
+		profile.Sort = nil
+	}
+
 	table := tview.NewTable()
 	table.SetFixed(1, 0)
 	table.SetSelectable(true, false)
 	table.SetBorderPadding(0, 0, 0, 0)
 	table.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor) // tcell.ColorBlack.TrueColor()
 	table.SetSelectedFunc(s.handleSelectedRow)                      // handles pressing ENTER key on table row
+	table.SetSelectionChangedFunc(s.handleSelectionChanged)
+	table.SetInputCapture(s.handleTableInput)
 	s.table = table
 
+	banner := tview.NewTextView()
+	banner.SetWrap(false)
+	banner.SetTextColor(tcell.ColorWhite)
+	banner.SetBackgroundColor(tcell.ColorDarkRed)
+	s.banner = banner
+
+	s.message = tview.NewTextView()
+
+	s.scheduler = NewRefreshScheduler(s)
+	s.search = NewSearch(s)
+	s.picker = NewColumnPicker(s)
+	s.actions = NewActionMenu(s)
+	s.details = NewDetailsPanel(s)
+	s.prompt = NewPrompt(s)
+	s.results = NewResults(s)
+
 	view := tview.NewFlex()
 	view.SetDirection(tview.FlexRow)
 	view.AddItem(table, 0, 1, true)
 	s.view = view
 
 	s.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// let the search field, side panels, prompt and results handle their own input
+		if s.search.Get().HasFocus() || s.picker.Get().HasFocus() || s.actions.Get().HasFocus() || s.details.Get().HasFocus() || s.prompt.Get().HasFocus() || s.results.Get().HasFocus() {
+			return event
+		}
+
+		if s.yanking {
+			s.handleYank(event)
+			return nil
+		}
+
+		if event.Key() == tcell.KeyEscape {
+			if s.Cancel() {
+				return nil
+			}
+
+			if len(s.filter) > 0 {
+				s.clearFilter()
+				return nil
+			}
+		}
+
 		switch event.Rune() {
+		case ' ': // mark the selected instance
+			s.toggleMark()
+			return nil
+
+		case 'a': // mark all displayed instances
+			s.toggleMarkAll()
+			return nil
+
+		case 'x': // run a command on the marked instances
+			s.promptCommand()
+			return nil
+
+		case 'o': // focus the command results
+			if s.results.Visible() {
+				s.service.GetApp().SetFocus(s.results.Get())
+				return nil
+			}
+
+		case 't': // connect to the marked instances in tmux panes
+			s.openTmux(s.profile.Connect.SynchronizePanes)
+			return nil
+
+		case 'T': // same, with synchronized panes
+			s.openTmux(true)
+			return nil
+
+		case 'u': // upload files to the marked instances
+			s.promptTransfer(connect.Upload)
+			return nil
+
+		case 'D': // download files from the marked instances
+			s.promptTransfer(connect.Download)
+			return nil
+
+		case 'y': // copy a field of the selected instance (yi, yp, yP, yy, ys)
+			s.startYank()
+			return nil
+
+		case 'd', 'i': // details of the selected instance
+			s.openDetails()
+			return nil
+
+		case 'A': // lifecycle actions of the selected instance
+			s.openActionMenu()
+			return nil
+
+		case 'L': // open a tunnel on the selected instance
+			s.promptTunnel()
+			return nil
+
 		case 'r': // refresh
+			s.service.SetStatusText(s.profile.ID, "Refreshing instances (ESC to cancel)")
 			s.update()
 			return nil
 
 		case 'R': // toggle auto-refresh (every minute)
 			s.toggleAutoRefresh()
 			return nil
+
+		case '/': // search
+			s.openSearch(false)
+			return nil
+
+		case '?': // search without hiding the rows that don't match
+			s.openSearch(true)
+			return nil
+
+		case 'c': // column picker
+			s.openColumnPicker()
+			return nil
+
+		case 's': // cycle sort on the current column
+			_, col := s.table.GetSelection()
+			s.cycleSort(col)
+			return nil
+
+		case 'n': // next match
+			if s.highlight && len(s.filter) > 0 {
+				s.selectMatch(1)
+				return nil
+			}
+
+		case 'N': // previous match
+			if s.highlight && len(s.filter) > 0 {
+				s.selectMatch(-1)
+				return nil
+			}
+		}
+
+		if preset := s.tunnelPreset(event.Rune()); preset != nil {
+			if instance := s.selectedInstance(); instance != nil {
+				s.openTunnel(instance, preset)
+			}
+			return nil
 		}
 
 		return event
@@ -66,13 +239,20 @@ func NewSlide(service *Service, profile *config.Profile) *Slide {
 		s.toggleAutoRefresh()
 	}
 
+	// reload the instances of local inventory files when they change
+	if watcher, ok := s.provider.(providers.Watcher); ok {
+		go watcher.Watch(s.service.Context(), func() {
+			s.service.QueueUpdateDraw(s.update)
+		})
+	}
+
 	return s
 }
 
 func (s *Slide) toggleAutoRefresh() {
-	if s.refreshTicker != nil {
+	if s.scheduler.Enabled() {
 		s.service.SetStatusText(s.profile.ID, "Stopping profile auto-refresh")
-		s.refreshTicker.Stop()
+		s.scheduler.Stop()
 		s.profile.Refresh.Enabled = false
 		return
 	}
@@ -82,21 +262,9 @@ func (s *Slide) toggleAutoRefresh() {
 		return
 	}
 
-	s.update() // update immediately before starting the timer
-
-	s.refreshTicker = time.NewTicker(time.Second * time.Duration(s.profile.Refresh.Interval))
-	go s.startAutoRefresh()
-	if s.refreshTicker != nil {
-		s.profile.Refresh.Enabled = true
-		s.service.SetStatusText(s.profile.ID, "Auto-refreshing every %d seconds", s.profile.Refresh.Interval)
-	}
-}
-
-func (s *Slide) startAutoRefresh() {
-	for range s.refreshTicker.C {
-		s.service.Log(s.profile.ID, "Auto-refreshing profile")
-		s.update()
-	}
+	s.scheduler.Start() // update immediately before scheduling the next refreshes
+	s.profile.Refresh.Enabled = true
+	s.service.SetStatusText(s.profile.ID, "Auto-refreshing every %d seconds", s.profile.Refresh.Interval)
 }
 
 func (s *Slide) handleSelectedRow(row int, col int) {
@@ -104,6 +272,10 @@ func (s *Slide) handleSelectedRow(row int, col int) {
 
 	cell := s.table.GetCell(row, col)
 	ref := cell.GetReference()
+	if ref == nil {
+		return
+	}
+
 	instance := s.provider.GetInstanceByID(ref.(string))
 	if instance == nil {
 		s.service.Log(s.profile.ID, "Instance not found for ID %s", ref)
@@ -111,57 +283,394 @@ func (s *Slide) handleSelectedRow(row int, col int) {
 	}
 
 	s.service.Log(s.profile.ID, "Selected instance: %+v", instance)
+	s.connectTo(instance)
+}
+
+// openSearch displays the search field above the table and gives it focus, the search either
+// hides the rows that don't match, or highlights the matching rows
+func (s *Slide) openSearch(highlight bool) {
+	if highlight != s.highlight {
+		s.highlight = highlight
+		s.render()
+		s.updatePageInfo()
+	}
+
+	s.search.Show(highlight)
+	s.search.view.SetText(s.filter)
+	s.layout()
+	s.service.GetApp().SetFocus(s.search.Get())
+}
+
+// setFilter updates the active filter and redraws the table
+func (s *Slide) setFilter(text string) {
+	if text == s.filter {
+		return
+	}
+
+	s.filter = text
+
+	f, err := filter.Parse(text)
+	if err != nil {
+		// keep the last valid search while the expression is being typed
+		s.service.SetStatusText(s.profile.ID, "Search error: %s", err)
+	} else {
+		s.searchFilter = f
+	}
+
+	s.render()
+	s.table.Select(1, 0)
+	s.table.ScrollToBeginning()
+	if s.highlight {
+		s.selectMatch(0)
+	}
+	s.updatePageInfo()
+}
+
+// clearFilter removes the active filter and hides the search field
+func (s *Slide) clearFilter() {
+	s.search.Hide()
+	s.setFilter("")
+	s.highlight = false
+	s.layout()
+	s.focusTable()
+}
+
+func (s *Slide) focusTable() {
+	s.service.GetApp().SetFocus(s.table)
+}
 
-	ip := s.provider.GetInstanceIPByID(instance.ID)
-	if ip == "" {
-		s.service.Log(s.profile.ID, "IP address not found for instance %s", instance.ID)
+// selectMatch moves the selection to the next (or previous) row matching the search, wrapping
+// around, a direction of 0 selects the first match from the selected row
+func (s *Slide) selectMatch(direction int) {
+	rows := s.matchingRows()
+	if len(rows) == 0 {
+		s.service.SetStatusText(s.profile.ID, "No instances matching '%s'", s.filter)
 		return
 	}
 
-	s.service.Log(s.profile.ID, "Connecting to instance %s via %s", instance.ID, ip)
-	s.service.GetApp().Suspend(func() {
-		cmd := exec.Command("ssh", ip)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
+	row, col := s.table.GetSelection()
 
-		if err := cmd.Run(); err != nil {
-			s.service.SetStatusText(s.profile.ID, "SSH to %s failed: %s", ip, err)
+	next := rows[0]
+	switch {
+	case direction < 0:
+		next = rows[len(rows)-1]
+		for idx := len(rows) - 1; idx >= 0; idx-- {
+			if rows[idx] < row {
+				next = rows[idx]
+				break
+			}
 		}
-	})
+
+	default:
+		for _, r := range rows {
+			if r > row || (direction == 0 && r == row) {
+				next = r
+				break
+			}
+		}
+	}
+
+	s.table.Select(next, col)
+}
+
+// matchingRows returns the rows of the instances matching the search
+func (s *Slide) matchingRows() []int {
+	rows := []int{}
+	if s.provider == nil {
+		return rows
+	}
+
+	for row := 1; row < s.table.GetRowCount(); row++ {
+		if instance := s.provider.GetInstanceByID(s.instanceIDAt(row)); instance != nil && s.searchFilter.Match(instance) {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// updatePageInfo displays the stale state and active filters in the status bar
+func (s *Slide) updatePageInfo() {
+	info := []string{}
+	if s.lastError != nil {
+		info = append(info, "STALE")
+	}
+
+	filters := []string{}
+	if !s.profileFilter.Empty() {
+		filters = append(filters, s.profileFilter.String())
+	}
+	if !s.searchFilter.Empty() && !s.highlight {
+		filters = append(filters, s.searchFilter.String())
+	}
+
+	if len(s.marked) > 0 {
+		marked := s.markedInstances()
+		info = append(info, fmt.Sprintf("%d marked%s", len(marked), s.hiddenNote(marked)))
+	}
+
+	if len(filters) > 0 {
+		count := 0
+		if s.provider != nil {
+			count = s.provider.InstancesCount()
+		}
+
+		info = append(info, fmt.Sprintf("Filter: %s (%d/%d)", strings.Join(filters, " + "), s.table.GetRowCount()-1, count))
+	}
+
+	if !s.searchFilter.Empty() && s.highlight {
+		info = append(info, fmt.Sprintf("Search: %s (%d matches)", s.searchFilter.String(), len(s.matchingRows())))
+	}
+
+	s.service.SetPageInfo(s.profile.ID, "%s", strings.Join(info, " | "))
+}
+
+// matches indicates if the instance matches both the profile filter and the search (unless the
+// search only highlights the matching rows)
+func (s *Slide) matches(instance *providers.Instance) bool {
+	return s.profileFilter.Match(instance) && (s.highlight || s.searchFilter.Match(instance))
+}
+
+// openColumnPicker displays the column picker next to the table and gives it focus
+func (s *Slide) openColumnPicker() {
+	s.picker.Show()
+	s.layout()
+	s.service.GetApp().SetFocus(s.picker.Get())
 }
 
+// closeColumnPicker hides the column picker, and applies the columns if any were selected
+func (s *Slide) closeColumnPicker(columns []*config.Column) {
+	s.picker.Hide()
+
+	if len(columns) > 0 {
+		s.profile.Columns = columns
+		s.render()
+		s.service.SetStatusText(s.profile.ID, "Columns updated (press w to save the configuration)")
+	}
+
+	s.layout()
+	s.focusTable()
+}
+
+// layout rebuilds the page with the search field, prompt, column picker and command results
+// (when visible) and the table
+func (s *Slide) layout() {
+	s.view.Clear()
+
+	if s.lastError != nil {
+		s.view.AddItem(s.banner, 1, 0, false)
+	}
+
+	if s.search.Visible() {
+		s.view.AddItem(s.search.Get(), 1, 0, false)
+	}
+
+	if s.prompt.Visible() {
+		s.view.AddItem(s.prompt.Get(), 1, 0, false)
+	}
+
+	s.view.AddItem(s.body(), 0, 1, true)
+
+	if s.results.Visible() {
+		s.view.AddItem(s.results.Get(), 0, 1, false)
+	}
+}
+
+// body returns the main part of the page: the table (with the column picker, action menu or
+// details when visible), or a message when there are no instances
+func (s *Slide) body() tview.Primitive {
+	var panel tview.Primitive
+	width, proportion := 40, 0
+
+	switch {
+	case s.picker.Visible():
+		panel = s.picker.Get()
+	case s.actions.Visible():
+		panel = s.actions.Get()
+	case s.details.Visible():
+		panel = s.details.Get()
+		width, proportion = 0, 1 // half of the page
+	}
+
+	if panel != nil {
+		body := tview.NewFlex()
+		body.SetDirection(tview.FlexColumn)
+		body.AddItem(s.table, 0, 1, false)
+		body.AddItem(panel, width, proportion, true)
+		return body
+	}
+
+	if s.lastError == nil && !s.lastUpdate.IsZero() && s.provider.InstancesCount() == 0 {
+		s.message.SetText(fmt.Sprintf("No instances found in profile '%s'", s.profile.ID))
+		return s.message
+	}
+
+	return s.table
+}
+
+// update fetches the instances in the background, and applies them on the UI goroutine,
+// it must be called from the UI goroutine
 func (s *Slide) update() {
 	if s.provider == nil {
 		s.service.SetStatusText(s.profile.ID, "Invalid provider '%s'", s.profile.Provider)
 		return
 	}
 
-	if err := s.provider.LoadInstances(); err != nil {
-		s.service.SetStatusText(s.profile.ID, "Error fetching instances: %v", err)
+	s.refreshMutex.Lock()
+	if s.cancelRefresh != nil {
+		// a refresh is already in progress, it may have loaded the instances before the request
+		// (eg. after a lifecycle action), another one follows
+		s.refreshQueued = true
+		s.refreshMutex.Unlock()
 		return
 	}
 
-	if s.provider.InstancesCount() == 0 {
-		message := tview.NewTextView()
-		message.SetText(fmt.Sprintf("No instances found in profile '%s'", s.profile.ID))
-		s.view.Clear()
-		s.view.AddItem(message, 0, 1, true)
+	ctx, cancel := context.WithTimeout(s.service.Context(), s.profile.GetTimeout())
+	s.refreshCtx = ctx
+	s.cancelRefresh = cancel
+	s.refreshMutex.Unlock()
+
+	go func() {
+		err := s.provider.LoadInstances(ctx)
+
+		if ctx.Err() != nil {
+			// the provider error is not always explicit about cancellations and timeouts
+			err = ctx.Err()
+		}
+
+		s.refreshMutex.Lock()
+		s.refreshCtx = nil
+		s.cancelRefresh = nil
+		queued := s.refreshQueued
+		s.refreshQueued = false
+		s.refreshMutex.Unlock()
+		cancel()
+
+		if errors.Is(err, context.Canceled) && s.service.Context().Err() != nil {
+			return // quitting
+		}
+
+		s.service.QueueUpdateDraw(func() {
+			s.applyRefresh(err)
+
+			if queued {
+				s.update()
+			}
+		})
+	}()
+}
+
+// Cancel cancels the refresh in progress, returns false if there was none
+func (s *Slide) Cancel() bool {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	if s.cancelRefresh == nil || s.refreshCtx.Err() != nil {
+		return false
+	}
+
+	s.refreshQueued = false
+	s.cancelRefresh()
+	return true
+}
+
+// applyRefresh displays the result of a refresh, must run on the UI goroutine
+func (s *Slide) applyRefresh(err error) {
+	defer s.scheduler.Done(err) // schedule the next refresh once the result is displayed
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.service.SetStatusText(s.profile.ID, "Refresh cancelled")
+		return
+
+	case errors.Is(err, context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %s", s.profile.GetTimeout())
+	}
+
+	if err != nil {
+		// keep the previous instances on screen, marked as stale
+		s.lastError = err
+		s.service.SetStatusText(s.profile.ID, "Error fetching instances: %v", err)
+		s.renderBanner()
+		s.layout()
+		s.updatePageInfo()
 		return
 	}
 
+	s.lastError = nil
+	s.lastUpdate = time.Now()
 	s.service.SetStatusText(s.profile.ID, "Found %d instances", s.provider.InstancesCount())
 
+	s.layout()
+	s.render()
+	s.updatePageInfo()
+}
+
+// renderBanner describes the last error and how old the displayed instances are
+func (s *Slide) renderBanner() {
+	text := fmt.Sprintf("Error fetching instances: %v", s.lastError)
+
+	if s.lastUpdate.IsZero() {
+		text += " (no instances loaded)"
+	} else {
+		age := time.Since(s.lastUpdate).Round(time.Second)
+		text += fmt.Sprintf(" (showing stale instances from %s, %s ago)", s.lastUpdate.Format(s.service.timeFormat()), age)
+	}
+
+	s.banner.SetText(text)
+}
+
+// renderHeaders draws the table headers, with the sort indicator and current column highlighted
+func (s *Slide) renderHeaders() {
+	for c, column := range s.columns() {
+		col := c
+		attributes := tcell.AttrBold
+		if c+markerColumns == s.column {
+			attributes |= tcell.AttrUnderline
+		}
+
+		head := tview.NewTableCell(columnLabel(column) + s.sortIndicator(column.Field)).
+			SetSelectable(false).
+			SetAlign(columnAlign(column)).
+			SetMaxWidth(column.Width).
+			SetAttributes(attributes).
+			SetBackgroundColor(tcell.ColorDimGrey.TrueColor()).
+			SetClickedFunc(func() bool {
+				s.cycleSort(col + markerColumns)
+				return true
+			})
+		s.table.SetCell(0, c+markerColumns, head)
+	}
+
+	s.table.SetCell(0, 0, tview.NewTableCell(" ").
+		SetSelectable(false).
+		SetBackgroundColor(tcell.ColorDimGrey.TrueColor()))
+}
+
+// render draws the provider instances matching the active filter in the table
+func (s *Slide) render() {
+	if s.provider == nil {
+		return
+	}
+
+	// keep the selected instance selected, even if its row changes
+	selectedRow, selectedCol := s.table.GetSelection()
+	selectedID := s.instanceIDAt(selectedRow)
+
 	s.table.Clear()
-	s.view.Clear()
-	s.view.AddItem(s.table, 0, 1, true)
 
-	tagsNames := s.provider.GetTags()
-	tagsCount := len(tagsNames)
+	columns := s.columns()
 	instances := s.provider.GetInstances()
+	s.sortInstances(instances)
 
-	row := 0
+	s.renderHeaders()
+
+	row := 1
 	for _, instance := range instances {
+		if !s.matches(instance) {
+			continue
+		}
+
 		// https://godoc.org/github.com/rivo/tview#hdr-Colors
 		// https://pkg.go.dev/github.com/gdamore/tcell?tab=doc#Color
 		// https://www.w3schools.com/colors/colors_names.asp
@@ -174,57 +683,76 @@ func (s *Slide) update() {
 		case "running":
 			if instance.IsRunningLessThan(15) { // 15 minutes
 				color = tcell.ColorPaleGreen.TrueColor()
-			} else if instance.IsRunningMoreThan(129600) { //  129600 minutes = 90 days (1 quarter)
+			} else if instance.IsRunningMoreThan(129600) { //  129600 minutes = 90 days (1 quarter)
 				color = tcell.ColorOrange.TrueColor()
 			}
 		}
 
-		tags := instance.TagValues(tagsNames)
-		vals := []string{
-			instance.ID,
-			instance.PrivateIP,
-			instance.PublicIP,
-			instance.State,
-			instance.AZ,
-			instance.Type,
-			instance.AMI,
-			instance.RunningDescription(),
-		}
-		values := append(tags, vals...)
-
-		// headers
-		if row == 0 {
-			for c, t := range tagsNames {
-				tag := tview.NewTableCell("Tag:" + t).
-					SetSelectable(false).
-					SetAttributes(tcell.AttrBold).
-					SetBackgroundColor(tcell.ColorDimGrey.TrueColor())
-				s.table.SetCell(0, c, tag)
-			}
-
-			for c, h := range s.provider.Headers() {
-				head := tview.NewTableCell(h).
-					SetSelectable(false).
-					SetAttributes(tcell.AttrBold).
-					SetBackgroundColor(tcell.ColorDimGrey.TrueColor())
-				s.table.SetCell(0, c+tagsCount, head)
-			}
-
-			row++
+		background := tcell.ColorBlack.TrueColor()
+		if s.highlight && !s.searchFilter.Empty() && s.searchFilter.Match(instance) {
+			background = tcell.ColorDarkSlateGray.TrueColor()
 		}
 
 		// instances
-		for col, val := range values {
-			cell := tview.NewTableCell(val).
+		s.table.SetCell(row, 0, s.markerCell(instance))
+		for col, column := range columns {
+			cell := tview.NewTableCell(s.fieldValue(instance, column.Field)).
 				SetSelectable(true).
 				SetReference(instance.ID).
+				SetAlign(columnAlign(column)).
+				SetMaxWidth(column.Width).
 				SetTextColor(color).
-				SetBackgroundColor(tcell.ColorBlack.TrueColor())
-			s.table.SetCell(row, col, cell)
+				SetBackgroundColor(background)
+			s.table.SetCell(row, col+markerColumns, cell)
+		}
+
+		if len(selectedID) > 0 && instance.ID == selectedID {
+			selectedRow = row
 		}
 
 		row++
 	}
+
+	if selectedRow >= row {
+		selectedRow = row - 1
+	}
+	if selectedRow < 1 {
+		selectedRow = 1
+	}
+	s.table.Select(selectedRow, selectedCol)
+}
+
+// fieldValue returns the displayed value of an instance field, the launch time is displayed in the
+// local time zone with the configured time format
+func (s *Slide) fieldValue(instance *providers.Instance, field string) string {
+	if field == "launched" && !instance.Launched.IsZero() {
+		return instance.Launched.Local().Format(s.service.timeFormat())
+	}
+
+	return instance.FieldValue(field)
+}
+
+// instanceIDAt returns the ID of the instance displayed in a row
+func (s *Slide) instanceIDAt(row int) string {
+	if row < 1 || row >= s.table.GetRowCount() {
+		return ""
+	}
+
+	if id, ok := s.table.GetCell(row, 0).GetReference().(string); ok {
+		return id
+	}
+
+	return ""
+}
+
+// selectedInstance returns the instance of the selected row
+func (s *Slide) selectedInstance() *providers.Instance {
+	if s.provider == nil {
+		return nil
+	}
+
+	row, _ := s.table.GetSelection()
+	return s.provider.GetInstanceByID(s.instanceIDAt(row))
 }
 
 func (s *Slide) Get(nextSlide func()) (title string, content tview.Primitive) {
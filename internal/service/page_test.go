@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// fakeProvider returns the hosts set with setHosts, from the next refresh
+type fakeProvider struct {
+	mutex     sync.Mutex
+	hosts     []string // hosts returned by the next refresh
+	instances map[string]*providers.Instance
+	loads     int           // refreshes started
+	gate      chan struct{} // when set, each refresh waits for a value before loading the hosts
+}
+
+// setHosts replaces the hosts returned by the next refresh
+func (p *fakeProvider) setHosts(hosts ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.hosts = append([]string{}, hosts...)
+}
+
+func (p *fakeProvider) Type() providers.ProviderType {
+	return "fake"
+}
+
+func (p *fakeProvider) Headers() []string {
+	return []string{"ID", "Private IP"}
+}
+
+func (p *fakeProvider) Fields() []string {
+	return []string{"id", "private_ip"}
+}
+
+func (p *fakeProvider) LoadInstances(ctx context.Context) error {
+	p.mutex.Lock()
+	p.loads++
+	gate := p.gate
+	p.mutex.Unlock()
+
+	if gate != nil {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.instances = make(map[string]*providers.Instance)
+	for idx, host := range p.hosts {
+		p.instances[host] = &providers.Instance{
+			ID:        host,
+			PrivateIP: fmt.Sprintf("10.0.0.%d", idx+1),
+			State:     "running",
+			Tags:      map[string]string{"name": host},
+		}
+	}
+
+	return nil
+}
+
+func (p *fakeProvider) InstancesCount() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.instances)
+}
+
+func (p *fakeProvider) GetTags() []string {
+	return []string{"name"}
+}
+
+func (p *fakeProvider) GetInstances() []*providers.Instance {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	instances := []*providers.Instance{}
+	for _, i := range p.instances {
+		instances = append(instances, i)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	return instances
+}
+
+func (p *fakeProvider) GetInstanceByID(id string) *providers.Instance {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.instances[id]
+}
+
+func (p *fakeProvider) GetInstanceIPByID(id string) string {
+	if i := p.GetInstanceByID(id); i != nil {
+		return i.PrivateIP
+	}
+
+	return ""
+}
+
+// newTestSlide returns a page of a fake provider profile, displayed on a simulation screen
+func newTestSlide(t *testing.T, hosts ...string) (*Slide, *fakeProvider) {
+	t.Helper()
+
+	provider := &fakeProvider{}
+	provider.setHosts(hosts...)
+
+	profile := &config.Profile{ID: "test"}
+
+	var slide *Slide
+	newTestService(t, func(s *Service) tview.Primitive {
+		slide = NewSlide(s, profile)
+		slide.provider = provider
+		return slide.view
+	})
+
+	return slide, provider
+}
+
+// refresh reloads the instances, and waits until they are displayed
+func refresh(t *testing.T, slide *Slide) {
+	t.Helper()
+
+	// wait for the refreshes in progress, they could have loaded the previous hosts
+	var last time.Time
+	deadline := time.Now().Add(5 * time.Second)
+	for started := false; !started; {
+		syncUI(t, slide.service, func() {
+			slide.refreshMutex.Lock()
+			idle := slide.cancelRefresh == nil
+			slide.refreshMutex.Unlock()
+
+			if idle {
+				last = slide.lastUpdate
+				slide.update()
+				started = true
+			}
+		})
+
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the refreshes in progress")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for {
+		updated := false
+		syncUI(t, slide.service, func() {
+			updated = slide.lastUpdate.After(last)
+		})
+
+		if updated {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// selectInstance selects the row of the instance
+func selectInstance(t *testing.T, slide *Slide, id string) {
+	t.Helper()
+
+	syncUI(t, slide.service, func() {
+		for row := 1; row < slide.table.GetRowCount(); row++ {
+			if slide.instanceIDAt(row) == id {
+				slide.table.Select(row, 0)
+				return
+			}
+		}
+
+		t.Errorf("instance %s not displayed", id)
+	})
+}
+
+// selection returns the selected row and instance ID
+func selection(t *testing.T, slide *Slide) (int, string) {
+	t.Helper()
+
+	var row int
+	var id string
+	syncUI(t, slide.service, func() {
+		row, _ = slide.table.GetSelection()
+		id = slide.instanceIDAt(row)
+	})
+
+	return row, id
+}
+
+func TestSlideRefreshKeepsSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   []string
+		selected string
+		after    []string
+		wantRow  int
+		wantID   string
+	}{
+		{"unchanged", []string{"web-1", "web-2", "web-3"}, "web-2", []string{"web-1", "web-2", "web-3"}, 2, "web-2"},
+		{"instance added before", []string{"web-1", "web-2", "web-3"}, "web-2", []string{"web-0", "web-1", "web-2", "web-3"}, 3, "web-2"},
+		{"instance removed before", []string{"web-1", "web-2", "web-3"}, "web-3", []string{"web-2", "web-3"}, 2, "web-3"},
+		{"selected instance removed", []string{"web-1", "web-2", "web-3"}, "web-2", []string{"web-1", "web-3"}, 2, "web-3"},
+		{"last instance removed", []string{"web-1", "web-2", "web-3"}, "web-3", []string{"web-1", "web-2"}, 2, "web-2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slide, provider := newTestSlide(t, test.before...)
+			refresh(t, slide)
+
+			selectInstance(t, slide, test.selected)
+			provider.setHosts(test.after...)
+			refresh(t, slide)
+
+			row, id := selection(t, slide)
+			if row != test.wantRow || id != test.wantID {
+				t.Errorf("selection = row %d (%s), want row %d (%s)", row, id, test.wantRow, test.wantID)
+			}
+		})
+	}
+}
+
+func TestSlideConcurrentRefreshes(t *testing.T) {
+	slide, provider := newTestSlide(t, "web-1", "web-2", "web-3")
+	refresh(t, slide)
+	selectInstance(t, slide, "web-3")
+
+	// refreshes requested from other goroutines (eg. the scheduler) while the instances change, the
+	// table is only modified on the UI goroutine
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			slide.service.QueueUpdateDraw(slide.update)
+			slide.service.QueueUpdateDraw(slide.render)
+		}(n)
+	}
+
+	hosts := []string{"web-1", "web-2", "web-3"}
+	for n := 0; n < 5; n++ {
+		hosts = append([]string{fmt.Sprintf("app-%d", n)}, hosts...)
+		provider.setHosts(hosts...)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	wg.Wait()
+	refresh(t, slide)
+
+	row, id := selection(t, slide)
+	if id != "web-3" || row != len(hosts) {
+		t.Errorf("selection = row %d (%s), want row %d (web-3)", row, id, len(hosts))
+	}
+}
+
+func TestSlideSearch(t *testing.T) {
+	slide, _ := newTestSlide(t, "db-1", "web-1", "db-2", "web-2")
+	refresh(t, slide)
+
+	rows := func() []string {
+		ids := []string{}
+		syncUI(t, slide.service, func() {
+			for row := 1; row < slide.table.GetRowCount(); row++ {
+				ids = append(ids, slide.instanceIDAt(row))
+			}
+		})
+		return ids
+	}
+
+	// the search hides the rows that don't match
+	syncUI(t, slide.service, func() {
+		slide.openSearch(false)
+		slide.setFilter("web")
+	})
+	if got := fmt.Sprint(rows()); got != "[web-1 web-2]" {
+		t.Errorf("rows = %s, want [web-1 web-2]", got)
+	}
+
+	// the search highlights the matching rows, n/N move between them
+	syncUI(t, slide.service, func() {
+		slide.clearFilter()
+		slide.openSearch(true)
+		slide.setFilter("web")
+	})
+	if got := fmt.Sprint(rows()); got != "[db-1 db-2 web-1 web-2]" {
+		t.Errorf("rows = %s, want all the instances", got)
+	}
+
+	steps := []struct {
+		direction int
+		want      string
+	}{
+		{0, "web-1"},
+		{1, "web-2"},
+		{1, "web-1"}, // wraps around
+		{-1, "web-2"},
+		{-1, "web-1"},
+	}
+
+	for _, step := range steps {
+		syncUI(t, slide.service, func() {
+			if step.direction != 0 {
+				slide.selectMatch(step.direction)
+			}
+		})
+
+		if _, id := selection(t, slide); id != step.want {
+			t.Errorf("selectMatch(%d) selected %s, want %s", step.direction, id, step.want)
+		}
+	}
+
+	// from a row that doesn't match
+	selectInstance(t, slide, "db-2")
+	syncUI(t, slide.service, func() {
+		slide.selectMatch(-1)
+	})
+	if _, id := selection(t, slide); id != "web-2" {
+		t.Errorf("selectMatch(-1) selected %s, want web-2 (wrapping around)", id)
+	}
+}
+
+func TestSlideFieldValue(t *testing.T) {
+	slide, _ := newTestSlide(t)
+	syncUI(t, slide.service, func() {
+		slide.service.config.TimeFormat = "02/01/2006 15:04"
+	})
+
+	launched := time.Date(2024, 3, 9, 17, 45, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		instance *providers.Instance
+		field    string
+		want     string
+	}{
+		{"launched", &providers.Instance{Launched: launched}, "launched", "09/03/2024 17:45"},
+		{"launched in utc", &providers.Instance{Launched: launched.UTC()}, "launched", "09/03/2024 17:45"},
+		{"not launched", &providers.Instance{}, "launched", ""},
+		{"other field", &providers.Instance{ID: "i-1", Launched: launched}, "id", "i-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := slide.fieldValue(test.instance, test.field); got != test.want {
+				t.Errorf("fieldValue(%s) = %q, want %q", test.field, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSlideMarkedInstancesHiddenByFilter(t *testing.T) {
+	slide, _ := newTestSlide(t, "db-1", "web-1", "web-2")
+	refresh(t, slide)
+
+	var ids []string
+	var note string
+	syncUI(t, slide.service, func() {
+		slide.toggleMarkAll()
+		slide.openSearch(false)
+		slide.setFilter("web")
+
+		for _, instance := range slide.markedInstances() {
+			ids = append(ids, instance.ID)
+		}
+		note = slide.hiddenNote(slide.markedInstances())
+	})
+
+	// the marks hidden by the filter are kept, and reported
+	if got := fmt.Sprint(ids); got != "[db-1 web-1 web-2]" {
+		t.Errorf("markedInstances() = %s, want [db-1 web-1 web-2]", got)
+	}
+	if want := " (1 hidden by the filter)"; note != want {
+		t.Errorf("hiddenNote() = %q, want %q", note, want)
+	}
+}
+
+func TestSlideRefreshQueued(t *testing.T) {
+	slide, provider := newTestSlide(t, "web-1")
+	refresh(t, slide)
+
+	gate := make(chan struct{})
+	provider.mutex.Lock()
+	provider.gate = gate
+	provider.loads = 0
+	provider.mutex.Unlock()
+
+	// the refreshes requested while one is in progress are merged into a single follow-up refresh
+	syncUI(t, slide.service, slide.update)
+	provider.setHosts("web-1", "web-2")
+	syncUI(t, slide.service, slide.update)
+	syncUI(t, slide.service, slide.update)
+
+	gate <- struct{}{} // first refresh, it may have loaded web-1 only
+	gate <- struct{}{} // follow-up refresh
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ids := []string{}
+		syncUI(t, slide.service, func() {
+			for row := 1; row < slide.table.GetRowCount(); row++ {
+				ids = append(ids, slide.instanceIDAt(row))
+			}
+		})
+
+		if fmt.Sprint(ids) == "[web-1 web-2]" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rows = %v, want [web-1 web-2] after the follow-up refresh", ids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	provider.mutex.Lock()
+	loads := provider.loads
+	provider.mutex.Unlock()
+
+	if loads != 2 {
+		t.Errorf("%d refreshes, want 2", loads)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Prompt asks the user for a value in an input field displayed above the table
+type Prompt struct {
+	slide   *Slide
+	view    *tview.InputField
+	visible bool
+	done    func(text string)
+	cancel  func()
+}
+
+func NewPrompt(slide *Slide) *Prompt {
+	p := &Prompt{
+		slide: slide,
+	}
+
+	view := tview.NewInputField()
+	view.SetFieldBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+	view.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEscape:
+			cancel := p.cancel
+			p.close()
+			notify(cancel)
+		case tcell.KeyEnter:
+			done, cancel := p.done, p.cancel
+			text := p.view.GetText()
+			p.close()
+
+			if len(text) == 0 {
+				notify(cancel)
+			} else if done != nil {
+				done(text)
+			}
+		}
+	})
+	p.view = view
+
+	return p
+}
+
+func (p *Prompt) Get() tview.Primitive {
+	return p.view
+}
+
+func (p *Prompt) Visible() bool {
+	return p.visible
+}
+
+// Ask displays the prompt with a label and an initial value, done is called with the value entered
+// (when not empty) after pressing ENTER, ESC closes the prompt without calling done
+func (p *Prompt) Ask(label string, text string, done func(text string)) {
+	p.AskOrCancel(label, text, done, nil)
+}
+
+// AskOrCancel displays the prompt like Ask, cancel (when not nil) is called instead of done when the
+// prompt is closed with ESC or without a value
+func (p *Prompt) AskOrCancel(label string, text string, done func(text string), cancel func()) {
+	p.visible = true
+	p.done = done
+	p.cancel = cancel
+	p.view.SetLabel(label + ": ")
+	p.view.SetText(text)
+
+	p.slide.layout()
+	p.slide.service.GetApp().SetFocus(p.view)
+}
+
+func (p *Prompt) close() {
+	p.visible = false
+	p.done = nil
+	p.cancel = nil
+
+	p.slide.layout()
+	p.slide.focusTable()
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/broadcast"
+	"github.com/yogin/gosh/internal/providers"
+	"github.com/yogin/gosh/internal/utils"
+)
+
+// Results displays the outcome of commands run on multiple instances
+type Results struct {
+	slide   *Slide
+	view    *tview.TextView
+	visible bool
+
+	title   string
+	output  strings.Builder   // plain text output grouped by instance, saved to files
+	partial map[string]string // output of the instances not ending with a newline yet, by instance ID and stream
+	total   int
+	done    int
+	failed  int
+	running bool
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+}
+
+func NewResults(slide *Slide) *Results {
+	r := &Results{
+		slide: slide,
+	}
+
+	view := tview.NewTextView()
+	view.SetDynamicColors(true)
+	view.SetScrollable(true)
+	view.SetWrap(true)
+	view.SetBorder(true)
+	view.SetInputCapture(r.handleInput)
+	r.view = view
+
+	return r
+}
+
+func (r *Results) Get() tview.Primitive {
+	return r.view
+}
+
+func (r *Results) Visible() bool {
+	return r.visible
+}
+
+// Running indicates if commands are still running
+func (r *Results) Running() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.running
+}
+
+func (r *Results) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if r.Running() {
+			r.cancel()
+			r.slide.service.SetStatusText(r.slide.profile.ID, "Cancelling commands")
+			return nil
+		}
+
+		r.visible = false
+		r.slide.layout()
+		r.slide.focusTable()
+		return nil
+	}
+
+	switch event.Rune() {
+	case 'o': // back to the instances
+		r.slide.focusTable()
+		return nil
+
+	case 'S': // save output
+		name := fmt.Sprintf("gosh-%s-%s.log", r.slide.profile.ID, time.Now().Format("20060102-150405"))
+		r.slide.prompt.Ask("Save output to", name, r.save)
+		return nil
+	}
+
+	return event
+}
+
+// Run runs the command on the instances through ssh, results are displayed as soon as they are available
+func (r *Results) Run(command string, instances []*providers.Instance) {
+	ctx, cancel := context.WithCancel(r.slide.service.Context())
+
+	r.mutex.Lock()
+	r.title = fmt.Sprintf("$ %s", command)
+	r.output.Reset()
+	r.partial = make(map[string]string)
+	r.total = len(instances)
+	r.done = 0
+	r.failed = 0
+	r.running = true
+	r.cancel = cancel
+	r.mutex.Unlock()
+
+	r.view.Clear()
+	r.visible = true
+	r.updateTitle()
+	r.slide.layout()
+	r.slide.service.GetApp().SetFocus(r.view)
+
+	jobs := []*broadcast.Job{}
+	names := make(map[string]string)
+
+	for _, instance := range instances {
+		names[instance.ID] = instanceName(instance)
+
+		args, err := r.command(instance, command)
+		if err != nil {
+			r.add(&broadcast.Result{Job: &broadcast.Job{ID: instance.ID}, ExitCode: -1, Err: err}, names[instance.ID])
+			continue
+		}
+
+		jobs = append(jobs, &broadcast.Job{ID: instance.ID, Args: args})
+	}
+
+	go func() {
+		output := func(job *broadcast.Job, data []byte, stderr bool) {
+			r.slide.service.QueueUpdateDraw(func() {
+				r.stream(job.ID, names[job.ID], string(data), stderr)
+			})
+		}
+
+		// the output of a job is queued before its result
+		broadcast.Run(ctx, jobs, r.slide.profile.Connect.Parallel, output, func(result *broadcast.Result) {
+			r.slide.service.QueueUpdateDraw(func() {
+				r.add(result, names[result.Job.ID])
+			})
+		})
+		cancel()
+
+		// queued after the results, the updates run in order
+		r.slide.service.QueueUpdateDraw(r.finish)
+	}()
+}
+
+// command returns the ssh command running the shell command on the instance
+func (r *Results) command(instance *providers.Instance, command string) ([]string, error) {
+	target, err := r.slide.target(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return target.ExecCommand(command)
+}
+
+// stream displays the complete lines of output of a command as they are written, prefixed with the
+// instance name, must be called from the UI goroutine
+func (r *Results) stream(id string, name string, data string, stderr bool) {
+	key := streamKey(id, stderr)
+	data = r.partial[key] + data
+
+	idx := strings.LastIndexByte(data, '\n')
+	r.partial[key] = data[idx+1:]
+
+	if idx >= 0 {
+		r.writeLines(name, data[:idx], stderr)
+	}
+}
+
+// flush displays the output of a command not ending with a newline, must be called from the UI goroutine
+func (r *Results) flush(id string, name string) {
+	for _, stderr := range []bool{false, true} {
+		key := streamKey(id, stderr)
+		if len(r.partial[key]) > 0 {
+			r.writeLines(name, r.partial[key], stderr)
+		}
+		delete(r.partial, key)
+	}
+}
+
+// writeLines displays lines of output prefixed with the instance name, stderr in yellow
+func (r *Results) writeLines(name string, lines string, stderr bool) {
+	color := "-"
+	if stderr {
+		color = "yellow"
+	}
+
+	for _, line := range strings.Split(lines, "\n") {
+		fmt.Fprintf(r.view, "[grey]%s |[-] [%s]%s[-]\n", tview.Escape(name), color, tview.Escape(line))
+	}
+}
+
+// streamKey identifies an output stream of an instance
+func streamKey(id string, stderr bool) string {
+	if stderr {
+		return id + "/stderr"
+	}
+
+	return id + "/stdout"
+}
+
+// add displays the result of a command, must be called from the UI goroutine
+func (r *Results) add(result *broadcast.Result, name string) {
+	r.flush(result.Job.ID, name)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done++
+	status := fmt.Sprintf("exit %d", result.ExitCode)
+	color := "green"
+
+	if result.Err != nil {
+		status = fmt.Sprintf("error: %s", result.Err)
+		color = "red"
+		r.failed++
+	} else if result.ExitCode != 0 {
+		color = "red"
+		r.failed++
+	}
+
+	header := fmt.Sprintf("==> %s (%s) %s in %s", name, result.Job.ID, status, result.Duration.Round(time.Millisecond))
+	fmt.Fprintf(r.view, "[%s]%s[-]\n", color, tview.Escape(header))
+
+	// the saved output is grouped by instance
+	fmt.Fprintf(&r.output, "%s\n", header)
+	r.output.Write(result.Stdout)
+	r.output.Write(result.Stderr)
+	r.output.WriteString("\n")
+
+	r.updateTitleLocked()
+}
+
+func (r *Results) finish() {
+	r.mutex.Lock()
+	r.running = false
+	failed, total := r.failed, r.total
+	r.updateTitleLocked()
+	r.mutex.Unlock()
+
+	r.slide.service.SetStatusText(r.slide.profile.ID, "Command completed on %d instances (%d failed), press S to save the output", total, failed)
+}
+
+func (r *Results) updateTitle() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.updateTitleLocked()
+}
+
+func (r *Results) updateTitleLocked() {
+	state := "done"
+	if r.running {
+		state = "running, ESC to cancel"
+	}
+
+	r.view.SetTitle(fmt.Sprintf(" %s [%d/%d, %d failed, %s] ", tview.Escape(r.title), r.done, r.total, r.failed, state))
+}
+
+// save writes the plain text output to a file
+func (r *Results) save(path string) {
+	r.mutex.Lock()
+	data := r.output.String()
+	r.mutex.Unlock()
+
+	path = utils.ExpandHome(path)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		r.slide.service.SetStatusText(r.slide.profile.ID, "Unable to save output: %s", err)
+		return
+	}
+
+	r.slide.service.SetStatusText(r.slide.profile.ID, "Output saved to %s", path)
+}
+
+// instanceName returns the name tag of the instance, or its ID
+func instanceName(instance *providers.Instance) string {
+	if name := instance.Tags["name"]; len(name) > 0 {
+		return name
+	}
+
+	return instance.ID
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/yogin/gosh/internal/providers"
+)
+
+const (
+	retryBaseDelay = 2 * time.Second // retryBaseDelay is the delay before the first retry
+	retryMaxDelay  = 5 * time.Minute // retryMaxDelay is the maximum delay between retries
+	maxStretch     = 8               // maxStretch is how many times the interval can be stretched when throttled
+	maxFailures    = 5               // maxFailures is the number of consecutive failures before pausing auto-refresh
+)
+
+// RefreshScheduler triggers the auto-refreshes of a page, retries transient failures with
+// jittered exponential backoff, stretches the interval when throttled and pauses after
+// too many consecutive transient failures or any other failure
+type RefreshScheduler struct {
+	slide    *Slide
+	timer    *time.Timer
+	enabled  bool
+	failures int           // consecutive failures
+	interval time.Duration // current interval, stretched when throttled
+	mutex    sync.Mutex
+}
+
+func NewRefreshScheduler(slide *Slide) *RefreshScheduler {
+	return &RefreshScheduler{
+		slide: slide,
+	}
+}
+
+// baseInterval returns the configured refresh interval
+func (r *RefreshScheduler) baseInterval() time.Duration {
+	return time.Duration(r.slide.profile.Refresh.Interval) * time.Second
+}
+
+// Enabled indicates if auto-refresh is running
+func (r *RefreshScheduler) Enabled() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.enabled
+}
+
+// Start refreshes immediately and schedules the next refreshes
+func (r *RefreshScheduler) Start() {
+	r.mutex.Lock()
+	r.enabled = true
+	r.failures = 0
+	r.interval = r.baseInterval()
+	r.stopTimer()
+	r.mutex.Unlock()
+
+	r.slide.update()
+}
+
+// Stop stops the auto-refresh
+func (r *RefreshScheduler) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.enabled = false
+	r.stopTimer()
+}
+
+// Done schedules the next refresh according to the result of the last one
+func (r *RefreshScheduler) Done(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.enabled {
+		return
+	}
+
+	base := r.baseInterval()
+	delay := r.interval
+
+	switch {
+	case err == nil:
+		r.failures = 0
+
+		// slowly go back to the configured interval after being throttled
+		if r.interval > base {
+			r.interval /= 2
+			if r.interval < base {
+				r.interval = base
+			}
+		}
+		delay = r.interval
+
+	case errors.Is(err, context.Canceled):
+		// cancelled by the user, not a failure
+
+	case providers.IsThrottlingError(err):
+		// the API works, it only needs to be called less often
+		if r.interval < base*maxStretch {
+			r.interval *= 2
+		}
+		delay = jitter(r.interval)
+
+		r.slide.service.SetStatusText(r.slide.profile.ID, "API throttled, next refresh in %s", delay.Round(time.Second))
+
+	case providers.IsTransientError(err):
+		r.failures++
+		delay = jitter(backoff(r.failures))
+
+		if !r.pauseAfterFailures() {
+			r.slide.service.SetStatusText(r.slide.profile.ID, "Refresh failed (%d/%d), retrying in %s", r.failures, maxFailures, delay.Round(time.Second))
+		}
+
+	default:
+		// retrying won't help (eg. invalid credentials or permissions)
+		r.failures++
+		r.enabled = false
+		r.stopTimer()
+		r.slide.service.SetStatusText(r.slide.profile.ID, "Refresh failed: %v, auto-refresh paused (press R to resume)", err)
+	}
+
+	if !r.enabled {
+		return
+	}
+
+	r.stopTimer()
+	r.timer = time.AfterFunc(delay, r.tick)
+}
+
+// pauseAfterFailures disables auto-refresh after too many consecutive failures, must be called with the mutex locked
+func (r *RefreshScheduler) pauseAfterFailures() bool {
+	if r.failures < maxFailures {
+		return false
+	}
+
+	r.enabled = false
+	r.stopTimer()
+	r.slide.service.SetStatusText(r.slide.profile.ID, "Auto-refresh paused after %d consecutive failures (press R to resume)", r.failures)
+	return true
+}
+
+func (r *RefreshScheduler) tick() {
+	if !r.Enabled() {
+		return
+	}
+
+	r.slide.service.Log(r.slide.profile.ID, "Auto-refreshing profile")
+	r.slide.service.QueueUpdateDraw(r.slide.update)
+}
+
+// stopTimer must be called with the mutex locked
+func (r *RefreshScheduler) stopTimer() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// backoff returns the exponential delay before retrying after a number of consecutive failures
+func backoff(failures int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < failures && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	return delay
+}
+
+// jitter returns a random duration between half and the full delay
+func jitter(delay time.Duration) time.Duration {
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
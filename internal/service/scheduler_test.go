@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+func TestRefreshSchedulerDone(t *testing.T) {
+	throttled := &providers.HTTPError{StatusCode: http.StatusTooManyRequests}
+	transient := &providers.HTTPError{StatusCode: http.StatusServiceUnavailable}
+	permanent := &providers.HTTPError{StatusCode: http.StatusForbidden}
+	awsTransient := awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("connection reset"))
+	awsPermanent := awserr.New("UnauthorizedOperation", "not authorized", nil)
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantEnabled  bool
+		wantFailures int
+	}{
+		{"success", []error{transient, nil}, true, 0},
+		{"cancelled", []error{transient, context.Canceled}, true, 1},
+		{"transient", []error{transient, transient}, true, 2},
+		{"too many transient failures", []error{transient, transient, transient, transient, transient}, false, 5},
+		{"throttled", []error{throttled, throttled, throttled, throttled, throttled, throttled}, true, 0},
+		{"throttled between transient failures", []error{transient, throttled, transient}, true, 2},
+		{"permanent", []error{permanent}, false, 1},
+		{"permanent wrapped", []error{fmt.Errorf("listing instances: %w", permanent)}, false, 1},
+		{"unknown", []error{errors.New("invalid credentials")}, false, 1},
+		{"aws transient", []error{fmt.Errorf("listing instances: %w", awsTransient)}, true, 1},
+		{"aws permanent", []error{awsPermanent}, false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slide, _ := newTestSlide(t)
+			slide.profile.Refresh.Interval = 60
+
+			r := slide.scheduler
+			r.enabled = true
+			r.interval = r.baseInterval()
+			defer r.Stop()
+
+			for _, err := range test.errs {
+				r.Done(err)
+			}
+
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+
+			if r.enabled != test.wantEnabled || r.failures != test.wantFailures {
+				t.Errorf("enabled = %t with %d failures, want %t with %d failures", r.enabled, r.failures, test.wantEnabled, test.wantFailures)
+			}
+			if !test.wantEnabled && r.timer != nil {
+				t.Error("a refresh is still scheduled")
+			}
+		})
+	}
+}
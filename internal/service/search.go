@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+type Search struct {
+	slide   *Slide
+	view    *tview.InputField
+	visible bool
+}
+
+func NewSearch(slide *Slide) *Search {
+	s := &Search{
+		slide: slide,
+	}
+
+	view := tview.NewInputField()
+	view.SetLabel("/")
+	view.SetFieldBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+	view.SetChangedFunc(func(text string) {
+		s.slide.setFilter(text)
+	})
+	view.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEscape:
+			s.slide.clearFilter()
+		case tcell.KeyEnter, tcell.KeyTab:
+			s.slide.focusTable()
+		}
+	})
+	s.view = view
+
+	return s
+}
+
+func (s *Search) Get() tview.Primitive {
+	return s.view
+}
+
+func (s *Search) Visible() bool {
+	return s.visible
+}
+
+// Show displays the search field, with the key opening it as label
+func (s *Search) Show(highlight bool) {
+	s.visible = true
+
+	if highlight {
+		s.view.SetLabel("?")
+	} else {
+		s.view.SetLabel("/")
+	}
+}
+
+// Hide hides the search field and resets its content
+func (s *Search) Hide() {
+	s.visible = false
+	s.view.SetText("")
+}
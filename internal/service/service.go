@@ -1,13 +1,17 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/tunnel"
 )
 
 var (
@@ -15,10 +19,18 @@ var (
 )
 
 type Service struct {
-	config *config.Config
-	app    *tview.Application
-	status *Status
-	devlog *DevLog
+	config  *config.Config
+	app     *tview.Application
+	status  *Status
+	devlog  *DevLog
+	tunnels *tunnel.Manager // tunnels opened from all the profiles
+	screen  tcell.Screen    // screen of the last draw, only accessed from the UI goroutine
+	ctx     context.Context // cancelled when quitting, parent of all provider requests
+	cancel  context.CancelFunc
+
+	updates      []func() // UI updates waiting to be passed to the application, in order
+	updatesMutex *sync.Mutex
+	updatesReady chan struct{} // signals that updates are waiting
 }
 
 func NewService(cfg *config.Config) *Service {
@@ -26,15 +38,33 @@ func NewService(cfg *config.Config) *Service {
 		return service
 	}
 
-	service = &Service{
-		config: cfg,
-	}
+	service = newService(cfg)
 
 	return service
 }
 
+func newService(cfg *config.Config) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Service{
+		config:       cfg,
+		ctx:          ctx,
+		cancel:       cancel,
+		updatesMutex: &sync.Mutex{},
+		updatesReady: make(chan struct{}, 1),
+	}
+}
+
 func (s *Service) Run() error {
+	if err := validateTunnelKeys(s.config); err != nil {
+		return err
+	}
+
 	s.app = tview.NewApplication()
+	s.app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		s.screen = screen
+	})
+	go s.runUpdates()
 
 	// devlog must be started before any other component so it can receive log messages
 	if s.config.Developer {
@@ -54,6 +84,12 @@ func (s *Service) Run() error {
 		slides = append(slides, NewSlide(s, profile))
 	}
 
+	tunnels := NewTunnelsPage(s)
+	s.tunnels = tunnel.NewManager(func() {
+		s.QueueUpdateDraw(tunnels.render)
+	})
+	slides = append(slides, tunnels)
+
 	pages := tview.NewPages()
 
 	menu := tview.NewTextView()
@@ -62,6 +98,19 @@ func (s *Service) Run() error {
 	menu.SetWrap(false)
 	menu.SetHighlightedFunc(func(added, removed, remaining []string) {
 		pages.SwitchToPage(added[0])
+
+		// cancel the requests in progress of the page we're leaving
+		for _, page := range removed {
+			if idx, err := strconv.Atoi(page); err == nil && idx < len(slides) {
+				slides[idx].Cancel()
+			}
+		}
+
+		if idx, err := strconv.Atoi(added[0]); err == nil && idx < len(s.config.Profiles) {
+			s.status.SetActivePage(s.config.Profiles[idx].ID)
+		} else {
+			s.status.SetActivePage(TunnelsPageID)
+		}
 	})
 
 	previousSlide := func() {
@@ -102,6 +151,11 @@ func (s *Service) Run() error {
 	s.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		s.Log("app", "Key pressed Name=%s, Key=%d, Rune=%d", event.Name(), event.Key(), event.Rune())
 
+		// let input fields (eg. search) receive all the keys typed
+		if _, ok := s.app.GetFocus().(*tview.InputField); ok {
+			return event
+		}
+
 		switch event.Key() {
 		case tcell.KeyCtrlN, tcell.KeyTab:
 			nextSlide()
@@ -114,6 +168,7 @@ func (s *Service) Run() error {
 		default:
 			switch event.Rune() {
 			case 'q', 'Q':
+				s.cancel()
 				s.app.Stop()
 				return nil
 
@@ -151,6 +206,9 @@ func (s *Service) Run() error {
 
 	s.app.SetRoot(layout, true)
 	s.app.EnableMouse(true)
+	defer s.cancel()           // cancel requests in progress (eg. when quitting with ctrl-c)
+	defer s.tunnels.CloseAll() // tunnels don't outlive gosh
+
 	return s.app.Run()
 }
 
@@ -158,11 +216,76 @@ func (s *Service) GetConfig() *config.Config {
 	return s.config
 }
 
+// QueueUpdateDraw runs f on the UI goroutine and redraws the screen, the updates run in the order
+// they were queued, it does not wait for f to be executed so it is safe to call from any goroutine
+// (including the UI goroutine)
+func (s *Service) QueueUpdateDraw(f func()) {
+	if s.ctx.Err() != nil {
+		return // the application is stopping, updates won't be processed anymore
+	}
+
+	s.updatesMutex.Lock()
+	s.updates = append(s.updates, f)
+	s.updatesMutex.Unlock()
+
+	select {
+	case s.updatesReady <- struct{}{}:
+	default:
+		// already signaled, the update will be passed with the ones waiting
+	}
+}
+
+// runUpdates passes the queued updates to the application in order, until the service context
+// is cancelled
+func (s *Service) runUpdates() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.updatesReady:
+		}
+
+		s.updatesMutex.Lock()
+		updates := s.updates
+		s.updates = nil
+		s.updatesMutex.Unlock()
+
+		if len(updates) == 0 {
+			continue // passed with the previous signal
+		}
+
+		s.app.QueueUpdateDraw(func() {
+			for _, f := range updates {
+				f()
+			}
+		})
+	}
+}
+
+// Context returns the service context, cancelled when quitting
+func (s *Service) Context() context.Context {
+	return s.ctx
+}
+
+// terminal returns the terminal of the screen, written to between draws, or nil when it is not
+// available (eg. not drawn yet), must be called from the UI goroutine
+func (s *Service) terminal() io.Writer {
+	if s.screen == nil {
+		return nil
+	}
+
+	if tty, ok := s.screen.Tty(); ok {
+		return tty
+	}
+
+	return nil
+}
+
 func (s *Service) GetApp() *tview.Application {
 	return s.app
 }
 
-func (s Service) SetStatusText(prefix string, format string, a ...interface{}) {
+func (s *Service) SetStatusText(prefix string, format string, a ...interface{}) {
 	if s.status == nil {
 		return
 	}
@@ -171,7 +294,16 @@ func (s Service) SetStatusText(prefix string, format string, a ...interface{}) {
 	s.Log(prefix, format, a...)
 }
 
-func (s Service) Log(prefix string, format string, a ...interface{}) {
+// SetPageInfo sets a persistent text in the status bar, displayed while the page is active
+func (s *Service) SetPageInfo(page string, format string, a ...interface{}) {
+	if s.status == nil {
+		return
+	}
+
+	s.status.SetPageInfo(page, format, a...)
+}
+
+func (s *Service) Log(prefix string, format string, a ...interface{}) {
 	if s.devlog == nil {
 		return
 	}
@@ -185,3 +317,12 @@ func (s Service) Log(prefix string, format string, a ...interface{}) {
 
 	s.devlog.Write(fmt.Sprintf("[gray][%s][white] %s%s\n", ts, prefix, l))
 }
+
+// timeFormat returns the configured time format
+func (s *Service) timeFormat() string {
+	if len(s.config.TimeFormat) == 0 {
+		return config.DefaultTimeFormat
+	}
+
+	return s.config.TimeFormat
+}
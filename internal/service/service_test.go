@@ -0,0 +1,223 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/config"
+)
+
+// newTestService returns a service drawing on a simulation screen, and a function quitting the
+// application (called at the end of the test)
+func newTestService(t *testing.T, root func(s *Service) tview.Primitive) (*Service, func()) {
+	t.Helper()
+
+	s := newService(&config.Config{})
+	s.app = tview.NewApplication()
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	screen.SetSize(120, 40)
+	s.app.SetScreen(screen)
+
+	go s.runUpdates()
+
+	s.status = NewStatus(s)
+	s.app.SetRoot(root(s), true)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.app.Run()
+	}()
+
+	// the application must be running before it is stopped
+	syncUI(t, s, func() {})
+
+	var once sync.Once
+	quit := func() {
+		once.Do(func() {
+			s.cancel()
+			s.app.Stop()
+
+			if err := <-done; err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+		})
+	}
+	t.Cleanup(quit)
+
+	return s, quit
+}
+
+// syncUI runs f on the UI goroutine, and waits until it is done
+func syncUI(t *testing.T, s *Service, f func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	s.QueueUpdateDraw(func() {
+		f()
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the UI goroutine")
+	}
+}
+
+func TestQueueUpdateDrawOrder(t *testing.T) {
+	s, _ := newTestService(t, func(s *Service) tview.Primitive { return tview.NewBox() })
+
+	const (
+		producers = 8
+		updates   = 200
+	)
+
+	// only accessed from the UI goroutine
+	got := make([][]int, producers)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+
+			for n := 0; n < updates; n++ {
+				n := n
+				s.QueueUpdateDraw(func() {
+					got[p] = append(got[p], n)
+				})
+			}
+		}(p)
+	}
+
+	// updates queued from the UI goroutine run after the ones already queued
+	nested := []string{}
+	syncUI(t, s, func() {
+		nested = append(nested, "outer")
+		s.QueueUpdateDraw(func() {
+			nested = append(nested, "inner")
+		})
+		nested = append(nested, "outer done")
+	})
+
+	wg.Wait()
+	syncUI(t, s, func() {
+		for p, values := range got {
+			if len(values) != updates {
+				t.Errorf("producer %d: %d updates, want %d", p, len(values), updates)
+				continue
+			}
+
+			for n, value := range values {
+				if value != n {
+					t.Errorf("producer %d: update %d ran at position %d", p, value, n)
+					break
+				}
+			}
+		}
+
+		want := []string{"outer", "outer done", "inner"}
+		if len(nested) != len(want) {
+			t.Fatalf("nested updates = %v, want %v", nested, want)
+		}
+		for idx := range want {
+			if nested[idx] != want[idx] {
+				t.Errorf("nested updates = %v, want %v", nested, want)
+				break
+			}
+		}
+	})
+}
+
+func TestQueueUpdateDrawAfterQuit(t *testing.T) {
+	s, quit := newTestService(t, func(s *Service) tview.Primitive { return tview.NewBox() })
+	quit()
+
+	ran := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for n := 0; n < 1000; n++ {
+			s.QueueUpdateDraw(func() {
+				select {
+				case ran <- struct{}{}:
+				default:
+				}
+			})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("QueueUpdateDraw blocked after quitting")
+	}
+
+	select {
+	case <-ran:
+		t.Error("update ran after quitting")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStatusTextCleared(t *testing.T) {
+	s, _ := newTestService(t, func(s *Service) tview.Primitive { return s.status.Get() })
+
+	// timers fired by the test, only accessed from the UI goroutine
+	type timer struct {
+		delay     time.Duration
+		fire      func()
+		cancelled bool
+	}
+	timers := []*timer{}
+
+	syncUI(t, s, func() {
+		s.status.afterFunc = func(d time.Duration, f func()) func() bool {
+			tm := &timer{delay: d, fire: f}
+			timers = append(timers, tm)
+			return func() bool {
+				tm.cancelled = true
+				return true
+			}
+		}
+
+		s.status.SetStatusText("first")
+		s.status.SetStatusText("second")
+	})
+
+	text := func() string {
+		var got string
+		syncUI(t, s, func() {
+			got = s.status.leftView.GetText(true)
+		})
+		return got
+	}
+
+	// fire waits until the clearing queued by the timer has run on the UI goroutine
+	fire := func(tm *timer) {
+		tm.fire()
+		syncUI(t, s, func() {})
+	}
+
+	syncUI(t, s, func() {
+		if len(timers) != 2 || timers[0].delay != statusClearDelay || !timers[0].cancelled || timers[1].cancelled {
+			t.Fatalf("timers = %+v, want the first one cancelled", timers)
+		}
+	})
+
+	// the timer of the first text fired before being cancelled, it must not clear the second one
+	fire(timers[0])
+	if got := text(); got != "second" {
+		t.Errorf("status text = %q, want %q", got, "second")
+	}
+
+	fire(timers[1])
+	if got := text(); got != "" {
+		t.Errorf("status text = %q, want it cleared", got)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/providers"
+)
+
+// handleTableInput tracks the current column when moving left and right through the table
+func (s *Slide) handleTableInput(event *tcell.EventKey) *tcell.EventKey {
+	direction := 0
+
+	switch event.Key() {
+	case tcell.KeyLeft:
+		direction = -1
+	case tcell.KeyRight:
+		direction = 1
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'h':
+			direction = -1
+		case 'l':
+			direction = 1
+		}
+	}
+
+	if direction != 0 {
+		row, col := s.table.GetSelection()
+		col += direction
+
+		if col >= 0 && col < s.table.GetColumnCount() {
+			s.table.Select(row, col)
+		}
+	}
+
+	return event
+}
+
+// handleSelectionChanged highlights the header of the current column
+func (s *Slide) handleSelectionChanged(row int, col int) {
+	if col == s.column {
+		return
+	}
+
+	s.column = col
+	s.renderHeaders()
+}
+
+// cycleSort cycles the sort of a column through ascending, descending and off
+func (s *Slide) cycleSort(col int) {
+	columns := s.columns()
+	idx := col - markerColumns
+	if idx < 0 || idx >= len(columns) {
+		return
+	}
+
+	field := columns[idx].Field
+	label := columnLabel(columns[idx])
+	current := s.profile.Sort
+
+	switch {
+	case current == nil || current.Field != field:
+		s.profile.Sort = &config.Sort{Field: field}
+		s.service.SetStatusText(s.profile.ID, "Sorting by %s (ascending)", label)
+	case !current.Descending:
+		s.profile.Sort = &config.Sort{Field: field, Descending: true}
+		s.service.SetStatusText(s.profile.ID, "Sorting by %s (descending)", label)
+	default:
+		s.profile.Sort = nil
+		s.service.SetStatusText(s.profile.ID, "Sorting by default order")
+	}
+
+	s.render()
+}
+
+// sortInstances sorts the instances according to the profile sort settings
+func (s *Slide) sortInstances(instances []*providers.Instance) {
+	if s.profile.Sort == nil || len(s.profile.Sort.Field) == 0 {
+		return
+	}
+
+	providers.SortInstances(instances, s.profile.Sort.Field, s.profile.Sort.Descending)
+}
+
+// sortIndicator returns the arrow displayed in the header of the sorted column
+func (s *Slide) sortIndicator(field string) string {
+	if s.profile.Sort == nil || s.profile.Sort.Field != field {
+		return ""
+	}
+
+	if s.profile.Sort.Descending {
+		return " ▼"
+	}
+
+	return " ▲"
+}
@@ -6,18 +6,33 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
-	"github.com/yogin/gosh/internal/config"
 )
 
 type Status struct {
 	service   *Service
 	leftView  *tview.TextView
+	infoView  *tview.TextView
 	rightView *tview.TextView
 	view      *tview.Flex
 
-	statusMutex      *sync.Mutex
-	statusClearTimer *time.Timer
+	pageInfo   map[string]string // persistent info per page (eg. active filter)
+	activePage string
+
+	statusMutex *sync.Mutex
+	cancelClear func() bool // cancels the clearing of the status text
+	statusID    int         // incremented for each status text, so only the latest one gets cleared
+
+	afterFunc func(d time.Duration, f func()) func() bool // schedules the clearing of the status text (time.AfterFunc), returns its cancel function
+}
+
+// statusClearDelay is how long the status texts are displayed
+const statusClearDelay = 3 * time.Second
+
+// afterFunc calls f after the delay, in its own goroutine
+func afterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
 }
 
 func NewStatus(service *Service) *Status {
@@ -25,6 +40,11 @@ func NewStatus(service *Service) *Status {
 	leftView.SetWrap(false)
 	leftView.SetTextAlign(tview.AlignLeft)
 
+	infoView := tview.NewTextView()
+	infoView.SetWrap(false)
+	infoView.SetTextAlign(tview.AlignCenter)
+	infoView.SetTextColor(tcell.ColorYellow)
+
 	rightView := tview.NewTextView()
 	rightView.SetWrap(false)
 	rightView.SetTextAlign(tview.AlignRight)
@@ -32,14 +52,18 @@ func NewStatus(service *Service) *Status {
 	view := tview.NewFlex()
 	view.SetDirection(tview.FlexColumn)
 	view.AddItem(leftView, 0, 1, false)
+	view.AddItem(infoView, 0, 1, false)
 	view.AddItem(rightView, 0, 1, false)
 
 	status := &Status{
 		service:     service,
 		view:        view,
 		leftView:    leftView,
+		infoView:    infoView,
 		rightView:   rightView,
 		statusMutex: &sync.Mutex{},
+		pageInfo:    make(map[string]string),
+		afterFunc:   afterFunc,
 	}
 	status.SetStatusText("Gosh, it's a status bar!")
 	status.update()
@@ -55,31 +79,59 @@ func (s *Status) Start() {
 	// refresh every second
 	go func() {
 		for range time.Tick(time.Second) {
-			s.service.GetApp().QueueUpdateDraw(func() {
-				s.update()
-			})
+			s.service.QueueUpdateDraw(s.update)
 		}
 	}()
 }
 
+// SetStatusText must be called from the UI goroutine (see Service.QueueUpdateDraw)
 func (s *Status) SetStatusText(format string, a ...interface{}) {
 	s.statusMutex.Lock()
 	defer s.statusMutex.Unlock()
 
 	// clear the timer if it's running
-	if s.statusClearTimer != nil {
-		s.statusClearTimer.Stop()
-		s.statusClearTimer = nil
+	if s.cancelClear != nil {
+		s.cancelClear()
+		s.cancelClear = nil
 	}
 
+	s.statusID++
+	id := s.statusID
 	s.leftView.SetText(fmt.Sprintf(format, a...))
 
-	// set a new timer to clear the status text
-	s.statusClearTimer = time.AfterFunc(3*time.Second, func() {
-		s.leftView.SetText("")
+	// set a new timer to clear the status text, from the UI goroutine
+	s.cancelClear = s.afterFunc(statusClearDelay, func() {
+		s.service.QueueUpdateDraw(func() {
+			s.statusMutex.Lock()
+			defer s.statusMutex.Unlock()
+
+			if id == s.statusID {
+				s.leftView.SetText("")
+			}
+		})
 	})
 }
 
+// SetPageInfo sets the persistent info text of a page, only displayed while the page is active
+func (s *Status) SetPageInfo(page string, format string, a ...interface{}) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	s.pageInfo[page] = fmt.Sprintf(format, a...)
+	if page == s.activePage {
+		s.infoView.SetText(s.pageInfo[page])
+	}
+}
+
+// SetActivePage switches the info text to the one of the active page
+func (s *Status) SetActivePage(page string) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	s.activePage = page
+	s.infoView.SetText(s.pageInfo[page])
+}
+
 func (s *Status) update() {
 	s.rightView.SetText(s.renderTime())
 }
@@ -109,9 +161,5 @@ func (s *Status) showUTCTime() bool {
 }
 
 func (s *Status) timeFormat() string {
-	if len(s.service.GetConfig().TimeFormat) == 0 {
-		return config.DefaultTimeFormat
-	}
-
-	return s.service.GetConfig().TimeFormat
+	return s.service.timeFormat()
 }
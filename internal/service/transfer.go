@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yogin/gosh/internal/broadcast"
+	"github.com/yogin/gosh/internal/connect"
+	"github.com/yogin/gosh/internal/utils"
+)
+
+// promptTransfer asks for the local and remote paths, then copies files to or from the marked instances
+func (s *Slide) promptTransfer(direction string) {
+	if s.provider == nil || len(s.markedInstances()) == 0 {
+		s.service.SetStatusText(s.profile.ID, "No instances selected")
+		return
+	}
+
+	if s.transferring {
+		s.service.SetStatusText(s.profile.ID, "A file transfer is already in progress")
+		return
+	}
+
+	// a single transfer at a time, from the first prompt until all the copies are done
+	s.transferring = true
+	cancel := func() { s.transferring = false }
+
+	if direction == connect.Upload {
+		s.prompt.AskOrCancel("Upload local path", "", func(local string) {
+			s.prompt.AskOrCancel("To remote path", "~/", func(remote string) {
+				s.transfer(direction, local, remote)
+			}, cancel)
+		}, cancel)
+		return
+	}
+
+	s.prompt.AskOrCancel("Download remote path", "", func(remote string) {
+		s.prompt.AskOrCancel("To local path", ".", func(local string) {
+			s.transfer(direction, local, remote)
+		}, cancel)
+	}, cancel)
+}
+
+// transfer copies files to or from the marked instances with scp, the progress and the result
+// of each instance are displayed in the status bar, transferring is cleared once the transfer is
+// done or could not be started
+func (s *Slide) transfer(direction string, local string, remote string) {
+	instances := s.markedInstances()
+	if len(instances) == 0 {
+		s.transferring = false
+		s.service.SetStatusText(s.profile.ID, "No instances selected")
+		return
+	}
+
+	s.prepareTargets(instances, func(targets []*connect.Target) {
+		jobs := []*broadcast.Job{}
+		names := make(map[string]string)
+
+		for _, target := range targets {
+			id := target.Instance.ID
+			names[id] = instanceName(target.Instance)
+
+			destination := local
+			if direction == connect.Download && len(targets) > 1 {
+				// keep the files of each instance apart
+				destination = filepath.Join(utils.ExpandHome(local), id)
+				if err := os.MkdirAll(destination, 0755); err != nil {
+					s.transferring = false
+					s.service.SetStatusText(s.profile.ID, "Unable to create %s: %s", destination, err)
+					return
+				}
+			}
+
+			args, err := target.CopyCommand(direction, destination, remote)
+			if err != nil {
+				s.service.SetStatusText(s.profile.ID, "Unable to %s files for %s: %s", direction, id, err)
+				continue
+			}
+
+			s.service.Log(s.profile.ID, "Transferring files for instance %s: %s", id, strings.Join(args, " "))
+			jobs = append(jobs, &broadcast.Job{ID: id, Args: args})
+		}
+
+		if len(jobs) == 0 {
+			s.transferring = false
+			return
+		}
+
+		label := "Upload"
+		if direction == connect.Download {
+			label = "Download"
+		}
+
+		s.service.SetStatusText(s.profile.ID, "%s started on %d instances%s", label, len(jobs), s.hiddenNote(instances))
+
+		done := 0
+		failures := []string{}
+
+		// the results are applied on the UI goroutine, in any order
+		report := func(result *broadcast.Result) {
+			s.service.QueueUpdateDraw(func() {
+				done++
+				id := result.Job.ID
+
+				if err := transferError(result); err != nil {
+					failures = append(failures, id)
+					s.service.SetStatusText(s.profile.ID, "%s %d/%d: %s (%s) failed: %s", label, done, len(jobs), names[id], id, err)
+				} else {
+					s.service.SetStatusText(s.profile.ID, "%s %d/%d: %s (%s) done in %s", label, done, len(jobs), names[id], id, result.Duration.Round(time.Millisecond))
+				}
+
+				if done < len(jobs) {
+					return
+				}
+
+				if len(failures) > 0 {
+					s.service.SetStatusText(s.profile.ID, "%s completed on %d instances, failed on %s", label, len(jobs), strings.Join(failures, ", "))
+					return
+				}
+
+				s.service.SetStatusText(s.profile.ID, "%s completed on %d instances", label, len(jobs))
+			})
+		}
+
+		go func() {
+			broadcast.Run(s.service.Context(), jobs, s.profile.Connect.Parallel, nil, report)
+
+			// queued after the results, also when the transfer is cancelled
+			s.service.QueueUpdateDraw(func() {
+				s.transferring = false
+			})
+		}()
+	}, func() {
+		s.transferring = false
+	})
+}
+
+// transferError returns the error of a transfer, including the scp error output
+func transferError(result *broadcast.Result) error {
+	if result.Err != nil {
+		return result.Err
+	}
+
+	if result.ExitCode != 0 {
+		if message := strings.TrimSpace(string(result.Stderr)); len(message) > 0 {
+			return fmt.Errorf("exit %d: %s", result.ExitCode, strings.ReplaceAll(message, "\n", " "))
+		}
+
+		return fmt.Errorf("exit %d", result.ExitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/connect"
+)
+
+// pressKey sends a key to the prompt, must be called from the UI goroutine
+func pressKey(p *Prompt, key tcell.Key) {
+	p.view.InputHandler()(tcell.NewEventKey(key, 0, tcell.ModNone), func(tview.Primitive) {})
+}
+
+func TestSlideTransferInProgress(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// answers the prompt with a value, or ESC when empty
+	answer := func(text string) func(s *Slide) {
+		return func(s *Slide) {
+			if len(text) == 0 {
+				pressKey(s.prompt, tcell.KeyEscape)
+				return
+			}
+
+			s.prompt.view.SetText(text)
+			pressKey(s.prompt, tcell.KeyEnter)
+		}
+	}
+
+	empty := func(s *Slide) {
+		s.prompt.view.SetText("")
+		pressKey(s.prompt, tcell.KeyEnter)
+	}
+
+	tests := []struct {
+		name    string
+		answers []func(s *Slide) // each answer is drawn before the next one
+	}{
+		{"escape", []func(s *Slide){answer("")}},
+		{"escape on the second prompt", []func(s *Slide){answer("/var/log/syslog"), answer("")}},
+		{"empty value", []func(s *Slide){empty}},
+		// the files of each instance can't be kept apart under a file
+		{"transfer not started", []func(s *Slide){answer("/var/log/syslog"), answer(file)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slide, _ := newTestSlide(t, "web-1", "web-2")
+			refresh(t, slide)
+
+			syncUI(t, slide.service, func() {
+				slide.toggleMarkAll()
+				slide.promptTransfer(connect.Download)
+
+				if !slide.transferring {
+					t.Error("transferring = false while prompting, want true")
+				}
+			})
+
+			for _, answer := range test.answers {
+				syncUI(t, slide.service, func() {
+					answer(slide)
+				})
+			}
+
+			syncUI(t, slide.service, func() {
+				if slide.transferring {
+					t.Error("transferring = true, want false once the transfer is abandoned")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/yogin/gosh/internal/config"
+	"github.com/yogin/gosh/internal/connect"
+	"github.com/yogin/gosh/internal/providers"
+	"github.com/yogin/gosh/internal/tunnel"
+)
+
+const TunnelsPageID = "tunnels" // TunnelsPageID identifies the tunnels page in the status bar and logs
+
+// TunnelsPage lists the tunnels opened from all the profiles
+type TunnelsPage struct {
+	service *Service
+	table   *tview.Table
+}
+
+func NewTunnelsPage(service *Service) *TunnelsPage {
+	p := &TunnelsPage{
+		service: service,
+	}
+
+	table := tview.NewTable()
+	table.SetFixed(1, 0)
+	table.SetSelectable(true, false)
+	table.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+	table.SetInputCapture(p.handleInput)
+	p.table = table
+
+	// keep the uptimes and traffic current until gosh exits
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-service.Context().Done():
+				return
+			case <-ticker.C:
+				service.QueueUpdateDraw(p.render)
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *TunnelsPage) Get(nextSlide func()) (title string, content tview.Primitive) {
+	p.render()
+	return "Tunnels", p.table
+}
+
+// Cancel does nothing, tunnels keep running when leaving the page
+func (p *TunnelsPage) Cancel() bool {
+	return false
+}
+
+func (p *TunnelsPage) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyDelete {
+		p.closeSelected()
+		return nil
+	}
+
+	switch event.Rune() {
+	case 'x': // close the selected tunnel
+		p.closeSelected()
+		return nil
+
+	case 'X': // close all the tunnels
+		p.service.tunnels.CloseAll()
+		p.service.SetStatusText(TunnelsPageID, "Closed all tunnels")
+		p.render()
+		return nil
+
+	case 'C': // clear the closed and failed tunnels
+		p.service.tunnels.Remove()
+		p.render()
+		return nil
+	}
+
+	return event
+}
+
+func (p *TunnelsPage) closeSelected() {
+	row, _ := p.table.GetSelection()
+	id, ok := p.table.GetCell(row, 0).GetReference().(int)
+	if !ok {
+		return
+	}
+
+	if err := p.service.tunnels.Close(id); err != nil {
+		p.service.SetStatusText(TunnelsPageID, "Unable to close tunnel: %s", err)
+		return
+	}
+
+	p.service.SetStatusText(TunnelsPageID, "Closed tunnel #%d", id)
+	p.render()
+}
+
+// render draws the tunnels, must be called from the UI goroutine
+func (p *TunnelsPage) render() {
+	row, _ := p.table.GetSelection()
+	p.table.Clear()
+
+	headers := []string{"#", "Profile", "Name", "Instance", "Forward", "State", "Uptime", "Traffic", "Error"}
+	for col, header := range headers {
+		p.table.SetCell(0, col, tview.NewTableCell(header).
+			SetSelectable(false).
+			SetAttributes(tcell.AttrBold).
+			SetBackgroundColor(tcell.ColorDimGrey.TrueColor()))
+	}
+
+	tunnels := p.service.tunnels.List()
+	if len(tunnels) == 0 {
+		p.table.SetCell(1, 0, tview.NewTableCell("No tunnels, press L on an instance to open one").
+			SetSelectable(false).
+			SetExpansion(1))
+		return
+	}
+
+	for i, t := range tunnels {
+		state, err := t.State()
+
+		color := tcell.ColorWhite.TrueColor()
+		switch state {
+		case tunnel.StateOpen:
+			color = tcell.ColorPaleGreen.TrueColor()
+		case tunnel.StateStarting:
+			color = tcell.ColorYellow.TrueColor()
+		case tunnel.StateFailed:
+			color = tcell.ColorCrimson.TrueColor()
+		case tunnel.StateClosed:
+			color = tcell.ColorGrey.TrueColor()
+		}
+
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+
+		values := []string{
+			strconv.Itoa(t.ID),
+			t.Profile,
+			t.Name,
+			fmt.Sprintf("%s (%s)", t.Label, t.Instance),
+			t.Forward.String(),
+			state,
+			t.Uptime().Round(time.Second).String(),
+			traffic(t),
+			message,
+		}
+
+		for col, value := range values {
+			p.table.SetCell(i+1, col, tview.NewTableCell(value).
+				SetReference(t.ID).
+				SetTextColor(color))
+		}
+	}
+
+	if row < 1 {
+		row = 1
+	}
+	if row > len(tunnels) {
+		row = len(tunnels)
+	}
+	p.table.Select(row, 0)
+}
+
+// traffic returns the bytes sent and received through a tunnel, or - when they are not counted
+func traffic(t *tunnel.Tunnel) string {
+	sent, received, ok := t.Traffic()
+	if !ok {
+		return "-"
+	}
+
+	return fmt.Sprintf("↑ %s ↓ %s", formatBytes(sent), formatBytes(received))
+}
+
+// formatBytes returns a size in bytes with a binary unit, eg. 1.5 KiB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// promptTunnel asks for a forward (or preset name) and opens it on the selected instance
+func (s *Slide) promptTunnel() {
+	instance := s.selectedInstance()
+	if instance == nil {
+		s.service.SetStatusText(s.profile.ID, "No instance selected")
+		return
+	}
+
+	names := []string{}
+	for _, preset := range s.profile.Tunnels {
+		names = append(names, preset.Name)
+	}
+
+	label := "Forward (local->host:port)"
+	if len(names) > 0 {
+		label = fmt.Sprintf("Forward (local->host:port or %s)", strings.Join(names, ", "))
+	}
+
+	s.prompt.Ask(label, "", func(text string) {
+		for _, preset := range s.profile.Tunnels {
+			if preset.Name == text {
+				s.openTunnel(instance, preset)
+				return
+			}
+		}
+
+		s.openTunnel(instance, &config.Tunnel{Forward: text})
+	})
+}
+
+// boundKeys are the keys of the instances list: the keys of the application (service.go) and of the
+// page (page.go), and the table navigation keys, tunnel presets can't be bound to them
+const boundKeys = "qQwW123456789~" + " axotTuDydiALrR/?csnN" + "hjklgG"
+
+// validateTunnelKeys checks that the tunnel presets are not bound to a key of the instances list,
+// they would never open
+func validateTunnelKeys(cfg *config.Config) error {
+	for _, profile := range cfg.Profiles {
+		for _, preset := range profile.Tunnels {
+			if len(preset.Key) > 0 && strings.Contains(boundKeys, preset.Key) {
+				return fmt.Errorf("profile '%s': tunnel '%s' key '%s' is already bound in the instances list", profile.ID, preset.Name, preset.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// tunnelPreset returns the tunnel preset bound to a key
+func (s *Slide) tunnelPreset(key rune) *config.Tunnel {
+	for _, preset := range s.profile.Tunnels {
+		if preset.Key == string(key) {
+			return preset
+		}
+	}
+
+	return nil
+}
+
+// validateTunnels reports invalid tunnel presets, the preset keys are checked when gosh starts
+// (validateTunnelKeys)
+func (s *Slide) validateTunnels() {
+	for _, preset := range s.profile.Tunnels {
+		if _, err := tunnel.ParseForward(preset.Forward); err != nil {
+			s.service.SetStatusText(s.profile.ID, "Tunnel '%s': %s", preset.Name, err)
+		}
+	}
+}
+
+// openTunnel starts a tunnel on the instance in the background
+func (s *Slide) openTunnel(instance *providers.Instance, preset *config.Tunnel) {
+	forward, err := tunnel.ParseForward(preset.Forward)
+	if err != nil {
+		s.service.SetStatusText(s.profile.ID, "%s", err)
+		return
+	}
+
+	s.prepareTargets([]*providers.Instance{instance}, func(targets []*connect.Target) {
+		target := targets[0]
+
+		// the command listens on another port, gosh relays the local port to it to count the bytes
+		port, err := tunnel.FreePort()
+		if err != nil {
+			s.service.Log(s.profile.ID, "Unable to find a free port, the traffic of the tunnel won't be counted: %s", err)
+		}
+
+		localPort := forward.LocalPort
+		if port > 0 {
+			localPort = port
+		}
+
+		args, err := target.TunnelCommand(localPort, forward.RemoteHost, forward.RemotePort)
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to open tunnel to %s: %s", instance.ID, err)
+			return
+		}
+
+		t := &tunnel.Tunnel{
+			Name:     preset.Name,
+			Profile:  s.profile.ID,
+			Instance: instance.ID,
+			Label:    instanceName(instance),
+			Forward:  forward,
+			Args:     args,
+			Port:     port,
+		}
+
+		s.service.Log(s.profile.ID, "Opening tunnel %s on instance %s: %s", forward, instance.ID, strings.Join(args, " "))
+		if err := s.service.tunnels.Open(t); err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to open tunnel to %s: %s", instance.ID, err)
+			return
+		}
+
+		s.service.SetStatusText(s.profile.ID, "Opened tunnel #%d %s on %s (see the Tunnels page)", t.ID, forward, instance.ID)
+	}, nil)
+}
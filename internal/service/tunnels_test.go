@@ -0,0 +1,101 @@
+package service
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yogin/gosh/internal/config"
+)
+
+// TestBoundKeys checks that boundKeys has the keys handled by the application and the page
+func TestBoundKeys(t *testing.T) {
+	for _, file := range []string{"service.go", "page.go"} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ast.Inspect(f, func(node ast.Node) bool {
+			clause, ok := node.(*ast.CaseClause)
+			if !ok {
+				return true
+			}
+
+			for _, expr := range clause.List {
+				if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.CHAR {
+					key, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !strings.Contains(boundKeys, key) {
+						t.Errorf("%s: key %s is missing from boundKeys", fset.Position(lit.Pos()), lit.Value)
+					}
+				}
+			}
+
+			return true
+		})
+	}
+}
+
+func TestValidateTunnelKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{"free key", "P", ""},
+		{"no key", "", ""},
+		{"page key", "c", "profile 'usw1': tunnel 'postgres' key 'c' is already bound in the instances list"},
+		{"application key", "w", "profile 'usw1': tunnel 'postgres' key 'w' is already bound in the instances list"},
+		{"navigation key", "j", "profile 'usw1': tunnel 'postgres' key 'j' is already bound in the instances list"},
+		{"last row key", "G", "profile 'usw1': tunnel 'postgres' key 'G' is already bound in the instances list"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{Profiles: []*config.Profile{{
+				ID:      "usw1",
+				Tunnels: []*config.Tunnel{{Name: "postgres", Forward: "5432->db.internal:5432", Key: test.key}},
+			}}}
+
+			err := validateTunnelKeys(cfg)
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Errorf("validateTunnelKeys() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.err {
+				t.Errorf("validateTunnelKeys() error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+
+	for _, test := range tests {
+		if got := formatBytes(test.size); got != test.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", test.size, got, test.want)
+		}
+	}
+}
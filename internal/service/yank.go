@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/yogin/gosh/internal/clipboard"
+	"github.com/yogin/gosh/internal/connect"
+)
+
+// copy copies the text to the clipboard, and confirms it in the status bar
+func (s *Slide) copy(label string, text string) {
+	if len(text) == 0 {
+		s.service.SetStatusText(s.profile.ID, "Nothing to copy, %s is empty", label)
+		return
+	}
+
+	if err := clipboard.Copy(s.service.terminal(), text, s.service.config.ClipboardCommand); err != nil {
+		s.service.SetStatusText(s.profile.ID, "Unable to copy %s: %s", label, err)
+		return
+	}
+
+	s.service.SetStatusText(s.profile.ID, "Copied %s: %s", label, text)
+}
+
+// startYank waits for the second key of a yank shortcut (eg. yi)
+func (s *Slide) startYank() {
+	if s.selectedInstance() == nil {
+		s.service.SetStatusText(s.profile.ID, "No instance selected")
+		return
+	}
+
+	s.yanking = true
+	s.service.SetStatusText(s.profile.ID, "Copy: i (ID), p (private IP), P (public IP), y (row), s (ssh command)")
+}
+
+// handleYank copies the selected instance field matching the key following y
+func (s *Slide) handleYank(event *tcell.EventKey) {
+	s.yanking = false
+
+	instance := s.selectedInstance()
+	if instance == nil {
+		return
+	}
+
+	switch event.Rune() {
+	case 'i':
+		s.copy("ID", instance.ID)
+
+	case 'p':
+		s.copy("private IP", instance.PrivateIP)
+
+	case 'P':
+		s.copy("public IP", instance.PublicIP)
+
+	case 'y': // the displayed columns, tab separated
+		values := []string{}
+		for _, column := range s.columns() {
+			values = append(values, s.fieldValue(instance, column.Field))
+		}
+		s.copy("row", strings.Join(values, "\t"))
+
+	case 's':
+		target, err := s.target(instance)
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to copy the ssh command: %s", err)
+			return
+		}
+
+		args, err := target.Command()
+		if err != nil {
+			s.service.SetStatusText(s.profile.ID, "Unable to copy the ssh command: %s", err)
+			return
+		}
+		s.copy("ssh command", connect.ShellQuote(args))
+
+	default:
+		s.service.SetStatusText(s.profile.ID, "Copy cancelled")
+	}
+}
@@ -0,0 +1,441 @@
+// Package tunnel manages port forwarding processes (ssh -L) running in the background.
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StateStarting = "starting" // StateStarting is the state of a tunnel until the forward is listening
+	StateOpen     = "open"     // StateOpen is the state of a tunnel listening on its local port
+	StateClosed   = "closed"   // StateClosed is the state of a tunnel closed from gosh
+	StateFailed   = "failed"   // StateFailed is the state of a tunnel whose process exited on its own
+)
+
+// Forward is a local port forwarded to a host and port reachable from the instance
+type Forward struct {
+	LocalPort  int
+	RemoteHost string
+	RemotePort int
+}
+
+// ParseForward parses a forward: local->host:port (or the ssh syntax local:host:port), the host
+// defaults to localhost (the instance itself) when omitted, eg. 8080->80
+func ParseForward(value string) (*Forward, error) {
+	value = strings.TrimSpace(value)
+
+	local, remote, ok := strings.Cut(value, "->")
+	if !ok {
+		local, remote, ok = strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid forward '%s' (expected local->host:port)", value)
+		}
+	}
+
+	forward := &Forward{RemoteHost: "localhost"}
+
+	port, err := parsePort(local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local port in forward '%s': %w", value, err)
+	}
+	forward.LocalPort = port
+
+	host, remotePort := "", strings.TrimSpace(remote)
+	if idx := strings.LastIndex(remotePort, ":"); idx >= 0 {
+		host, remotePort = remotePort[:idx], remotePort[idx+1:]
+	}
+
+	port, err = parsePort(remotePort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote port in forward '%s': %w", value, err)
+	}
+	forward.RemotePort = port
+
+	if host = strings.Trim(strings.TrimSpace(host), "[]"); len(host) > 0 {
+		forward.RemoteHost = host
+	}
+
+	return forward, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("'%s' is not a valid port", strings.TrimSpace(value))
+	}
+
+	return port, nil
+}
+
+func (f *Forward) String() string {
+	return fmt.Sprintf("%d->%s:%d", f.LocalPort, f.RemoteHost, f.RemotePort)
+}
+
+// Tunnel is a port forwarding process
+type Tunnel struct {
+	ID       int
+	Name     string // preset name, empty for custom forwards
+	Profile  string // profile ID
+	Instance string // instance ID
+	Label    string // instance name
+	Forward  *Forward
+	Args     []string // command running the tunnel
+	Port     int      // local port of the command when gosh relays Forward.LocalPort to it (counting the bytes), 0 when the command listens on Forward.LocalPort
+	Started  time.Time
+
+	cmd      *exec.Cmd
+	cancel   context.CancelFunc
+	listener net.Listener // Forward.LocalPort, relayed to Port
+	sent     atomic.Int64
+	received atomic.Int64
+	stderr   bytes.Buffer
+	state    string
+	err      error
+	ended    time.Time
+	mutex    sync.Mutex
+}
+
+// FreePort returns a local port that isn't used, for the command of a relayed tunnel
+func FreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// State returns the state of the tunnel, and the error of failed tunnels
+func (t *Tunnel) State() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.state, t.err
+}
+
+// Uptime returns for how long the tunnel has been (or was) running
+func (t *Tunnel) Uptime() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.ended.IsZero() {
+		return t.ended.Sub(t.Started)
+	}
+
+	return time.Since(t.Started)
+}
+
+// Traffic returns the bytes sent and received through the tunnel, ok is false when they are not
+// counted (the tunnel is not relayed)
+func (t *Tunnel) Traffic() (sent int64, received int64, ok bool) {
+	return t.sent.Load(), t.received.Load(), t.Port > 0
+}
+
+// port returns the local port of the tunnel command
+func (t *Tunnel) port() int {
+	if t.Port > 0 {
+		return t.Port
+	}
+
+	return t.Forward.LocalPort
+}
+
+func (t *Tunnel) setState(state string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// a closed tunnel doesn't fail when its process is killed
+	if t.state == StateClosed {
+		return
+	}
+
+	t.state = state
+	t.err = err
+
+	if state == StateClosed || state == StateFailed {
+		t.ended = time.Now()
+	}
+}
+
+// stop kills the tunnel process right away (not waiting for the context to be noticed, gosh may be exiting)
+func (t *Tunnel) stop() {
+	t.setState(StateClosed, nil)
+	t.cancel()
+	t.closeListener()
+
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+}
+
+func (t *Tunnel) closeListener() {
+	if t.listener != nil {
+		_ = t.listener.Close()
+	}
+}
+
+// relay accepts the connections on the local port, and passes them to the tunnel command until
+// the listener is closed
+func (t *Tunnel) relay() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go t.pipe(conn)
+	}
+}
+
+// pipe copies a connection to the tunnel command and back, counting the bytes
+func (t *Tunnel) pipe(conn net.Conn) {
+	defer conn.Close()
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(t.Port)))
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		copyCounting(upstream, conn, &t.sent)
+	}()
+
+	go func() {
+		defer wg.Done()
+		copyCounting(conn, upstream, &t.received)
+	}()
+
+	wg.Wait()
+}
+
+// copyCounting copies src to dst as it is read, then closes the writing side of dst so the other
+// end sees the end of the stream
+func copyCounting(dst net.Conn, src net.Conn, total *atomic.Int64) {
+	_, _ = io.Copy(&counter{writer: dst, total: total}, src)
+
+	if conn, ok := dst.(*net.TCPConn); ok {
+		_ = conn.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// counter counts the bytes written
+type counter struct {
+	writer io.Writer
+	total  *atomic.Int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.total.Add(int64(n))
+
+	return n, err
+}
+
+// listening indicates if a local port accepts connections
+func listening(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 200*time.Millisecond)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
+// Manager runs the tunnels, changed is called (from any goroutine) when a tunnel state changes
+type Manager struct {
+	tunnels []*Tunnel
+	nextID  int
+	changed func()
+	mutex   sync.Mutex
+}
+
+func NewManager(changed func()) *Manager {
+	return &Manager{
+		nextID:  1,
+		changed: changed,
+	}
+}
+
+// Open starts the tunnel process in the background
+func (m *Manager) Open(t *Tunnel) error {
+	if len(t.Args) == 0 {
+		return errors.New("empty tunnel command")
+	}
+
+	m.mutex.Lock()
+	for _, other := range m.tunnels {
+		if state, _ := other.State(); (state == StateStarting || state == StateOpen) && other.Forward.LocalPort == t.Forward.LocalPort {
+			m.mutex.Unlock()
+			return fmt.Errorf("local port %d is already used by tunnel #%d", t.Forward.LocalPort, other.ID)
+		}
+	}
+	m.mutex.Unlock()
+
+	if t.Port > 0 {
+		listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(t.Forward.LocalPort)))
+		if err != nil {
+			return fmt.Errorf("unable to listen on local port %d: %w", t.Forward.LocalPort, err)
+		}
+		t.listener = listener
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, t.Args[0], t.Args[1:]...)
+	cmd.Stderr = &t.stderr
+
+	t.cmd = cmd
+	t.cancel = cancel
+	t.state = StateStarting
+	t.Started = time.Now()
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		t.closeListener()
+		return fmt.Errorf("unable to start tunnel: %w", err)
+	}
+
+	m.mutex.Lock()
+	t.ID = m.nextID
+	m.nextID++
+	m.tunnels = append(m.tunnels, t)
+	m.mutex.Unlock()
+
+	go m.wait(t)
+	go m.probe(ctx, t)
+
+	if t.listener != nil {
+		go t.relay()
+	}
+
+	return nil
+}
+
+// wait waits for the tunnel process to exit
+func (m *Manager) wait(t *Tunnel) {
+	err := t.cmd.Wait()
+	t.cancel()
+	t.closeListener()
+
+	// stderr is fully written once the process exited
+	message := strings.TrimSpace(t.stderr.String())
+
+	if len(message) > 0 {
+		// the last line is usually the most relevant (eg. bind: Address already in use)
+		lines := strings.Split(message, "\n")
+		err = errors.New(strings.TrimSpace(lines[len(lines)-1]))
+	} else if err == nil {
+		err = errors.New("tunnel process exited")
+	}
+
+	t.setState(StateFailed, err)
+	m.notify()
+}
+
+// probe marks the tunnel open once the local port of its command accepts connections
+func (m *Manager) probe(ctx context.Context, t *Tunnel) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if listening(t.port()) {
+				t.mutex.Lock()
+				if t.state == StateStarting {
+					t.state = StateOpen
+				}
+				t.mutex.Unlock()
+
+				m.notify()
+				return
+			}
+		}
+	}
+}
+
+// Close stops the tunnel process
+func (m *Manager) Close(id int) error {
+	t := m.Get(id)
+	if t == nil {
+		return fmt.Errorf("tunnel #%d not found", id)
+	}
+
+	t.stop()
+	m.notify()
+
+	return nil
+}
+
+// CloseAll stops all the tunnel processes
+func (m *Manager) CloseAll() {
+	for _, t := range m.List() {
+		if state, _ := t.State(); state == StateStarting || state == StateOpen {
+			t.stop()
+		}
+	}
+}
+
+// Remove forgets the tunnels that are not running anymore
+func (m *Manager) Remove() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tunnels := []*Tunnel{}
+	for _, t := range m.tunnels {
+		if state, _ := t.State(); state == StateStarting || state == StateOpen {
+			tunnels = append(tunnels, t)
+		}
+	}
+
+	m.tunnels = tunnels
+}
+
+// Get returns a tunnel by ID
+func (m *Manager) Get(id int) *Tunnel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, t := range m.tunnels {
+		if t.ID == id {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// List returns the tunnels in the order they were opened
+func (m *Manager) List() []*Tunnel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]*Tunnel{}, m.tunnels...)
+}
+
+func (m *Manager) notify() {
+	if m.changed != nil {
+		m.changed()
+	}
+}
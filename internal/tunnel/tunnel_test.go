@@ -0,0 +1,270 @@
+package tunnel
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *Forward
+		err   string
+	}{
+		{"8080->80", &Forward{8080, "localhost", 80}, ""},
+		{"5432->db.internal:5432", &Forward{5432, "db.internal", 5432}, ""},
+		{" 5432 -> db.internal:5433 ", &Forward{5432, "db.internal", 5433}, ""},
+		{"8080->[fd00::1]:80", &Forward{8080, "fd00::1", 80}, ""},
+		{"8080->fd00::1:80", &Forward{8080, "fd00::1", 80}, ""},
+		{"8080:db.internal:80", &Forward{8080, "db.internal", 80}, ""},
+		{"8080:80", &Forward{8080, "localhost", 80}, ""},
+		{"8080", nil, "invalid forward '8080' (expected local->host:port)"},
+		{"", nil, "invalid forward '' (expected local->host:port)"},
+		{"web->80", nil, "invalid local port in forward 'web->80': 'web' is not a valid port"},
+		{"0->80", nil, "invalid local port in forward '0->80': '0' is not a valid port"},
+		{"70000->80", nil, "invalid local port in forward '70000->80': '70000' is not a valid port"},
+		{"8080->db.internal", nil, "invalid remote port in forward '8080->db.internal': 'db.internal' is not a valid port"},
+		{"8080->db.internal:", nil, "invalid remote port in forward '8080->db.internal:': '' is not a valid port"},
+		{"8080->db.internal:-1", nil, "invalid remote port in forward '8080->db.internal:-1': '-1' is not a valid port"},
+		{"8080->:80", &Forward{8080, "localhost", 80}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseForward(test.value)
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ParseForward() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseForward() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseForward() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// freePort returns a local port that isn't used
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	port, err := FreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return port
+}
+
+// waitState waits until the tunnel is in the state
+func waitState(t *testing.T, tunnel *Tunnel, state string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, _ := tunnel.State(); got == state {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			got, err := tunnel.State()
+			t.Fatalf("tunnel state = %s (%v), want %s", got, err, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagerOpenClose(t *testing.T) {
+	changes := make(chan struct{}, 100)
+	m := NewManager(func() { changes <- struct{}{} })
+	t.Cleanup(m.CloseAll)
+
+	// the command doesn't listen on the port, the tunnel keeps starting
+	first := &Tunnel{Forward: &Forward{LocalPort: freePort(t)}, Args: []string{"sleep", "10"}}
+	if err := m.Open(first); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if state, _ := first.State(); state != StateStarting || first.ID != 1 {
+		t.Errorf("tunnel = #%d %s, want #1 %s", first.ID, state, StateStarting)
+	}
+
+	// the local port is used by the first tunnel
+	second := &Tunnel{Forward: &Forward{LocalPort: first.Forward.LocalPort}, Args: []string{"sleep", "10"}}
+	if err := m.Open(second); err == nil || !strings.Contains(err.Error(), "is already used by tunnel #1") {
+		t.Errorf("Open() error = %v, want the local port already used", err)
+	}
+
+	if err := m.Open(&Tunnel{Forward: &Forward{LocalPort: freePort(t)}}); err == nil {
+		t.Error("Open() without a command succeeded, want an error")
+	}
+
+	if err := m.Close(first.ID); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// a closed tunnel doesn't fail when its process is killed
+	time.Sleep(100 * time.Millisecond)
+	if state, err := first.State(); state != StateClosed || err != nil {
+		t.Errorf("tunnel state = %s (%v), want %s", state, err, StateClosed)
+	}
+
+	select {
+	case <-changes:
+	default:
+		t.Error("no change notified")
+	}
+
+	if err := m.Close(42); err == nil {
+		t.Error("Close() of an unknown tunnel succeeded, want an error")
+	}
+
+	// the port is free again
+	third := &Tunnel{Forward: &Forward{LocalPort: first.Forward.LocalPort}, Args: []string{"sleep", "10"}}
+	if err := m.Open(third); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if got := len(m.List()); got != 2 {
+		t.Errorf("List() has %d tunnels, want 2", got)
+	}
+
+	m.Remove()
+	if list := m.List(); len(list) != 1 || list[0] != third || m.Get(third.ID) != third || m.Get(first.ID) != nil {
+		t.Errorf("List() = %v after Remove(), want only the running tunnel", list)
+	}
+}
+
+func TestManagerFailed(t *testing.T) {
+	m := NewManager(nil)
+
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"ssh error", "echo 'connecting' >&2; echo 'bind [127.0.0.1]:8080: Address already in use' >&2; exit 255", "bind [127.0.0.1]:8080: Address already in use"},
+		{"no output", "exit 0", "tunnel process exited"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tunnel := &Tunnel{Forward: &Forward{LocalPort: freePort(t)}, Args: []string{"sh", "-c", test.script}}
+			if err := m.Open(tunnel); err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			waitState(t, tunnel, StateFailed)
+
+			if _, err := tunnel.State(); err == nil || err.Error() != test.err {
+				t.Errorf("tunnel error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestManagerRelay(t *testing.T) {
+	// plays the tunnel command: an echo server on the port of the command
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	m := NewManager(nil)
+	t.Cleanup(m.CloseAll)
+
+	tunnel := &Tunnel{
+		Forward: &Forward{LocalPort: freePort(t), RemoteHost: "localhost", RemotePort: 80},
+		Args:    []string{"sleep", "10"},
+		Port:    upstream.Addr().(*net.TCPAddr).Port,
+	}
+	if err := m.Open(tunnel); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	waitState(t, tunnel, StateOpen)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(tunnel.Forward.LocalPort)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	conn.(*net.TCPConn).CloseWrite()
+
+	reply, err := io.ReadAll(conn)
+	conn.Close()
+	if err != nil || string(reply) != "ping" {
+		t.Fatalf("reply = %q (%v), want ping", reply, err)
+	}
+
+	if sent, received, ok := tunnel.Traffic(); sent != 4 || received != 4 || !ok {
+		t.Errorf("Traffic() = %d, %d, %t, want 4, 4, true", sent, received, ok)
+	}
+
+	// the local port is released with the tunnel
+	if err := m.Close(tunnel.ID); err != nil {
+		t.Fatal(err)
+	}
+	if listening(tunnel.Forward.LocalPort) {
+		t.Error("local port still listening once the tunnel is closed")
+	}
+
+	// the bytes of tunnels listening on the local port themselves are not counted
+	direct := &Tunnel{Forward: &Forward{LocalPort: freePort(t)}, Args: []string{"sleep", "10"}}
+	if err := m.Open(direct); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := direct.Traffic(); ok {
+		t.Error("Traffic() counted for a tunnel that isn't relayed")
+	}
+}
+
+func TestManagerRelayPortUsed(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer used.Close()
+
+	m := NewManager(nil)
+	tunnel := &Tunnel{
+		Forward: &Forward{LocalPort: used.Addr().(*net.TCPAddr).Port},
+		Args:    []string{"sleep", "10"},
+		Port:    freePort(t),
+	}
+
+	if err := m.Open(tunnel); err == nil || !strings.Contains(err.Error(), "unable to listen on local port") {
+		t.Errorf("Open() error = %v, want the local port already used", err)
+	}
+	if len(m.List()) != 0 {
+		t.Error("tunnel listed although it could not be opened")
+	}
+}
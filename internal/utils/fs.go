@@ -2,6 +2,8 @@ package utils
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func PathInfo(path string) (bool, os.FileInfo, error) {
@@ -30,3 +32,17 @@ func IsDirectory(path string) bool {
 
 	return info.IsDir()
 }
+
+// ExpandHome replaces ~ or a leading ~/ of a path with the user's home directory, as a shell would
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}